@@ -0,0 +1,237 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	pb "github.com/rekib0023/event-horizon-gateway/proto"
+)
+
+// Formats accepted in config.AccessLog.Format.
+const (
+	FormatCombined = "combined"
+	FormatJSON     = "json"
+	FormatTemplate = "template"
+)
+
+// Entry is one access log record. Custom templates refer to its fields,
+// e.g. `{{.Method}} {{.Path}} {{.Status}} {{.Latency}}`.
+type Entry struct {
+	Time            time.Time     `json:"time"`
+	RemoteAddr      string        `json:"remote_addr"`
+	User            string        `json:"user,omitempty"`
+	Method          string        `json:"method"`
+	Path            string        `json:"path"`
+	Route           string        `json:"route,omitempty"`
+	Proto           string        `json:"proto"`
+	Status          int           `json:"status"`
+	Bytes           int           `json:"bytes"`
+	Referer         string        `json:"referer,omitempty"`
+	UserAgent       string        `json:"user_agent,omitempty"`
+	RequestID       string        `json:"request_id,omitempty"`
+	Latency         time.Duration `json:"-"`
+	AuthLatency     time.Duration `json:"-"`
+	UpstreamLatency time.Duration `json:"-"`
+	GatewayLatency  time.Duration `json:"-"`
+}
+
+func (e Entry) MarshalJSON() ([]byte, error) {
+	type plain Entry
+	return json.Marshal(struct {
+		plain
+		LatencyMs         float64 `json:"latency_ms"`
+		AuthLatencyMs     float64 `json:"auth_latency_ms"`
+		UpstreamLatencyMs float64 `json:"upstream_latency_ms"`
+		GatewayLatencyMs  float64 `json:"gateway_latency_ms"`
+	}{
+		plain:             plain(e),
+		LatencyMs:         millis(e.Latency),
+		AuthLatencyMs:     millis(e.AuthLatency),
+		UpstreamLatencyMs: millis(e.UpstreamLatency),
+		GatewayLatencyMs:  millis(e.GatewayLatency),
+	})
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// Logger writes access log entries, independently of the application log.
+type Logger struct {
+	mu       sync.Mutex
+	w        io.Writer
+	format   string
+	tmpl     *template.Template
+	sampling []config.AccessLogSampling
+	random   func() float64
+}
+
+func New(w io.Writer, cfg config.AccessLog) (*Logger, error) {
+	l := &Logger{w: w, format: cfg.Format, sampling: cfg.Sampling, random: rand.Float64}
+	switch cfg.Format {
+	case "":
+		l.format = FormatCombined
+	case FormatCombined, FormatJSON:
+	case FormatTemplate:
+		tmpl, err := template.New("accesslog").Parse(cfg.Template)
+		if err != nil {
+			return nil, fmt.Errorf("parse access log template: %w", err)
+		}
+		l.tmpl = tmpl
+	default:
+		return nil, fmt.Errorf("unknown access log format %q", cfg.Format)
+	}
+	for _, rule := range cfg.Sampling {
+		if rule.Rate < 0 || rule.Rate > 1 {
+			return nil, fmt.Errorf("access log sampling rate for %q must be between 0 and 1", rule.Route)
+		}
+	}
+	return l, nil
+}
+
+// Open returns the writer named by cfg.Output.
+func Open(cfg config.AccessLog) (io.Writer, error) {
+	switch cfg.Output {
+	case "", "stdout":
+		return os.Stdout, nil
+	case "off":
+		return io.Discard, nil
+	}
+	return OpenRotatingFile(cfg.Output, int64(cfg.MaxSizeMB)<<20, cfg.MaxBackups)
+}
+
+// Middleware replaces gin's default logger. It must run before the auth
+// middleware and any upstream calls so that the latency breakdown is
+// captured.
+func (l *Logger) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		t := &timings{}
+		c.Request = c.Request.WithContext(withTimings(c.Request.Context(), t))
+
+		c.Next()
+
+		entry := Entry{
+			Time:       start,
+			RemoteAddr: c.ClientIP(),
+			Method:     c.Request.Method,
+			Path:       c.Request.URL.RequestURI(),
+			Route:      c.FullPath(),
+			Proto:      c.Request.Proto,
+			Status:     c.Writer.Status(),
+			Bytes:      c.Writer.Size(),
+			Referer:    c.Request.Referer(),
+			UserAgent:  c.Request.UserAgent(),
+			RequestID:  c.GetString("requestId"),
+			Latency:    time.Since(start),
+		}
+		if entry.Bytes < 0 {
+			entry.Bytes = 0
+		}
+		if v, ok := c.Get("user"); ok {
+			if user, ok := v.(*pb.TokenVerification); ok {
+				entry.User = user.GetId()
+			}
+		}
+		entry.AuthLatency, entry.UpstreamLatency = t.totals()
+		entry.GatewayLatency = entry.Latency - entry.AuthLatency - entry.UpstreamLatency
+		if entry.GatewayLatency < 0 {
+			entry.GatewayLatency = 0
+		}
+
+		if l.sampled(entry) {
+			l.Write(entry)
+		}
+	}
+}
+
+// sampled applies the first sampling rule matching the entry's route and
+// status; unmatched entries are always logged.
+func (l *Logger) sampled(e Entry) bool {
+	key := e.Method + " " + e.Route
+	for _, rule := range l.sampling {
+		if rule.Route != "*" && rule.Route != key {
+			continue
+		}
+		if !statusMatches(rule.Status, e.Status) {
+			continue
+		}
+		return rule.Rate >= 1 || l.random() < rule.Rate
+	}
+	return true
+}
+
+// statusMatches accepts "", an exact status such as "404", or a class such
+// as "2xx".
+func statusMatches(pattern string, status int) bool {
+	if pattern == "" || pattern == "*" {
+		return true
+	}
+	code := strconv.Itoa(status)
+	if len(pattern) == 3 && strings.HasSuffix(strings.ToLower(pattern), "xx") {
+		return code[0] == pattern[0]
+	}
+	return pattern == code
+}
+
+func (l *Logger) Write(e Entry) {
+	var buf bytes.Buffer
+	switch l.format {
+	case FormatJSON:
+		data, err := json.Marshal(e)
+		if err != nil {
+			return
+		}
+		buf.Write(data)
+	case FormatTemplate:
+		if err := l.tmpl.Execute(&buf, e); err != nil {
+			return
+		}
+	default:
+		writeCombined(&buf, e)
+	}
+	buf.WriteByte('\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(buf.Bytes())
+}
+
+// writeCombined renders the Apache combined log format.
+func writeCombined(buf *bytes.Buffer, e Entry) {
+	fmt.Fprintf(buf, "%s - %s [%s] \"%s %s %s\" %d %s \"%s\" \"%s\"",
+		dash(e.RemoteAddr),
+		dash(e.User),
+		e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		e.Method, e.Path, e.Proto,
+		e.Status,
+		dash(bytesField(e.Bytes)),
+		dash(e.Referer),
+		dash(e.UserAgent),
+	)
+}
+
+func bytesField(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return strings.ReplaceAll(s, `"`, `\"`)
+}
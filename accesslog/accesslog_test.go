@@ -0,0 +1,152 @@
+package accesslog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rekib0023/event-horizon-gateway/accesslog"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	pb "github.com/rekib0023/event-horizon-gateway/proto"
+	"google.golang.org/grpc"
+)
+
+func engine(t *testing.T, cfg config.AccessLog, handlers ...gin.HandlerFunc) (*gin.Engine, *bytes.Buffer) {
+	t.Helper()
+	var buf bytes.Buffer
+	l, err := accesslog.New(&buf, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(l.Middleware())
+	ok := func(c *gin.Context) { c.String(http.StatusOK, "ok") }
+	if len(handlers) > 0 {
+		ok = handlers[0]
+	}
+	e.GET("/health", ok)
+	e.GET("/events/:eventId", func(c *gin.Context) {
+		if c.Param("eventId") == "missing" {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.Status(http.StatusServiceUnavailable)
+	})
+	return e, &buf
+}
+
+func TestSampling(t *testing.T) {
+	e, buf := engine(t, config.AccessLog{Format: accesslog.FormatTemplate, Template: "{{.Method}} {{.Path}} {{.Status}}", Sampling: []config.AccessLogSampling{
+		{Route: "GET /health", Rate: 0},
+		{Route: "GET /events/:eventId", Status: "404", Rate: 0},
+		{Route: "*", Status: "5xx", Rate: 1},
+		{Route: "*", Rate: 0},
+	}})
+
+	for _, path := range []string{"/health", "/events/missing", "/events/7", "/nowhere"} {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	if got := buf.String(); got != "GET /events/7 503\n" {
+		t.Fatalf("access log = %q", got)
+	}
+}
+
+func TestSamplingRejectsBadRate(t *testing.T) {
+	_, err := accesslog.New(&bytes.Buffer{}, config.AccessLog{Sampling: []config.AccessLogSampling{{Route: "*", Rate: 1.5}}})
+	if err == nil {
+		t.Fatal("accepted a rate above 1")
+	}
+}
+
+type sleepTransport time.Duration
+
+func (d sleepTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	time.Sleep(time.Duration(d))
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+}
+
+func TestLatencyBreakdown(t *testing.T) {
+	const step = 20 * time.Millisecond
+	client := &http.Client{Transport: accesslog.Transport(sleepTransport(step))}
+	interceptor := accesslog.UnaryClientInterceptor()
+
+	e, buf := engine(t, config.AccessLog{Format: accesslog.FormatJSON}, func(c *gin.Context) {
+		ctx := c.Request.Context()
+		sleep := func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+			time.Sleep(step)
+			return nil
+		}
+		// Auth: one gRPC call. Upstream: one gRPC call and two HTTP calls.
+		interceptor(accesslog.WithAuthPhase(ctx), "/auth.AuthService/Verify", nil, nil, nil, sleep)
+		interceptor(ctx, "/auth.AuthService/GetUser", nil, nil, nil, sleep)
+		for i := 0; i < 2; i++ {
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://events.invalid/events", nil)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}
+		time.Sleep(step)
+		c.Set("user", &pb.TokenVerification{Id: "42"})
+		c.String(http.StatusOK, "done")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/health?verbose=1", nil)
+	req.Header.Set("User-Agent", "probe")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry struct {
+		Path              string  `json:"path"`
+		Route             string  `json:"route"`
+		Status            int     `json:"status"`
+		Bytes             int     `json:"bytes"`
+		User              string  `json:"user"`
+		UserAgent         string  `json:"user_agent"`
+		LatencyMs         float64 `json:"latency_ms"`
+		AuthLatencyMs     float64 `json:"auth_latency_ms"`
+		UpstreamLatencyMs float64 `json:"upstream_latency_ms"`
+		GatewayLatencyMs  float64 `json:"gateway_latency_ms"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("%v: %s", err, buf)
+	}
+	if entry.Path != "/health?verbose=1" || entry.Route != "/health" || entry.Status != 200 || entry.Bytes != 4 || entry.User != "42" || entry.UserAgent != "probe" {
+		t.Fatalf("entry = %+v", entry)
+	}
+
+	ms := float64(step.Milliseconds())
+	if entry.AuthLatencyMs < ms || entry.AuthLatencyMs >= entry.UpstreamLatencyMs {
+		t.Errorf("auth latency = %vms, want one step", entry.AuthLatencyMs)
+	}
+	if entry.UpstreamLatencyMs < 3*ms {
+		t.Errorf("upstream latency = %vms, want three steps", entry.UpstreamLatencyMs)
+	}
+	if entry.GatewayLatencyMs < ms {
+		t.Errorf("gateway latency = %vms, want at least one step", entry.GatewayLatencyMs)
+	}
+	sum := entry.AuthLatencyMs + entry.UpstreamLatencyMs + entry.GatewayLatencyMs
+	if diff := sum - entry.LatencyMs; diff > 0.01 || diff < -0.01 {
+		t.Errorf("breakdown sums to %vms, total %vms", sum, entry.LatencyMs)
+	}
+}
+
+func TestCombinedFormat(t *testing.T) {
+	e, buf := engine(t, config.AccessLog{})
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.Header.Set("User-Agent", `say "hi"`)
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	line := buf.String()
+	if !strings.HasPrefix(line, "192.0.2.1 - - [") || !strings.HasSuffix(line, `] "GET /health HTTP/1.1" 200 2 "-" "say \"hi\""`+"\n") {
+		t.Fatalf("combined line = %q", line)
+	}
+}
@@ -0,0 +1,80 @@
+package accesslog
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+type timings struct {
+	mu       sync.Mutex
+	auth     time.Duration
+	upstream time.Duration
+}
+
+func (t *timings) totals() (time.Duration, time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.auth, t.upstream
+}
+
+type timingsKey struct{}
+type authPhaseKey struct{}
+
+func withTimings(ctx context.Context, t *timings) context.Context {
+	return context.WithValue(ctx, timingsKey{}, t)
+}
+
+// WithAuthPhase marks upstream calls made with ctx as part of request
+// authentication, so they count towards the auth latency instead of the
+// upstream latency.
+func WithAuthPhase(ctx context.Context) context.Context {
+	return context.WithValue(ctx, authPhaseKey{}, true)
+}
+
+func record(ctx context.Context, d time.Duration) {
+	t, ok := ctx.Value(timingsKey{}).(*timings)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if auth, _ := ctx.Value(authPhaseKey{}).(bool); auth {
+		t.auth += d
+	} else {
+		t.upstream += d
+	}
+}
+
+// UnaryClientInterceptor attributes gRPC call latency to the request.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		record(ctx, time.Since(start))
+		return err
+	}
+}
+
+// Transport attributes HTTP upstream latency, up to the response headers,
+// to the request.
+func Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := next.RoundTrip(req)
+		record(req.Context(), time.Since(start))
+		return resp, err
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
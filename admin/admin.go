@@ -0,0 +1,256 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"net/url"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/health"
+	"github.com/rekib0023/event-horizon-gateway/logging"
+	"github.com/rekib0023/event-horizon-gateway/problem"
+)
+
+// Route describes one registered gateway route and the handlers it runs,
+// engine middleware first.
+type Route struct {
+	Method   string   `json:"method"`
+	Path     string   `json:"path"`
+	Handlers []string `json:"handlers"`
+}
+
+// Upstream describes one upstream service and its last health check.
+type Upstream struct {
+	Name    string                   `json:"name"`
+	Address string                   `json:"address"`
+	State   string                   `json:"state,omitempty"`
+	Health  *health.DependencyStatus `json:"health,omitempty"`
+}
+
+type CacheStats struct {
+	Entries   int        `json:"entries"`
+	Hits      uint64     `json:"hits"`
+	Misses    uint64     `json:"misses"`
+	TTL       string     `json:"ttl"`
+	FetchedAt *time.Time `json:"fetched_at,omitempty"`
+}
+
+// Cache is a gateway cache that can be inspected and flushed.
+type Cache interface {
+	Stats() CacheStats
+	Flush()
+}
+
+// Server exposes internal gateway state. It is served on its own listener
+// and must never be mounted on the public engine.
+type Server struct {
+	Config    *config.Config
+	LogLevel  *slog.LevelVar
+	Routes    func() []Route
+	Upstreams func() []Upstream
+	Caches    map[string]Cache
+}
+
+func (s *Server) Handler() http.Handler {
+	r := gin.New()
+	r.Use(gin.Recovery(), s.authorize())
+
+	r.GET("/config", s.config)
+	r.GET("/routes", s.routes)
+	r.GET("/upstreams", s.upstreams)
+	r.GET("/caches", s.caches)
+	r.DELETE("/caches/:name", s.flushCache)
+	r.GET("/ratelimits/:key", s.rateLimit)
+	r.GET("/loglevel", s.logLevel)
+	r.PUT("/loglevel", s.setLogLevel)
+
+	r.GET("/debug/pprof/", gin.WrapF(pprof.Index))
+	r.GET("/debug/pprof/cmdline", gin.WrapF(pprof.Cmdline))
+	r.GET("/debug/pprof/profile", gin.WrapF(pprof.Profile))
+	r.GET("/debug/pprof/symbol", gin.WrapF(pprof.Symbol))
+	r.POST("/debug/pprof/symbol", gin.WrapF(pprof.Symbol))
+	r.GET("/debug/pprof/trace", gin.WrapF(pprof.Trace))
+	r.GET("/debug/pprof/:profile", func(c *gin.Context) {
+		pprof.Handler(c.Param("profile")).ServeHTTP(c.Writer, c.Request)
+	})
+	return r
+}
+
+// Serve runs the admin server until ctx is done. Without a token it only
+// listens on loopback addresses.
+func (s *Server) Serve(ctx context.Context) error {
+	cfg := s.Config.Admin
+	if cfg.Token == "" && !isLoopback(cfg.Bind) {
+		return errors.New("admin: a token is required to bind to " + cfg.Bind)
+	}
+
+	srv := &http.Server{Addr: net.JoinHostPort(cfg.Bind, cfg.Port), Handler: s.Handler()}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	slog.Info("Serving admin API", "address", srv.Addr)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// HandlerNames names handlers the way they appear in the route table.
+func HandlerNames(handlers []gin.HandlerFunc) []string {
+	names := make([]string, len(handlers))
+	for i, h := range handlers {
+		name := runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name()
+		name = strings.TrimPrefix(name, "github.com/rekib0023/event-horizon-gateway/")
+		names[i] = strings.TrimSuffix(name, "-fm")
+	}
+	return names
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *Server) authorize() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := s.Config.Admin.Token
+		if token == "" {
+			return
+		}
+		given, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			p := problem.New(http.StatusUnauthorized, "Missing or invalid admin token")
+			p.Headers = http.Header{"WWW-Authenticate": {"Bearer"}}
+			problem.Abort(c, p)
+		}
+	}
+}
+
+// config renders the effective configuration with secrets redacted.
+func (s *Server) config(c *gin.Context) {
+	raw, err := json.Marshal(s.Config)
+	if err != nil {
+		problem.Abort(c, problem.New(http.StatusInternalServerError, err.Error()))
+		return
+	}
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		problem.Abort(c, problem.New(http.StatusInternalServerError, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, redact(doc))
+}
+
+// secretURLs are config keys whose URLs are credentials in themselves,
+// such as webhooks with a token in the path. Only their origin is shown.
+var secretURLs = map[string]bool{
+	"webhookURL": true,
+}
+
+func redact(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			s, ok := value.(string)
+			if ok && s != "" && logging.IsSensitive(key) {
+				v[key] = "[REDACTED]"
+				continue
+			}
+			if ok && s != "" && secretURLs[key] {
+				v[key] = "[REDACTED]"
+				if u, err := url.Parse(s); err == nil && u.Host != "" {
+					v[key] = u.Scheme + "://" + u.Host + "/[REDACTED]"
+				}
+				continue
+			}
+			v[key] = redact(value)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = redact(v[i])
+		}
+	case string:
+		if u, err := url.Parse(v); err == nil && u.Host != "" && (u.User != nil || u.RawQuery != "") {
+			query := u.Query()
+			for key := range query {
+				query.Set(key, "REDACTED")
+			}
+			u.RawQuery = query.Encode()
+			v = u.Redacted()
+		}
+		return logging.Scrub(v)
+	}
+	return v
+}
+
+func (s *Server) routes(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"routes": s.Routes()})
+}
+
+func (s *Server) upstreams(c *gin.Context) {
+	// The gateway has no circuit breakers yet; health and connection state
+	// are all there is to report.
+	c.JSON(http.StatusOK, gin.H{"upstreams": s.Upstreams()})
+}
+
+func (s *Server) caches(c *gin.Context) {
+	stats := make(map[string]CacheStats, len(s.Caches))
+	for name, cache := range s.Caches {
+		stats[name] = cache.Stats()
+	}
+	c.JSON(http.StatusOK, gin.H{"caches": stats})
+}
+
+func (s *Server) flushCache(c *gin.Context) {
+	cache, ok := s.Caches[c.Param("name")]
+	if !ok {
+		problem.Abort(c, problem.New(http.StatusNotFound, "No cache named "+c.Param("name")))
+		return
+	}
+	cache.Flush()
+	slog.Info("Cache flushed", "cache", c.Param("name"))
+	c.Status(http.StatusNoContent)
+}
+
+func (s *Server) rateLimit(c *gin.Context) {
+	problem.Abort(c, problem.New(http.StatusNotImplemented, "Rate limiting is not enabled on this gateway"))
+}
+
+func (s *Server) logLevel(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"level": s.LogLevel.Level().String()})
+}
+
+func (s *Server) setLogLevel(c *gin.Context) {
+	var body struct {
+		Level string `json:"level" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		problem.Abort(c, problem.FromBindError(err))
+		return
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(body.Level)); err != nil {
+		problem.Abort(c, problem.New(http.StatusBadRequest, "Unknown log level "+body.Level).WithFieldError("level", err.Error()))
+		return
+	}
+	previous := s.LogLevel.Level()
+	s.LogLevel.Set(level)
+	slog.Info("Log level changed", "from", previous.String(), "to", level.String())
+	c.JSON(http.StatusOK, gin.H{"level": level.String()})
+}
@@ -0,0 +1,66 @@
+package admin_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rekib0023/event-horizon-gateway/admin"
+	"github.com/rekib0023/event-horizon-gateway/config"
+)
+
+func TestConfigRedactsSecrets(t *testing.T) {
+	cfg := &config.Config{
+		EventService:  "http://events.internal/?api_key=key-secret",
+		Admin:         config.Admin{Token: "admin-secret"},
+		Notifications: config.Notifications{Token: "notify-secret"},
+		Audit:         config.Audit{WebhookURL: "https://hooks.example.com/services/T0/B0/hook-secret?sig=query-secret"},
+	}
+	handler := (&admin.Server{Config: cfg}).Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/config", nil)
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	body := rec.Body.String()
+	for _, secret := range []string{"admin-secret", "notify-secret", "hook-secret", "query-secret", "key-secret"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("config shows %s: %s", secret, body)
+		}
+	}
+	if !strings.Contains(body, "https://hooks.example.com/[REDACTED]") {
+		t.Errorf("config hides the webhook host: %s", body)
+	}
+}
+
+func TestAuthorizeRequiresBearerToken(t *testing.T) {
+	cfg := &config.Config{Admin: config.Admin{Token: "admin-secret"}}
+	handler := (&admin.Server{Config: cfg}).Handler()
+
+	for header, want := range map[string]int{
+		"":                     http.StatusUnauthorized,
+		"admin-secret":         http.StatusUnauthorized,
+		"Basic admin-secret":   http.StatusUnauthorized,
+		"Bearer admin-secre":   http.StatusUnauthorized,
+		"Bearer admin-secret ": http.StatusUnauthorized,
+		"Bearer admin-secret":  http.StatusOK,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/config", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("Authorization %q: status = %d, want %d", header, rec.Code, want)
+		}
+		if want == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") != "Bearer" {
+			t.Errorf("Authorization %q: no WWW-Authenticate challenge", header)
+		}
+	}
+}
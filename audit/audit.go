@@ -0,0 +1,156 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	pb "github.com/rekib0023/event-horizon-gateway/proto"
+)
+
+// Outcomes of an audited action.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// ActorKey is the gin context key handlers use to name the actor of an
+// unauthenticated action, such as the email of a login attempt.
+const ActorKey = "audit.actor"
+
+// Event is one audit record: who did what, when, from where and with what
+// outcome.
+type Event struct {
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"`
+	Actor     string    `json:"actor,omitempty"`
+	Target    string    `json:"target,omitempty"`
+	Method    string    `json:"method"`
+	Outcome   string    `json:"outcome"`
+	Status    int       `json:"status"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+
+	// PrevHash and Hash chain records together in sinks that support tamper
+	// evidence.
+	PrevHash string `json:"prev_hash,omitempty"`
+	Hash     string `json:"hash,omitempty"`
+}
+
+// Sink persists audit events. Write is called from a single goroutine.
+// Queue-backed sinks implement this interface too.
+type Sink interface {
+	Write(ctx context.Context, e Event) error
+}
+
+// Recorder hands events to each sink on its own goroutine so that auditing
+// never blocks the request path and a slow sink, such as a webhook that is
+// down, does not hold up the others. When a sink's buffer is full events
+// are dropped for that sink and counted.
+type Recorder struct {
+	Now func() time.Time
+
+	queues []*queue
+	once   sync.Once
+}
+
+type queue struct {
+	sink    Sink
+	events  chan Event
+	dropped atomic.Int64
+	done    chan struct{}
+}
+
+func NewRecorder(bufferSize int, sinks ...Sink) *Recorder {
+	r := &Recorder{Now: time.Now}
+	for _, s := range sinks {
+		q := &queue{sink: s, events: make(chan Event, bufferSize), done: make(chan struct{})}
+		r.queues = append(r.queues, q)
+		go q.run()
+	}
+	return r
+}
+
+func (q *queue) run() {
+	defer close(q.done)
+	for e := range q.events {
+		if err := q.sink.Write(context.Background(), e); err != nil {
+			slog.Error("could not write audit event", "action", e.Action, "error", err)
+		}
+	}
+}
+
+func (r *Recorder) Record(e Event) {
+	for _, q := range r.queues {
+		select {
+		case q.events <- e:
+		default:
+			q.dropped.Add(1)
+			slog.Warn("audit buffer full, event dropped", "sink", fmt.Sprintf("%T", q.sink), "action", e.Action, "target", e.Target)
+		}
+	}
+}
+
+// Dropped returns the number of events discarded because a sink's buffer
+// was full, summed over sinks.
+func (r *Recorder) Dropped() int64 {
+	var n int64
+	for _, q := range r.queues {
+		n += q.dropped.Load()
+	}
+	return n
+}
+
+// Close stops accepting events and waits until buffered events are written
+// or ctx expires.
+func (r *Recorder) Close(ctx context.Context) error {
+	r.once.Do(func() {
+		for _, q := range r.queues {
+			close(q.events)
+		}
+	})
+	for _, q := range r.queues {
+		select {
+		case <-q.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Middleware records action once the rest of the chain has run. It should
+// precede authentication so that rejected attempts are audited as well.
+func (r *Recorder) Middleware(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		status := c.Writer.Status()
+		e := Event{
+			Time:      r.Now().UTC(),
+			Action:    action,
+			Actor:     c.GetString(ActorKey),
+			Target:    c.Request.URL.Path,
+			Method:    c.Request.Method,
+			Outcome:   OutcomeSuccess,
+			Status:    status,
+			IP:        c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+			RequestID: c.GetString("requestId"),
+		}
+		if v, ok := c.Get("user"); ok {
+			if user, ok := v.(*pb.TokenVerification); ok {
+				e.Actor = user.GetId()
+			}
+		}
+		if status >= 400 {
+			e.Outcome = OutcomeFailure
+		}
+		r.Record(e)
+	}
+}
@@ -0,0 +1,79 @@
+package audit_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rekib0023/event-horizon-gateway/audit"
+)
+
+type memorySink struct {
+	mu     sync.Mutex
+	events []audit.Event
+}
+
+func (s *memorySink) Write(ctx context.Context, e audit.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e)
+	return nil
+}
+
+func (s *memorySink) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.events)
+}
+
+// stuckSink blocks every write until release is closed, like a webhook
+// that does not answer.
+type stuckSink struct{ release chan struct{} }
+
+func (s stuckSink) Write(ctx context.Context, e audit.Event) error {
+	<-s.release
+	return nil
+}
+
+func TestRecorderWritesEverySink(t *testing.T) {
+	a, b := &memorySink{}, &memorySink{}
+	r := audit.NewRecorder(10, a, b)
+	for i := 0; i < 5; i++ {
+		r.Record(audit.Event{Action: "user.update"})
+	}
+	if err := r.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if a.len() != 5 || b.len() != 5 || r.Dropped() != 0 {
+		t.Fatalf("wrote %d and %d events, dropped %d", a.len(), b.len(), r.Dropped())
+	}
+}
+
+func TestRecorderSlowSinkDoesNotBlockOthers(t *testing.T) {
+	stuck := stuckSink{release: make(chan struct{})}
+	fast := &memorySink{}
+	r := audit.NewRecorder(2, stuck, fast)
+	for i := 0; i < 10; i++ {
+		r.Record(audit.Event{Action: "user.update"})
+		time.Sleep(time.Millisecond)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for fast.len() < 10 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if fast.len() != 10 {
+		t.Fatalf("fast sink got %d events, want 10", fast.len())
+	}
+	if r.Dropped() == 0 {
+		t.Fatalf("stuck sink dropped no events")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := r.Close(ctx); err == nil {
+		t.Fatalf("Close returned before the stuck sink drained")
+	}
+	close(stuck.release)
+}
@@ -0,0 +1,60 @@
+package audit_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rekib0023/event-horizon-gateway/audit"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/gatewaytest"
+)
+
+// auditedIP creates an event through a gateway trusting proxies and
+// returns the IP its audit record names.
+func auditedIP(t *testing.T, proxies []string) string {
+	file := filepath.Join(t.TempDir(), "audit.jsonl")
+	h := gatewaytest.New(t, func(cfg *config.Config) {
+		cfg.Audit.File = file
+		cfg.TrustedProxies = proxies
+	})
+	user := h.AddUser("ada@example.com")
+
+	req := gatewaytest.As(h.Request(http.MethodPost, "/api/events", map[string]any{"title": "Launch"}), user.GetToken())
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.9")
+	h.ExpectStatus(h.Do(req), http.StatusOK)
+	if err := h.Gateway.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e audit.Event
+	if err := json.Unmarshal([]byte(strings.TrimSpace(string(data))), &e); err != nil {
+		t.Fatalf("decode %q: %v", data, err)
+	}
+	if e.Action != "event.create" || e.Actor != fmt.Sprint(user.GetId()) {
+		t.Fatalf("event = %+v", e)
+	}
+	return e.IP
+}
+
+func TestAuditIgnoresForwardedForFromUntrustedPeers(t *testing.T) {
+	if ip := auditedIP(t, nil); ip != "192.0.2.1" {
+		t.Fatalf("ip = %q, want the peer address", ip)
+	}
+}
+
+func TestAuditTrustsConfiguredProxies(t *testing.T) {
+	if ip := auditedIP(t, []string{"192.0.2.0/24"}); ip != "203.0.113.9" {
+		t.Fatalf("ip = %q, want the forwarded address", ip)
+	}
+}
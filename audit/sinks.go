@@ -0,0 +1,188 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+// FileSink appends events as JSON lines. Each record carries the SHA-256 of
+// the previous record's hash and its own content, so that edits or
+// deletions break the chain.
+type FileSink struct {
+	file     *os.File
+	prevHash string
+}
+
+func OpenFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	prev, err := lastHash(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("read audit log %s: %w", path, err)
+	}
+	return &FileSink{file: f, prevHash: prev}, nil
+}
+
+// lastHash returns the hash of the last record in f. A final line without
+// its newline was torn by a crash during Write: it is cut off, with a
+// warning, so that the chain continues from the last complete record.
+func lastHash(f *os.File) (string, error) {
+	var last Event
+	var offset int64
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(bytes.TrimSpace(line)) > 0 {
+				return repairTail(f, offset, line, last.Hash)
+			}
+			return last.Hash, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		offset += int64(len(line))
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(line, &last); err != nil {
+			return "", fmt.Errorf("record ending at byte %d: %w", offset, err)
+		}
+	}
+}
+
+// repairTail handles an unterminated last line starting at offset. A
+// complete record only lacks its newline; anything else is truncated.
+func repairTail(f *os.File, offset int64, tail []byte, prevHash string) (string, error) {
+	var e Event
+	if json.Unmarshal(tail, &e) == nil {
+		if _, err := f.Write([]byte{'\n'}); err != nil {
+			return "", err
+		}
+		return e.Hash, nil
+	}
+	slog.Warn("truncating torn record at the end of the audit log", "file", f.Name(), "offset", offset, "bytes", len(tail))
+	if err := f.Truncate(offset); err != nil {
+		return "", err
+	}
+	return prevHash, nil
+}
+
+// ChainHash computes the hash of e chained to prevHash, ignoring the
+// event's own Hash field.
+func ChainHash(prevHash string, e Event) (string, error) {
+	e.PrevHash, e.Hash = prevHash, ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(prevHash), data...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (s *FileSink) Write(_ context.Context, e Event) error {
+	hash, err := ChainHash(s.prevHash, e)
+	if err != nil {
+		return err
+	}
+	e.PrevHash, e.Hash = s.prevHash, hash
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	s.prevHash = hash
+	return nil
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// Verify checks the hash chain of an audit log, returning the line number
+// of the first broken record.
+func Verify(r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	prev := ""
+	line := 0
+	for scanner.Scan() {
+		line++
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return line, err
+		}
+		hash, err := ChainHash(prev, e)
+		if err != nil {
+			return line, err
+		}
+		if e.PrevHash != prev || e.Hash != hash {
+			return line, fmt.Errorf("hash chain broken at line %d", line)
+		}
+		prev = e.Hash
+	}
+	return 0, scanner.Err()
+}
+
+// WebhookSink POSTs each event as JSON, retrying transient failures.
+type WebhookSink struct {
+	URL     string
+	Client  *http.Client
+	Retries int
+}
+
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{URL: url, Client: &http.Client{Timeout: 5 * time.Second}, Retries: 3}
+}
+
+func (s *WebhookSink) Write(ctx context.Context, e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= s.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * 500 * time.Millisecond)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := s.Client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 300 {
+			return nil
+		}
+		lastErr = fmt.Errorf("webhook responded %s", resp.Status)
+		if resp.StatusCode < 500 {
+			return lastErr
+		}
+	}
+	return lastErr
+}
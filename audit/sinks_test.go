@@ -0,0 +1,110 @@
+package audit_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rekib0023/event-horizon-gateway/audit"
+)
+
+// writeLog writes n chained events to a new audit log and returns its path.
+func writeLog(t *testing.T, n int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	appendEvents(t, path, n)
+	return path
+}
+
+func appendEvents(t *testing.T, path string, n int) {
+	t.Helper()
+	s, err := audit.OpenFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		if err := s.Write(context.Background(), audit.Event{Action: "user.update", Target: "1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func verify(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line, err := audit.Verify(bytes.NewReader(data)); err != nil {
+		t.Fatalf("line %d: %v", line, err)
+	}
+	return data
+}
+
+func TestFileSinkChainsAcrossReopen(t *testing.T) {
+	path := writeLog(t, 2)
+	appendEvents(t, path, 2)
+	if data := verify(t, path); bytes.Count(data, []byte("\n")) != 4 {
+		t.Fatalf("log = %s", data)
+	}
+}
+
+func TestFileSinkTruncatesTornRecord(t *testing.T) {
+	path := writeLog(t, 2)
+	intact := verify(t, path)
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"time":"2024-01-01T00:00:00Z","action":"user.upd`)
+	f.Close()
+
+	appendEvents(t, path, 1)
+	data := verify(t, path)
+	if !bytes.HasPrefix(data, intact) || bytes.Count(data, []byte("\n")) != 3 {
+		t.Fatalf("log = %s", data)
+	}
+}
+
+func TestFileSinkKeepsUnterminatedRecord(t *testing.T) {
+	path := writeLog(t, 2)
+	data := verify(t, path)
+	if err := os.WriteFile(path, bytes.TrimSuffix(data, []byte("\n")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	appendEvents(t, path, 1)
+	if data := verify(t, path); bytes.Count(data, []byte("\n")) != 3 {
+		t.Fatalf("log = %s", data)
+	}
+}
+
+func TestFileSinkRefusesCorruptRecord(t *testing.T) {
+	path := writeLog(t, 2)
+	data := verify(t, path)
+	lines := strings.SplitAfter(string(data), "\n")
+	corrupt := "garbage\n" + lines[1]
+	if err := os.WriteFile(path, []byte(corrupt), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := audit.OpenFileSink(path); err == nil {
+		t.Fatal("opened a log with a corrupt complete record")
+	}
+}
+
+func TestVerifyDetectsEdits(t *testing.T) {
+	path := writeLog(t, 3)
+	data := verify(t, path)
+	edited := bytes.Replace(data, []byte(`"target":"1"`), []byte(`"target":"2"`), 1)
+	line, err := audit.Verify(bytes.NewReader(edited))
+	if err == nil || line != 1 {
+		t.Fatalf("Verify = %d, %v; want a break at line 1", line, err)
+	}
+}
@@ -0,0 +1,258 @@
+// Package batch serves several API calls sent in one round-trip.
+package batch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/problem"
+)
+
+// credentials are taken from the batch request for every sub-request, so
+// that a batch cannot act as anyone but its caller.
+var credentials = []string{"Authorization", "Cookie"}
+
+// dropped headers do not apply to sub-requests: their bodies are sent and
+// answered uncompressed inside the batch, and they cannot be upgraded.
+var dropped = []string{"Accept-Encoding", "Content-Encoding", "Connection", "Upgrade"}
+
+type Request struct {
+	// ID names the request for DependsOn; it defaults to its index.
+	ID      string            `json:"id,omitempty"`
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+	// DependsOn lists requests that must succeed before this one runs.
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+type Response struct {
+	ID      string      `json:"id"`
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers,omitempty"`
+	// Body is embedded as is when it is JSON, and as a string otherwise.
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// Handler dispatches each sub-request to h, the gateway's own engine, so
+// that it passes the same middleware and authentication as if it had
+// been sent on its own. Responses come back in request order.
+func Handler(h http.Handler, cfg config.Batch) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var reqs []Request
+		if err := c.ShouldBindJSON(&reqs); err != nil {
+			problem.Abort(c, problem.FromBindError(err))
+			return
+		}
+		deps, p := plan(c, reqs, cfg)
+		if p != nil {
+			problem.Abort(c, p)
+			return
+		}
+
+		res := make([]Response, len(reqs))
+		done := make([]chan struct{}, len(reqs))
+		for i := range done {
+			done[i] = make(chan struct{})
+		}
+		sem := make(chan struct{}, max(cfg.Concurrency, 1))
+		var wg sync.WaitGroup
+		for i := range reqs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				defer close(done[i])
+				for _, d := range deps[i] {
+					<-done[d]
+					if res[d].Status >= 400 {
+						res[i] = failed(reqs[i], problem.New(http.StatusFailedDependency, "Depends on "+reqs[d].ID+", which failed"))
+						return
+					}
+				}
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				res[i] = dispatch(c, h, reqs[i], cfg)
+			}(i)
+		}
+		wg.Wait()
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+// plan checks reqs and returns the indexes each one depends on.
+func plan(c *gin.Context, reqs []Request, cfg config.Batch) ([][]int, *problem.Problem) {
+	if len(reqs) == 0 {
+		return nil, problem.New(http.StatusBadRequest, "A batch needs at least one request")
+	}
+	if cfg.MaxRequests > 0 && len(reqs) > cfg.MaxRequests {
+		return nil, problem.New(http.StatusBadRequest, fmt.Sprintf("A batch holds at most %d requests", cfg.MaxRequests))
+	}
+
+	index := map[string]int{}
+	for i := range reqs {
+		r := &reqs[i]
+		if r.ID == "" {
+			r.ID = strconv.Itoa(i)
+		}
+		if _, ok := index[r.ID]; ok {
+			return nil, problem.New(http.StatusBadRequest, "Duplicate request id "+r.ID).WithFieldError(fmt.Sprintf("[%d].id", i), "must be unique")
+		}
+		index[r.ID] = i
+
+		r.Method = strings.ToUpper(r.Method)
+		if r.Method == "" {
+			r.Method = http.MethodGet
+		}
+		u, err := url.Parse(r.Path)
+		if err != nil || u.IsAbs() || !strings.HasPrefix(path.Clean(u.Path), "/api/") || path.Clean(u.Path) == c.Request.URL.Path {
+			return nil, problem.New(http.StatusBadRequest, "Invalid request path").WithFieldError(fmt.Sprintf("[%d].path", i), "must be an API path other than the batch endpoint")
+		}
+	}
+
+	deps := make([][]int, len(reqs))
+	for i, r := range reqs {
+		for _, id := range r.DependsOn {
+			d, ok := index[id]
+			if !ok || d == i {
+				return nil, problem.New(http.StatusBadRequest, "Unknown dependency "+id).WithFieldError(fmt.Sprintf("[%d].dependsOn", i), "must name another request")
+			}
+			deps[i] = append(deps[i], d)
+		}
+	}
+	if hasCycle(deps) {
+		return nil, problem.New(http.StatusBadRequest, "Requests depend on each other in a cycle")
+	}
+	return deps, nil
+}
+
+func hasCycle(deps [][]int) bool {
+	done := make([]bool, len(deps))
+	for finished := 0; finished < len(deps); {
+		progressed := false
+		for i, ds := range deps {
+			if done[i] {
+				continue
+			}
+			ready := true
+			for _, d := range ds {
+				ready = ready && done[d]
+			}
+			if ready {
+				done[i] = true
+				finished++
+				progressed = true
+			}
+		}
+		if !progressed {
+			return true
+		}
+	}
+	return false
+}
+
+func dispatch(c *gin.Context, h http.Handler, r Request, cfg config.Batch) Response {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	if timeout := cfg.Timeout.Std(); timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.Method, r.Path, bytes.NewReader(r.Body))
+	if err != nil {
+		return failed(r, problem.New(http.StatusBadRequest, "Invalid request"))
+	}
+	for key, value := range r.Headers {
+		req.Header.Set(key, value)
+	}
+	for _, key := range dropped {
+		req.Header.Del(key)
+	}
+	if len(r.Body) > 0 && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for _, key := range credentials {
+		req.Header.Del(key)
+		for _, v := range c.Request.Header.Values(key) {
+			req.Header.Add(key, v)
+		}
+	}
+	req.Host = c.Request.Host
+	req.RemoteAddr = c.Request.RemoteAddr
+
+	rec := &recorder{header: http.Header{}, cancel: cancel}
+	h.ServeHTTP(rec, req)
+	if rec.streaming {
+		return failed(r, problem.New(http.StatusBadRequest, "Event streams cannot be batched"))
+	}
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return Response{ID: r.ID, Status: rec.status, Headers: rec.header, Body: body(rec.body.Bytes())}
+}
+
+func failed(r Request, p *problem.Problem) Response {
+	p.Instance = r.Path
+	data, _ := json.Marshal(p)
+	return Response{
+		ID:      r.ID,
+		Status:  p.Status,
+		Headers: http.Header{"Content-Type": {problem.ContentType}},
+		Body:    data,
+	}
+}
+
+func body(data []byte) json.RawMessage {
+	if len(data) == 0 {
+		return nil
+	}
+	if json.Valid(data) {
+		return data
+	}
+	quoted, _ := json.Marshal(string(data))
+	return quoted
+}
+
+// recorder buffers a sub-response. A handler flushing an event stream is
+// cancelled, since the batch could only answer once the stream ends.
+type recorder struct {
+	header    http.Header
+	status    int
+	body      bytes.Buffer
+	cancel    context.CancelFunc
+	streaming bool
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(p)
+}
+
+func (r *recorder) Flush() {
+	if mediaType, _, _ := mime.ParseMediaType(r.header.Get("Content-Type")); mediaType == "text/event-stream" {
+		r.streaming = true
+		r.cancel()
+	}
+}
@@ -0,0 +1,92 @@
+package batch_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rekib0023/event-horizon-gateway/gatewaytest"
+)
+
+type response struct {
+	ID      string          `json:"id"`
+	Status  int             `json:"status"`
+	Headers http.Header     `json:"headers"`
+	Body    json.RawMessage `json:"body"`
+}
+
+func TestBatch(t *testing.T) {
+	h := gatewaytest.New(t, nil)
+	user := h.AddUser("ada@example.com")
+	h.Events.Handle(http.MethodGet, "/events/8", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such event", http.StatusNotFound)
+	})
+
+	rec := h.DoAs(user, http.MethodPost, "/api/batch", []map[string]any{
+		{"id": "me", "path": fmt.Sprintf("/api/users/%d", user.Id)},
+		{"id": "event", "path": "/api/events/7", "dependsOn": []string{"me"}, "headers": map[string]string{"Accept-Encoding": "gzip"}},
+		{"id": "missing", "path": "/api/events/8"},
+		{"id": "after", "path": "/api/events/9", "dependsOn": []string{"missing"}},
+	})
+	h.ExpectStatus(rec, http.StatusOK)
+	var res []response
+	h.Decode(rec, &res)
+	if len(res) != 4 {
+		t.Fatalf("responses = %+v", res)
+	}
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusNotFound, http.StatusFailedDependency} {
+		if res[i].Status != want {
+			t.Fatalf("%s: status = %d, want %d; body %s", res[i].ID, res[i].Status, want, res[i].Body)
+		}
+	}
+	var event map[string]any
+	if err := json.Unmarshal(res[1].Body, &event); err != nil || event["path"] != "/events/7" {
+		t.Fatalf("event body = %s, want uncompressed JSON", res[1].Body)
+	}
+	if res[1].Headers.Get("Content-Encoding") != "" {
+		t.Fatalf("sub-response is encoded: %v", res[1].Headers)
+	}
+	h.ExpectProxiedHeader("X-User-ID", fmt.Sprint(user.Id))
+}
+
+func TestBatchRejectsInvalidPlans(t *testing.T) {
+	h := gatewaytest.New(t, nil)
+	user := h.AddUser("ada@example.com")
+
+	for name, reqs := range map[string][]map[string]any{
+		"empty":     {},
+		"cycle":     {{"id": "a", "path": "/api/events/1", "dependsOn": []string{"b"}}, {"id": "b", "path": "/api/events/2", "dependsOn": []string{"a"}}},
+		"recursive": {{"path": "/api/batch"}},
+		"external":  {{"path": "https://example.com/api/events"}},
+		"unknown":   {{"path": "/api/events/1", "dependsOn": []string{"nope"}}},
+	} {
+		rec := h.DoAs(user, http.MethodPost, "/api/batch", reqs)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400; body %s", name, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestBatchRefusesEventStreams(t *testing.T) {
+	h := gatewaytest.New(t, nil)
+	user := h.AddUser("ada@example.com")
+
+	start := time.Now()
+	rec := h.DoAs(user, http.MethodPost, "/api/batch", []map[string]any{
+		{"path": "/api/notifications"},
+		{"path": "/api/events/7/notifications"},
+	})
+	h.ExpectStatus(rec, http.StatusOK)
+	var res []response
+	h.Decode(rec, &res)
+	for _, r := range res {
+		if r.Status != http.StatusBadRequest {
+			t.Fatalf("%s: status = %d, want 400; body %s", r.ID, r.Status, r.Body)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Duration(h.Config.Batch.Timeout)/2 {
+		t.Fatalf("streams were refused after %v, want at once", elapsed)
+	}
+}
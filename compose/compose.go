@@ -0,0 +1,378 @@
+// Package compose serves endpoints assembled from several upstream calls.
+package compose
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/logging"
+	"github.com/rekib0023/event-horizon-gateway/metrics"
+	"github.com/rekib0023/event-horizon-gateway/problem"
+	pb "github.com/rekib0023/event-horizon-gateway/proto"
+	"github.com/rekib0023/event-horizon-gateway/render"
+	"github.com/rekib0023/event-horizon-gateway/tracing"
+	"github.com/rekib0023/event-horizon-gateway/transcoder"
+	"github.com/rekib0023/event-horizon-gateway/users"
+	"github.com/rekib0023/event-horizon-gateway/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Keys added to a composite document when calls failed.
+const (
+	PartialKey = "_partial"
+	ErrorsKey  = "_errors"
+)
+
+const defaultTimeout = 5 * time.Second
+
+type Composer struct {
+	conn          grpc.ClientConnInterface
+	httpClient    *http.Client
+	eventService  string
+	statusMapping *utils.StatusMapping
+	renderer      *render.Renderer
+}
+
+func New(conn grpc.ClientConnInterface, httpClient *http.Client, eventService string, statusMapping *utils.StatusMapping, renderer *render.Renderer) *Composer {
+	return &Composer{
+		conn:          conn,
+		httpClient:    httpClient,
+		eventService:  eventService,
+		statusMapping: statusMapping,
+		renderer:      renderer,
+	}
+}
+
+// reference matches "{name}" and "{call.field.path}" in templates.
+var reference = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)((?:\.[A-Za-z0-9_]+)*)\}`)
+
+type step struct {
+	call    config.CompositeCall
+	method  *transcoder.Method
+	deps    []string
+	timeout time.Duration
+}
+
+// Handler builds the handler for route, checking its calls and their
+// references up front so that misconfigured composites fail at startup.
+func (m *Composer) Handler(route config.Composite) (gin.HandlerFunc, error) {
+	vars := map[string]bool{}
+	for _, match := range reference.FindAllStringSubmatch(route.Path, -1) {
+		vars[match[1]] = true
+	}
+	names := map[string]bool{}
+	for _, call := range route.Calls {
+		if call.Name == "" || strings.HasPrefix(call.Name, "_") || names[call.Name] {
+			return nil, fmt.Errorf("call names must be unique and not start with _, got %q", call.Name)
+		}
+		names[call.Name] = true
+	}
+
+	var steps []*step
+	for _, call := range route.Calls {
+		s := &step{call: call, timeout: call.Timeout.Std()}
+		if s.timeout <= 0 {
+			s.timeout = route.Timeout.Std()
+		}
+		if s.timeout <= 0 {
+			s.timeout = defaultTimeout
+		}
+
+		templates := []string{call.Path}
+		switch {
+		case call.Path != "" && call.RPC == "":
+		case call.RPC != "" && call.Path == "":
+			method, err := transcoder.ResolveMethod(call.RPC)
+			if err != nil {
+				return nil, fmt.Errorf("call %s: %w", call.Name, err)
+			}
+			for field, template := range call.Fields {
+				if err := method.CheckField(field); err != nil {
+					return nil, fmt.Errorf("call %s: %w", call.Name, err)
+				}
+				templates = append(templates, template)
+			}
+			s.method = method
+		default:
+			return nil, fmt.Errorf("call %s: exactly one of path and rpc is required", call.Name)
+		}
+
+		for _, template := range templates {
+			for _, match := range reference.FindAllStringSubmatch(template, -1) {
+				switch name := match[1]; {
+				case names[name] && name != call.Name:
+					s.deps = append(s.deps, name)
+				case vars[name] && match[2] == "":
+				default:
+					return nil, fmt.Errorf("call %s: unknown reference %s", call.Name, match[0])
+				}
+			}
+		}
+		steps = append(steps, s)
+	}
+	if err := checkCycles(steps); err != nil {
+		return nil, err
+	}
+
+	return func(c *gin.Context) { m.serve(c, steps) }, nil
+}
+
+func checkCycles(steps []*step) error {
+	done := map[string]bool{}
+	for len(done) < len(steps) {
+		progressed := false
+		for _, s := range steps {
+			if !done[s.call.Name] && all(s.deps, done) {
+				done[s.call.Name] = true
+				progressed = true
+			}
+		}
+		if !progressed {
+			return errors.New("calls reference each other in a cycle")
+		}
+	}
+	return nil
+}
+
+func all(names []string, set map[string]bool) bool {
+	for _, name := range names {
+		if !set[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// serve runs the calls in waves: each wave holds the calls whose
+// references have finished, run in parallel.
+func (m *Composer) serve(c *gin.Context, steps []*step) {
+	results := map[string]interface{}{}
+	failures := map[string]*problem.Problem{}
+	finished := map[string]bool{}
+
+	pending := steps
+	for len(pending) > 0 {
+		var ready, waiting []*step
+		for _, s := range pending {
+			if all(s.deps, finished) {
+				ready = append(ready, s)
+			} else {
+				waiting = append(waiting, s)
+			}
+		}
+
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for _, s := range ready {
+			wg.Add(1)
+			go func(s *step) {
+				defer wg.Done()
+				mu.Lock()
+				deps := map[string]interface{}{}
+				var failed *problem.Problem
+				for _, dep := range s.deps {
+					deps[dep] = results[dep]
+					if failures[dep] != nil {
+						failed = problem.New(http.StatusFailedDependency, "Depends on "+dep+", which failed")
+					}
+				}
+				mu.Unlock()
+
+				var result interface{}
+				if failed == nil {
+					result, failed = m.run(c, s, deps)
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				if failed != nil {
+					failures[s.call.Name] = failed
+				} else {
+					results[s.call.Name] = result
+				}
+			}(s)
+		}
+		wg.Wait()
+		for _, s := range ready {
+			finished[s.call.Name] = true
+		}
+		pending = waiting
+	}
+
+	doc := map[string]interface{}{}
+	for _, s := range steps {
+		if p := failures[s.call.Name]; p != nil && s.call.Required {
+			problem.Abort(c, p)
+			return
+		}
+		if v, ok := results[s.call.Name]; ok {
+			doc[s.call.Name] = v
+		}
+	}
+	if len(failures) > 0 {
+		doc[PartialKey] = true
+		doc[ErrorsKey] = failures
+	}
+	m.renderer.Value(c, http.StatusOK, doc)
+}
+
+func (m *Composer) run(c *gin.Context, s *step, deps map[string]interface{}) (interface{}, *problem.Problem) {
+	ctx, span := tracing.Start(c.Request.Context(), "compose "+s.call.Name, attribute.String("compose.call", s.call.Name))
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if s.method != nil {
+		fields := map[string]string{}
+		for field, template := range s.call.Fields {
+			v, p := expand(template, c, deps, false)
+			if p != nil {
+				return nil, p
+			}
+			fields[field] = v
+		}
+		return m.invoke(ctx, s, fields)
+	}
+
+	path, p := expand(s.call.Path, c, deps, true)
+	if p != nil {
+		return nil, p
+	}
+	return m.get(ctx, c, s, path)
+}
+
+func (m *Composer) invoke(ctx context.Context, s *step, fields map[string]string) (interface{}, *problem.Problem) {
+	req, err := s.method.NewRequest(fields)
+	if err != nil {
+		return nil, problem.New(http.StatusFailedDependency, "Invalid "+s.call.Name+" request: "+err.Error())
+	}
+	res := s.method.NewResponse()
+	if err := m.conn.Invoke(ctx, s.method.FullMethod, req, res); err != nil {
+		return nil, problem.FromGRPC(err, s.method.FullMethod, m.statusMapping)
+	}
+	// Composites show users to whoever may read the route, so only the
+	// public part of a profile is ever merged in.
+	if u, ok := res.(*pb.UserResponse); ok {
+		return users.PublicProfile(u), nil
+	}
+	return res, nil
+}
+
+func (m *Composer) get(ctx context.Context, c *gin.Context, s *step, path string) (interface{}, *problem.Problem) {
+	ctx = metrics.WithOperation(ctx, "GET "+transcoder.GinPath(s.call.Path))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.eventService+path, nil)
+	if err != nil {
+		return nil, problem.New(http.StatusInternalServerError, "Internal server error")
+	}
+	if v, ok := c.Get("user"); ok {
+		if user, ok := v.(*pb.TokenVerification); ok {
+			req.Header.Set("X-User-ID", user.Id)
+			req.Header.Set("X-User-Email", user.Email)
+		}
+	}
+
+	resp, err := m.httpClient.Do(req)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, problem.New(http.StatusGatewayTimeout, "Event service did not answer "+s.call.Name+" in time")
+	}
+	if err != nil {
+		logging.FromContext(ctx).Warn("upstream call failed", "upstream", "event-service", "error", err)
+		return nil, problem.New(http.StatusBadGateway, "Event service is unavailable")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, problem.FromUpstream(resp)
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, problem.New(http.StatusGatewayTimeout, "Event service did not answer "+s.call.Name+" in time")
+		}
+		logging.FromContext(ctx).Warn("could not decode upstream response", "upstream", "event-service", "error", err)
+		return nil, problem.New(http.StatusBadGateway, "Invalid response from event service")
+	}
+	return doc, nil
+}
+
+// expand fills in the references of template, escaping them as path
+// segments when escape is set.
+func expand(template string, c *gin.Context, results map[string]interface{}, escape bool) (string, *problem.Problem) {
+	var missing string
+	out := reference.ReplaceAllStringFunc(template, func(ref string) string {
+		match := reference.FindStringSubmatch(ref)
+		var value string
+		if result, ok := results[match[1]]; ok {
+			v, found := lookup(result, strings.Split(strings.TrimPrefix(match[2], "."), "."))
+			if !found || v == nil {
+				missing = strings.Trim(ref, "{}")
+				return ""
+			}
+			value = fmt.Sprint(v)
+		} else {
+			value = c.Param(match[1])
+		}
+		if escape {
+			return url.PathEscape(value)
+		}
+		return value
+	})
+	if missing != "" {
+		return "", problem.New(http.StatusFailedDependency, missing+" is missing")
+	}
+	return out, nil
+}
+
+// lookup walks path through a decoded JSON document or a protobuf message
+// rendered with its JSON names.
+func lookup(v interface{}, path []string) (interface{}, bool) {
+	if msg, ok := v.(proto.Message); ok {
+		data, err := protojson.Marshal(msg)
+		if err != nil {
+			return nil, false
+		}
+		dec := json.NewDecoder(strings.NewReader(string(data)))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return nil, false
+		}
+	}
+	for _, key := range path {
+		if key == "" {
+			continue
+		}
+		switch node := v.(type) {
+		case map[string]interface{}:
+			next, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			v = next
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
@@ -0,0 +1,69 @@
+package compose_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/rekib0023/event-horizon-gateway/compose"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/gatewaytest"
+)
+
+func TestEventDetails(t *testing.T) {
+	h := gatewaytest.New(t, func(cfg *config.Config) { cfg.AdminUsers = []string{"admin@example.com"} })
+	organizer := h.AddUser("org@example.com")
+	admin := h.AddUser("admin@example.com")
+	h.Events.Handle(http.MethodGet, "/events/7", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":7,"name":"Launch","organizerId":%d}`, organizer.Id)
+	})
+	h.Events.Handle(http.MethodGet, "/events/7/attendees", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"attendees":[]}`)
+	})
+
+	// Not even admins see the organizer's email through a composite.
+	for _, caller := range []struct{ name, token string }{{"organizer", organizer.GetToken()}, {"admin", admin.GetToken()}} {
+		rec := h.Do(gatewaytest.As(h.Request(http.MethodGet, "/api/events/7/details", nil), caller.token))
+		h.ExpectStatus(rec, http.StatusOK)
+		var doc map[string]any
+		h.Decode(rec, &doc)
+		org, _ := doc["organizer"].(map[string]any)
+		if org == nil || org["userName"] != "org@example.com" {
+			t.Fatalf("%s: organizer = %+v", caller.name, doc["organizer"])
+		}
+		if _, ok := org["email"]; ok {
+			t.Fatalf("%s: organizer email leaked: %+v", caller.name, org)
+		}
+		if doc["event"] == nil || doc["attendees"] == nil || doc[compose.PartialKey] != nil {
+			t.Fatalf("%s: details = %+v", caller.name, doc)
+		}
+	}
+}
+
+func TestEventDetailsFailures(t *testing.T) {
+	h := gatewaytest.New(t, nil)
+	user := h.AddUser("ada@example.com")
+	h.Events.Handle(http.MethodGet, "/events/7", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":7,"organizerId":%d}`, user.Id)
+	})
+	h.Events.Handle(http.MethodGet, "/events/7/attendees", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+	h.Events.Handle(http.MethodGet, "/events/8", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such event", http.StatusNotFound)
+	})
+
+	rec := h.DoAs(user, http.MethodGet, "/api/events/7/details", nil)
+	h.ExpectStatus(rec, http.StatusOK)
+	var doc map[string]any
+	h.Decode(rec, &doc)
+	errs, _ := doc[compose.ErrorsKey].(map[string]any)
+	if doc[compose.PartialKey] != true || errs["attendees"] == nil || doc["organizer"] == nil {
+		t.Fatalf("details = %+v", doc)
+	}
+
+	h.ExpectStatus(h.DoAs(user, http.MethodGet, "/api/events/8/details", nil), http.StatusNotFound)
+}
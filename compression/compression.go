@@ -0,0 +1,317 @@
+// Package compression negotiates compressed responses and decodes
+// compressed request bodies.
+package compression
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/limits"
+	"github.com/rekib0023/event-horizon-gateway/logging"
+	"github.com/rekib0023/event-horizon-gateway/problem"
+)
+
+var errUnsupported = errors.New("unsupported content encoding")
+
+// Middleware compresses responses with the best encoding the client
+// accepts once they reach cfg.MinSize, for the configured content types,
+// unless a handler already set Content-Encoding. Compressed request bodies
+// are decoded as they are read, bounded by cfg.MaxDecompressedBytes,
+// cfg.MaxRatio and the route's body limit.
+//
+// A compressed response keeps a strong ETag with the encoding appended,
+// e.g. "abc-gzip"; the suffix is removed from If-Match and If-None-Match
+// so that handlers compare their own tags.
+func Middleware(cfg config.Compression) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if encoding := c.GetHeader("Content-Encoding"); encoding != "" && c.Request.Body != nil {
+			if p := decodeBody(c, encoding, cfg); p != nil {
+				problem.Abort(c, p)
+				return
+			}
+		}
+
+		revalidated := ""
+		if h := c.Request.Header; h.Get("If-Match") != "" {
+			h.Set("If-Match", stripETags(h.Get("If-Match"), cfg.Encodings))
+		}
+		if h := c.Request.Header; h.Get("If-None-Match") != "" {
+			revalidated = strippedEncoding(h.Get("If-None-Match"), cfg.Encodings)
+			h.Set("If-None-Match", stripETags(h.Get("If-None-Match"), cfg.Encodings))
+		}
+
+		encoding := negotiate(c.GetHeader("Accept-Encoding"), cfg.Encodings)
+		if encoding == "" || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		w := &writer{ResponseWriter: c.Writer, cfg: cfg, encoding: encoding, revalidated: revalidated == encoding}
+		c.Writer = w
+		defer w.finish(c)
+		c.Next()
+	}
+}
+
+// decodeBody replaces the request body with one that decodes it as it is
+// read. Reading past a limit fails with an *http.MaxBytesError, which
+// handlers already answer with 413.
+func decodeBody(c *gin.Context, encoding string, cfg config.Compression) *problem.Problem {
+	req := c.Request
+	encoding = strings.ToLower(strings.TrimSpace(encoding))
+	if encoding == "identity" {
+		return nil
+	}
+
+	compressed := &countingReader{r: req.Body}
+	dec, err := newDecoder(encoding, compressed)
+	if errors.Is(err, errUnsupported) {
+		return problem.New(http.StatusUnsupportedMediaType, "Unsupported Content-Encoding "+encoding)
+	}
+	if err != nil {
+		if p := problem.FromBodyError(err); p != nil {
+			return p
+		}
+		return problem.New(http.StatusBadRequest, "Invalid "+encoding+" request body")
+	}
+
+	var body io.ReadCloser = &decodedBody{dec: dec, body: req.Body, compressed: compressed, cfg: cfg}
+	if limit := limits.MaxBodyBytes(c); limit > 0 {
+		body = http.MaxBytesReader(c.Writer, body, limit)
+	}
+	req.Body = body
+	req.ContentLength = -1
+	req.Header.Del("Content-Encoding")
+	req.Header.Del("Content-Length")
+	return nil
+}
+
+// decodedBody bounds a decoder by cfg.MaxDecompressedBytes and by
+// cfg.MaxRatio times the compressed bytes read so far.
+type decodedBody struct {
+	dec        io.ReadCloser
+	body       io.Closer
+	compressed *countingReader
+	cfg        config.Compression
+	n          int64
+}
+
+func (b *decodedBody) Read(p []byte) (int, error) {
+	n, err := b.dec.Read(p)
+	b.n += int64(n)
+	if limit := b.cfg.MaxDecompressedBytes; limit > 0 && b.n > limit {
+		return 0, &http.MaxBytesError{Limit: limit}
+	}
+	if ratio := int64(b.cfg.MaxRatio); ratio > 0 && b.n > ratio*max(b.compressed.n, 1) {
+		return 0, &http.MaxBytesError{Limit: ratio * b.compressed.n}
+	}
+	return n, err
+}
+
+func (b *decodedBody) Close() error {
+	b.dec.Close()
+	return b.body.Close()
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// writer holds the response back until it knows whether to compress:
+// once MinSize bytes are buffered, on Flush, or when the handler returns.
+type writer struct {
+	gin.ResponseWriter
+	cfg      config.Compression
+	encoding string
+
+	// revalidated is set when If-None-Match named this encoding's
+	// variant, so that a 304 names it too.
+	revalidated bool
+
+	status  int
+	buf     bytes.Buffer
+	decided bool
+	enc     encoder
+}
+
+func (w *writer) WriteHeader(code int) {
+	if w.decided {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+}
+
+func (w *writer) WriteHeaderNow() {
+	if !w.decided {
+		w.decide(false)
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *writer) Status() int {
+	if !w.decided && w.status != 0 {
+		return w.status
+	}
+	return w.ResponseWriter.Status()
+}
+
+func (w *writer) Written() bool {
+	return w.ResponseWriter.Written() || (!w.decided && (w.status != 0 || w.buf.Len() > 0))
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if !w.decided {
+		w.buf.Write(p)
+		if w.buf.Len() >= w.cfg.MinSize {
+			if err := w.decide(true); err != nil {
+				return 0, err
+			}
+		}
+		return len(p), nil
+	}
+	if w.enc != nil {
+		return w.enc.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the connection.
+func (w *writer) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *writer) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush commits to compressing regardless of size, so that streamed
+// responses are not held back.
+func (w *writer) Flush() {
+	if !w.decided {
+		w.decide(true)
+	}
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *writer) decide(compress bool) error {
+	if w.revalidated && w.Status() == http.StatusNotModified {
+		w.Header().Set("ETag", encodedETag(w.Header().Get("ETag"), w.encoding))
+	}
+	eligible := w.eligible()
+	w.decided = true
+	if eligible {
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
+	if compress && eligible {
+		h := w.Header()
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+		// A compressed representation is a different entity.
+		h.Set("ETag", encodedETag(h.Get("ETag"), w.encoding))
+		enc, err := newEncoder(w.encoding, w.ResponseWriter)
+		if err != nil {
+			return err
+		}
+		w.enc = enc
+	}
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	data := w.buf.Bytes()
+	w.buf = bytes.Buffer{}
+	if w.enc != nil {
+		_, err := w.enc.Write(data)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(data)
+	return err
+}
+
+func (w *writer) eligible() bool {
+	switch status := w.Status(); {
+	case status < 200, status == http.StatusNoContent, status == http.StatusNotModified, status == http.StatusPartialContent:
+		return false
+	}
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" || strings.Contains(h.Get("Cache-Control"), "no-transform") {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	for _, t := range w.cfg.ContentTypes {
+		if mediaType == t || (strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *writer) finish(c *gin.Context) {
+	if !w.decided {
+		w.decide(false)
+	}
+	if w.enc != nil {
+		if err := w.enc.Close(); err != nil {
+			logging.FromContext(c.Request.Context()).Warn("could not finish compressed response", "encoding", w.encoding, "error", err)
+		}
+	}
+}
+
+// encodedETag appends encoding to a strong ETag. Weak ETags already allow
+// for a different encoding and are left alone.
+func encodedETag(etag, encoding string) string {
+	if !strings.HasPrefix(etag, `"`) || !strings.HasSuffix(etag, `"`) || len(etag) < 2 {
+		return etag
+	}
+	return etag[:len(etag)-1] + "-" + encoding + `"`
+}
+
+// stripETags removes the suffixes added by encodedETag from a list of
+// entity tags such as an If-None-Match header.
+func stripETags(header string, encodings []string) string {
+	tags := strings.Split(header, ",")
+	for i, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		for _, encoding := range encodings {
+			if base, ok := strings.CutSuffix(tag, "-"+encoding+`"`); ok && strings.HasPrefix(tag, `"`) {
+				tag = base + `"`
+				break
+			}
+		}
+		tags[i] = tag
+	}
+	return strings.Join(tags, ", ")
+}
+
+// strippedEncoding returns the encoding named by the first tag in header
+// that stripETags would change, or "".
+func strippedEncoding(header string, encodings []string) string {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		for _, encoding := range encodings {
+			if strings.HasPrefix(tag, `"`) && strings.HasSuffix(tag, "-"+encoding+`"`) {
+				return encoding
+			}
+		}
+	}
+	return ""
+}
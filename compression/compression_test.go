@@ -0,0 +1,136 @@
+package compression_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/gatewaytest"
+)
+
+func gzipped(t *testing.T, data []byte) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func compressAll(cfg *config.Config) {
+	cfg.Compression.MinSize = 0
+}
+
+func TestCompressedResponse(t *testing.T) {
+	h := gatewaytest.New(t, compressAll)
+	user := h.AddUser("ada@example.com")
+
+	req := gatewaytest.As(h.Request(http.MethodGet, "/api/events/7", nil), user.GetToken())
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := h.Do(req)
+	h.ExpectStatus(rec, http.StatusOK)
+	if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), `"path":"/events/7"`) {
+		t.Fatalf("decoded body = %s", body)
+	}
+}
+
+func TestCompressedRequestBody(t *testing.T) {
+	h := gatewaytest.New(t, nil)
+	user := h.AddUser("ada@example.com")
+
+	req := gatewaytest.As(h.Request(http.MethodPost, "/api/events", gzipped(t, []byte(`{"name":"Launch"}`))), user.GetToken())
+	req.Header.Set("Content-Encoding", "gzip")
+	h.ExpectStatus(h.Do(req), http.StatusOK)
+
+	proxied, _ := h.Events.LastRequest()
+	if string(proxied.Body) != `{"name":"Launch"}` {
+		t.Fatalf("proxied body = %q", proxied.Body)
+	}
+	if got := proxied.Header.Get("Content-Encoding"); got != "" {
+		t.Fatalf("proxied Content-Encoding = %q", got)
+	}
+}
+
+func TestCompressedRequestBodyLimits(t *testing.T) {
+	t.Run("ratio", func(t *testing.T) {
+		h := gatewaytest.New(t, nil)
+		user := h.AddUser("ada@example.com")
+
+		req := gatewaytest.As(h.Request(http.MethodPost, "/api/events", gzipped(t, make([]byte, 1<<20))), user.GetToken())
+		req.Header.Set("Content-Encoding", "gzip")
+		h.ExpectStatus(h.Do(req), http.StatusRequestEntityTooLarge)
+	})
+
+	t.Run("route", func(t *testing.T) {
+		h := gatewaytest.New(t, func(cfg *config.Config) {
+			cfg.Compression.MaxRatio = 0
+			cfg.Limits.Routes = map[string]config.RouteLimit{"POST /api/events": {MaxBodyBytes: 1024}}
+		})
+		user := h.AddUser("ada@example.com")
+
+		req := gatewaytest.As(h.Request(http.MethodPost, "/api/events", gzipped(t, make([]byte, 4096))), user.GetToken())
+		req.Header.Set("Content-Encoding", "gzip")
+		rec := h.Do(req)
+		h.ExpectStatus(rec, http.StatusRequestEntityTooLarge)
+		if !strings.Contains(rec.Body.String(), "1024 bytes") {
+			t.Fatalf("body = %s, want the route limit", rec.Body.String())
+		}
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		h := gatewaytest.New(t, nil)
+		user := h.AddUser("ada@example.com")
+
+		req := gatewaytest.As(h.Request(http.MethodPost, "/api/events", strings.NewReader("{}")), user.GetToken())
+		req.Header.Set("Content-Encoding", "compress")
+		h.ExpectStatus(h.Do(req), http.StatusUnsupportedMediaType)
+	})
+}
+
+func TestCompressedETagStaysStrong(t *testing.T) {
+	h := gatewaytest.New(t, compressAll)
+	user := h.AddUser("ada@example.com")
+	path := fmt.Sprintf("/api/users/%d", user.Id)
+
+	req := gatewaytest.As(h.Request(http.MethodGet, path, nil), user.GetToken())
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := h.Do(req)
+	h.ExpectStatus(rec, http.StatusOK)
+	etag := rec.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `"`) || !strings.HasSuffix(etag, `-gzip"`) {
+		t.Fatalf("ETag = %q, want a strong gzip tag", etag)
+	}
+
+	req = gatewaytest.As(h.Request(http.MethodGet, path, nil), user.GetToken())
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("If-None-Match", etag)
+	rec = h.Do(req)
+	h.ExpectStatus(rec, http.StatusNotModified)
+	if got := rec.Header().Get("ETag"); got != etag {
+		t.Fatalf("304 ETag = %q, want %q", got, etag)
+	}
+
+	req = gatewaytest.As(h.Request(http.MethodPut, path, map[string]any{"firstName": "Ada"}), user.GetToken())
+	req.Header.Set("If-Match", etag)
+	h.ExpectStatus(h.Do(req), http.StatusOK)
+}
@@ -0,0 +1,100 @@
+package compression
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	Gzip   = "gzip"
+	Brotli = "br"
+	Zstd   = "zstd"
+)
+
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+}
+
+func newEncoder(encoding string, w io.Writer) (encoder, error) {
+	switch encoding {
+	case Gzip:
+		return gzip.NewWriter(w), nil
+	case Brotli:
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression), nil
+	case Zstd:
+		return zstd.NewWriter(w)
+	}
+	return nil, errUnsupported
+}
+
+func newDecoder(encoding string, r io.Reader) (io.ReadCloser, error) {
+	switch encoding {
+	case Gzip:
+		return gzip.NewReader(r)
+	case Brotli:
+		return io.NopCloser(brotli.NewReader(r)), nil
+	case Zstd:
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	}
+	return nil, errUnsupported
+}
+
+// negotiate picks the encoding from Accept-Encoding with the highest
+// q-value, breaking ties by the order of supported. It returns "" when
+// the response should stay identity-encoded.
+func negotiate(acceptEncoding string, supported []string) string {
+	type choice struct {
+		encoding string
+		q        float64
+		rank     int
+	}
+	q := map[string]float64{}
+	wildcard := -1.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		weight := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				weight = f
+			}
+		}
+		if name == "*" {
+			wildcard = weight
+		} else if name != "" {
+			q[name] = weight
+		}
+	}
+
+	var choices []choice
+	for rank, encoding := range supported {
+		weight, ok := q[encoding]
+		if !ok {
+			weight = wildcard
+		}
+		if weight > 0 {
+			choices = append(choices, choice{encoding, weight, rank})
+		}
+	}
+	if len(choices) == 0 {
+		return ""
+	}
+	sort.Slice(choices, func(i, j int) bool {
+		if choices[i].q != choices[j].q {
+			return choices[i].q > choices[j].q
+		}
+		return choices[i].rank < choices[j].rank
+	})
+	return choices[0].encoding
+}
@@ -0,0 +1,611 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	Port         string `json:"port"`
+	AuthService  string `json:"authService"`
+	EventService string `json:"eventService"`
+	Environment  string `json:"environment"`
+
+	// MetricsPort serves /metrics on a separate listener. When empty the
+	// endpoint is exposed on Port.
+	MetricsPort string `json:"metricsPort,omitempty"`
+
+	// GrpcStatusMapping overrides the google.rpc gRPC to HTTP status mapping
+	// per service or method, keyed by gRPC code name.
+	GrpcStatusMapping map[string]map[string]int `json:"grpcStatusMapping,omitempty"`
+
+	Render RenderOptions `json:"render"`
+
+	Tracing Tracing `json:"tracing"`
+
+	Logging Logging `json:"logging"`
+
+	AccessLog AccessLog `json:"accessLog"`
+
+	Audit Audit `json:"audit"`
+
+	Health Health `json:"health"`
+
+	Admin Admin `json:"admin"`
+
+	// ShutdownTimeout bounds graceful shutdown. DrainDelay keeps serving
+	// with /readyz failing before shutdown starts so that load balancers
+	// can stop routing to the gateway.
+	ShutdownTimeout Duration `json:"shutdownTimeout"`
+	DrainDelay      Duration `json:"drainDelay"`
+
+	// UsersSnapshotTTL is how long the gateway pages over a cached GetUsers
+	// result before fetching it again.
+	UsersSnapshotTTL Duration `json:"usersSnapshotTTL"`
+
+	ResponseCache ResponseCache `json:"responseCache"`
+
+	Compression Compression `json:"compression"`
+
+	Limits Limits `json:"limits"`
+
+	Live Live `json:"live"`
+
+	Notifications Notifications `json:"notifications"`
+
+	GraphQL GraphQL `json:"graphql"`
+
+	Attendees Attendees `json:"attendees"`
+
+	Batch Batch `json:"batch"`
+
+	// TrustedProxies lists the addresses or CIDR ranges whose
+	// X-Forwarded-For and X-Real-IP headers are believed when logging and
+	// auditing the client IP. With none, the peer address is used.
+	TrustedProxies []string `json:"trustedProxies,omitempty"`
+
+	// AdminUsers lists the user ids or emails granted the "admin" role.
+	AdminUsers []string `json:"adminUsers,omitempty"`
+
+	// Shaping declares response redaction rules, keyed by
+	// "METHOD /full/route/template" such as "GET /api/users/:userId".
+	Shaping map[string]ShapingRoute `json:"shaping,omitempty"`
+
+	// Routes exposes additional auth service RPCs over REST on top of the
+	// built-in route table.
+	Routes []Route `json:"routes,omitempty"`
+
+	// Composites declares endpoints merged from several upstream calls.
+	Composites []Composite `json:"composites,omitempty"`
+}
+
+type Logging struct {
+	// Level is debug, info, warn or error.
+	Level string `json:"level"`
+	// Format is "json" or "text".
+	Format string `json:"format"`
+}
+
+type AccessLog struct {
+	// Format is "combined" (Apache), "json" or "template".
+	Format string `json:"format"`
+	// Template is a text/template over accesslog.Entry for the "template"
+	// format.
+	Template string `json:"template,omitempty"`
+	// Output is "stdout", "off" or a file path. Files rotate at MaxSizeMB.
+	Output     string              `json:"output"`
+	MaxSizeMB  int                 `json:"maxSizeMB,omitempty"`
+	MaxBackups int                 `json:"maxBackups,omitempty"`
+	Sampling   []AccessLogSampling `json:"sampling,omitempty"`
+}
+
+// AccessLogSampling logs a fraction of the requests matching Route
+// ("METHOD /full/route/template" or "*") and Status ("2xx", "404", or empty
+// for any). The first matching rule applies; unmatched requests are always
+// logged.
+type AccessLogSampling struct {
+	Route  string  `json:"route"`
+	Status string  `json:"status,omitempty"`
+	Rate   float64 `json:"rate"`
+}
+
+type Health struct {
+	// AuthService is the service name sent in gRPC health checks; empty
+	// checks the server as a whole.
+	AuthService string `json:"authService"`
+	// EventProbePath is requested on the event service to check it.
+	EventProbePath string   `json:"eventProbePath"`
+	Timeout        Duration `json:"timeout"`
+	// CacheTTL is how long /readyz and /health/dependencies serve the last
+	// probe results before probing again.
+	CacheTTL Duration `json:"cacheTTL"`
+}
+
+// Limits bounds what clients may send. Routes override the body limits
+// per route, keyed by "METHOD /full/route/template" like Shaping.
+type Limits struct {
+	MaxBodyBytes   int64 `json:"maxBodyBytes"`
+	MaxHeaderBytes int   `json:"maxHeaderBytes"`
+	MaxHeaderCount int   `json:"maxHeaderCount"`
+	// ReadHeaderTimeout and BodyReadTimeout cut off slow clients;
+	// IdleTimeout closes idle keep-alive connections.
+	ReadHeaderTimeout Duration              `json:"readHeaderTimeout"`
+	BodyReadTimeout   Duration              `json:"bodyReadTimeout"`
+	IdleTimeout       Duration              `json:"idleTimeout"`
+	Routes            map[string]RouteLimit `json:"routes,omitempty"`
+}
+
+type RouteLimit struct {
+	MaxBodyBytes    int64    `json:"maxBodyBytes,omitempty"`
+	BodyReadTimeout Duration `json:"bodyReadTimeout,omitempty"`
+}
+
+// Live bounds proxied WebSocket and Server-Sent Events connections.
+// AllowedOrigins lists cross-origin pages allowed to open WebSockets in
+// addition to the gateway's own host.
+type Live struct {
+	IdleTimeout     Duration `json:"idleTimeout"`
+	MaxConnsPerUser int      `json:"maxConnsPerUser"`
+	MaxMessageBytes int64    `json:"maxMessageBytes"`
+	AllowedOrigins  []string `json:"allowedOrigins,omitempty"`
+}
+
+// Notifications configures the notification hub. The event service posts
+// changes with Token, and the publish endpoint is not mounted without one.
+// History is how many messages clients can resume over, and Buffer how
+// many a subscriber may fall behind before it is dropped.
+type Notifications struct {
+	Token         string   `json:"token,omitempty"`
+	History       int      `json:"history"`
+	Buffer        int      `json:"buffer"`
+	Heartbeat     Duration `json:"heartbeat"`
+	AttendanceTTL Duration `json:"attendanceTTL"`
+}
+
+// GraphQL bounds queries to /api/graphql. ListCost multiplies the cost of
+// selections under list fields. PersistedQueries names a JSON file mapping
+// SHA-256 hashes to queries; with PersistedOnly only those queries run and
+// clients cannot register their own. MaxRegistered bounds the queries
+// clients register.
+type GraphQL struct {
+	MaxDepth         int    `json:"maxDepth"`
+	MaxComplexity    int    `json:"maxComplexity"`
+	ListCost         int    `json:"listCost"`
+	PersistedQueries string `json:"persistedQueries,omitempty"`
+	PersistedOnly    bool   `json:"persistedOnly"`
+	MaxRegistered    int    `json:"maxRegistered"`
+}
+
+// Attendees configures ?expand=users on attendee lists. Path is the
+// dotted path of the list in the event service response, which may also
+// be a bare list. Profiles are fetched Concurrency at a time and cached
+// for ProfileTTL, at most MaxProfiles of them; 0 leaves the cache
+// unbounded.
+type Attendees struct {
+	Path        string   `json:"path"`
+	ProfileTTL  Duration `json:"profileTTL"`
+	Concurrency int      `json:"concurrency"`
+	MaxProfiles int      `json:"maxProfiles"`
+}
+
+// Batch bounds POST /api/batch: how many sub-requests one batch holds,
+// how many of them run at once and how long each may take.
+type Batch struct {
+	MaxRequests int      `json:"maxRequests"`
+	Concurrency int      `json:"concurrency"`
+	Timeout     Duration `json:"timeout"`
+}
+
+// Compression configures response compression and compressed request
+// bodies. Encodings lists "br", "zstd" and "gzip" in order of preference;
+// an empty list disables response compression.
+type Compression struct {
+	Encodings []string `json:"encodings"`
+	// MinSize is the smallest response, in bytes, worth compressing.
+	MinSize int `json:"minSize"`
+	// ContentTypes are compressed media types; entries ending in "/" match
+	// a whole type such as "text/".
+	ContentTypes []string `json:"contentTypes"`
+	// MaxDecompressedBytes and MaxRatio bound decoded request bodies.
+	MaxDecompressedBytes int64 `json:"maxDecompressedBytes"`
+	MaxRatio             int   `json:"maxRatio"`
+}
+
+// ResponseCache caches event service responses for the routes listed in
+// Routes, keyed like Shaping but without the /api prefix, e.g.
+// "GET /events/:eventId". MaxEntries 0 disables the cache; MaxBytes bounds
+// the cached bodies in total, 0 leaving them unbounded.
+type ResponseCache struct {
+	MaxEntries int                   `json:"maxEntries"`
+	MaxBytes   int64                 `json:"maxBytes"`
+	Routes     map[string]CacheRoute `json:"routes,omitempty"`
+}
+
+type CacheRoute struct {
+	// TTL overrides the freshness lifetime sent by the event service.
+	TTL Duration `json:"ttl,omitempty"`
+	// StaleWhileRevalidate applies when the event service does not send
+	// the directive itself.
+	StaleWhileRevalidate Duration `json:"staleWhileRevalidate,omitempty"`
+	// PerUser keys entries by the authenticated user, for personalized
+	// responses.
+	PerUser bool `json:"perUser"`
+}
+
+// Admin configures the internal admin API. It is disabled unless Port is
+// set, and binding beyond loopback requires Token.
+type Admin struct {
+	Port  string `json:"port,omitempty"`
+	Bind  string `json:"bind"`
+	Token string `json:"token,omitempty"`
+}
+
+// Audit configures the sinks receiving security-relevant actions. Either
+// may be empty.
+type Audit struct {
+	// File is a JSON lines file with hash chaining. It is off by default;
+	// give an absolute path, since a relative one depends on the working
+	// directory the gateway was started in.
+	File       string `json:"file,omitempty"`
+	WebhookURL string `json:"webhookURL,omitempty"`
+	BufferSize int    `json:"bufferSize"`
+}
+
+// Tracing configures OpenTelemetry trace export.
+type Tracing struct {
+	// Exporter is "none", "otlp" (OTLP over HTTP), "stdout" or "file".
+	Exporter string `json:"exporter"`
+	// Endpoint is the OTLP collector host:port. When empty the standard
+	// OTEL_EXPORTER_OTLP_* variables apply.
+	Endpoint    string  `json:"endpoint,omitempty"`
+	Insecure    bool    `json:"insecure,omitempty"`
+	File        string  `json:"file,omitempty"`
+	SampleRatio float64 `json:"sampleRatio"`
+	ServiceName string  `json:"serviceName"`
+}
+
+// RenderOptions controls the protojson encoding of protobuf responses.
+type RenderOptions struct {
+	// UseProtoNames renders fields with their proto names instead of
+	// lowerCamelCase JSON names.
+	UseProtoNames bool `json:"useProtoNames"`
+	// EmitUnpopulated renders zero-valued fields instead of omitting them.
+	EmitUnpopulated bool `json:"emitUnpopulated"`
+}
+
+type ShapingRoute struct {
+	// Collection is the path of the resource or resource list that ?fields=
+	// projects, e.g. "users" for {"users": [...]}. Empty means the root.
+	Collection string        `json:"collection,omitempty"`
+	Rules      []ShapingRule `json:"rules,omitempty"`
+}
+
+// ShapingRule removes the field at Path unless the caller holds one of the
+// VisibleTo roles ("self", "admin"). Path segments are separated by dots and
+// "*" matches every array element.
+type ShapingRule struct {
+	Path      string   `json:"path"`
+	VisibleTo []string `json:"visibleTo"`
+	// OwnerField names the sibling field holding the owner's user id for the
+	// "self" role. Defaults to "id".
+	OwnerField string `json:"ownerField,omitempty"`
+}
+
+// Route maps an HTTP endpoint onto a gRPC method in the style of
+// google.api.http annotations.
+type Route struct {
+	Method string `json:"method"`
+	// Path is a URL template such as "/users/{userId}". Each variable is
+	// bound to the request field of the same name unless Fields says
+	// otherwise.
+	Path string `json:"path"`
+	// RPC names the method as "/package.Service/Method".
+	RPC string `json:"rpc"`
+	// Body is "*" to bind the whole JSON body to the request, a field name
+	// to bind it to that field, or empty for no body. Remaining request
+	// fields are bound from query parameters.
+	Body string `json:"body,omitempty"`
+	// Fields maps path variables to request field paths, e.g.
+	// {"userId": "userId.id"}.
+	Fields map[string]string `json:"fields,omitempty"`
+	// ResponseBody renders a single field of the response instead of the
+	// whole message.
+	ResponseBody string `json:"responseBody,omitempty"`
+	Status       int    `json:"status,omitempty"`
+	Auth         bool   `json:"auth,omitempty"`
+	// Hooks names hooks registered with the transcoder, run in order.
+	Hooks []string `json:"hooks,omitempty"`
+	// Audit records calls to the route in the audit log under this action.
+	Audit string `json:"audit,omitempty"`
+}
+
+// Composite declares a GET endpoint that fans out to several upstream
+// calls and merges their results into one document keyed by call name.
+// Calls that fail are reported under "_errors" unless they are Required.
+type Composite struct {
+	// Path is a URL template under /api such as "/events/{eventId}/details".
+	Path  string          `json:"path"`
+	Calls []CompositeCall `json:"calls"`
+	// Timeout applies to calls that set none.
+	Timeout Duration `json:"timeout,omitempty"`
+}
+
+// CompositeCall is one upstream call, to the event service with Path or
+// to the auth service with RPC. Templates name path variables as
+// "{eventId}" and fields of other results as "{event.organizerId}"; a
+// call runs as soon as the calls it references have finished, in
+// parallel with the others.
+type CompositeCall struct {
+	Name string `json:"name"`
+	// Path is requested with GET from the event service.
+	Path string `json:"path,omitempty"`
+	RPC  string `json:"rpc,omitempty"`
+	// Fields binds RPC request fields to templates, e.g.
+	// {"id": "{event.organizerId}"}.
+	Fields   map[string]string `json:"fields,omitempty"`
+	Timeout  Duration          `json:"timeout,omitempty"`
+	Required bool              `json:"required,omitempty"`
+}
+
+// Default returns the configuration used before the config file and
+// environment are applied.
+func Default() *Config {
+	return &Config{
+		UsersSnapshotTTL: Duration(30 * time.Second),
+		Logging:          Logging{Level: "info", Format: "json"},
+		AccessLog:        AccessLog{Format: "combined", Output: "stdout", MaxSizeMB: 100, MaxBackups: 5},
+		Audit:            Audit{BufferSize: 1024},
+		Admin:            Admin{Bind: "127.0.0.1"},
+		Health:           Health{EventProbePath: "/health", Timeout: Duration(2 * time.Second), CacheTTL: Duration(2 * time.Second)},
+		ShutdownTimeout:  Duration(15 * time.Second),
+		Limits: Limits{
+			MaxBodyBytes:      1 << 20,
+			MaxHeaderBytes:    64 << 10,
+			MaxHeaderCount:    100,
+			ReadHeaderTimeout: Duration(5 * time.Second),
+			BodyReadTimeout:   Duration(30 * time.Second),
+			IdleTimeout:       Duration(2 * time.Minute),
+			Routes: map[string]RouteLimit{
+				"PUT /api/events/:eventId/cover": {MaxBodyBytes: 10 << 20, BodyReadTimeout: Duration(5 * time.Minute)},
+			},
+		},
+		Live: Live{
+			IdleTimeout:     Duration(2 * time.Minute),
+			MaxConnsPerUser: 5,
+			MaxMessageBytes: 64 << 10,
+		},
+		Notifications: Notifications{
+			History:       1000,
+			Buffer:        64,
+			Heartbeat:     Duration(30 * time.Second),
+			AttendanceTTL: Duration(30 * time.Second),
+		},
+		GraphQL: GraphQL{
+			MaxDepth:      8,
+			MaxComplexity: 1000,
+			ListCost:      10,
+			MaxRegistered: 1000,
+		},
+		Attendees: Attendees{
+			Path:        "attendees",
+			ProfileTTL:  Duration(time.Minute),
+			Concurrency: 8,
+			MaxProfiles: 10000,
+		},
+		Batch: Batch{
+			MaxRequests: 20,
+			Concurrency: 4,
+			Timeout:     Duration(10 * time.Second),
+		},
+		Compression: Compression{
+			Encodings:            []string{"br", "zstd", "gzip"},
+			MinSize:              1024,
+			ContentTypes:         []string{"application/json", "application/problem+json", "application/x-protobuf", "text/"},
+			MaxDecompressedBytes: 10 << 20,
+			MaxRatio:             100,
+		},
+		ResponseCache: ResponseCache{
+			MaxEntries: 1000,
+			MaxBytes:   64 << 20,
+			Routes: map[string]CacheRoute{
+				"GET /events/search":   {PerUser: true},
+				"GET /events/:eventId": {PerUser: true},
+			},
+		},
+		Composites: []Composite{{
+			Path:    "/events/{eventId}/details",
+			Timeout: Duration(2 * time.Second),
+			Calls: []CompositeCall{
+				{Name: "event", Path: "/events/{eventId}", Required: true},
+				{Name: "attendees", Path: "/events/{eventId}/attendees"},
+				{Name: "organizer", RPC: "/auth.AuthService/GetUserById", Fields: map[string]string{"id": "{event.organizerId}"}},
+			},
+		}},
+		Tracing: Tracing{
+			Exporter:    "none",
+			SampleRatio: 1,
+			ServiceName: "event-horizon-gateway",
+		},
+	}
+}
+
+// Load reads the optional JSON file named by GATEWAY_CONFIG and then applies
+// environment variable overrides.
+func Load() (*Config, error) {
+	cfg := Default()
+
+	if path := os.Getenv("GATEWAY_CONFIG"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read config: %w", err)
+		}
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("parse config %s: %w", path, err)
+		}
+	}
+
+	setFromEnv(&cfg.Port, "PORT")
+	setFromEnv(&cfg.AuthService, "AUTH_SVC")
+	setFromEnv(&cfg.EventService, "EVENT_MGT_SVC")
+	setFromEnv(&cfg.Environment, "ENVIRONMENT")
+	setFromEnv(&cfg.MetricsPort, "METRICS_PORT")
+	setFromEnv(&cfg.Logging.Level, "LOG_LEVEL")
+	setFromEnv(&cfg.Logging.Format, "LOG_FORMAT")
+	setFromEnv(&cfg.AccessLog.Format, "ACCESS_LOG_FORMAT")
+	setFromEnv(&cfg.AccessLog.Output, "ACCESS_LOG_OUTPUT")
+	setFromEnv(&cfg.Audit.File, "AUDIT_LOG_FILE")
+	setFromEnv(&cfg.Audit.WebhookURL, "AUDIT_WEBHOOK_URL")
+	setFromEnv(&cfg.Admin.Port, "ADMIN_PORT")
+	setFromEnv(&cfg.Admin.Bind, "ADMIN_BIND")
+	setFromEnv(&cfg.Admin.Token, "ADMIN_TOKEN")
+	setFromEnv(&cfg.Notifications.Token, "NOTIFICATIONS_TOKEN")
+	setFromEnv(&cfg.GraphQL.PersistedQueries, "GRAPHQL_PERSISTED_QUERIES")
+	setFromEnv(&cfg.Attendees.Path, "ATTENDEES_PATH")
+	setFromEnv(&cfg.Health.EventProbePath, "EVENT_SVC_HEALTH_PATH")
+	setFromEnv(&cfg.Tracing.Exporter, "OTEL_TRACES_EXPORTER")
+	setFromEnv(&cfg.Tracing.File, "OTEL_TRACES_FILE")
+	setFromEnv(&cfg.Tracing.ServiceName, "OTEL_SERVICE_NAME")
+	if v := os.Getenv("OTEL_TRACES_SAMPLER_ARG"); v != "" {
+		ratio, err := strconv.ParseFloat(v, 64)
+		if err != nil || ratio < 0 || ratio > 1 {
+			return nil, fmt.Errorf("OTEL_TRACES_SAMPLER_ARG must be a ratio between 0 and 1")
+		}
+		cfg.Tracing.SampleRatio = ratio
+	}
+
+	if err := boolFromEnv(&cfg.Render.UseProtoNames, "JSON_USE_PROTO_NAMES"); err != nil {
+		return nil, err
+	}
+	if err := boolFromEnv(&cfg.Render.EmitUnpopulated, "JSON_EMIT_UNPOPULATED"); err != nil {
+		return nil, err
+	}
+
+	if v, ok := os.LookupEnv("COMPRESSION_ENCODINGS"); ok {
+		cfg.Compression.Encodings = nil
+		for _, encoding := range strings.Split(v, ",") {
+			if encoding = strings.TrimSpace(encoding); encoding != "" {
+				cfg.Compression.Encodings = append(cfg.Compression.Encodings, encoding)
+			}
+		}
+	}
+	if v := os.Getenv("COMPRESSION_MIN_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("COMPRESSION_MIN_SIZE must be a non-negative integer")
+		}
+		cfg.Compression.MinSize = n
+	}
+
+	if v := os.Getenv("MAX_BODY_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("MAX_BODY_BYTES must be a non-negative integer")
+		}
+		cfg.Limits.MaxBodyBytes = n
+	}
+
+	if v := os.Getenv("LIVE_MAX_CONNS_PER_USER"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("LIVE_MAX_CONNS_PER_USER must be a non-negative integer")
+		}
+		cfg.Live.MaxConnsPerUser = n
+	}
+
+	if v := os.Getenv("PROFILE_CACHE_MAX_ENTRIES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("PROFILE_CACHE_MAX_ENTRIES must be a non-negative integer")
+		}
+		cfg.Attendees.MaxProfiles = n
+	}
+	if v := os.Getenv("RESPONSE_CACHE_MAX_ENTRIES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("RESPONSE_CACHE_MAX_ENTRIES must be a non-negative integer")
+		}
+		cfg.ResponseCache.MaxEntries = n
+	}
+	if v := os.Getenv("RESPONSE_CACHE_MAX_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("RESPONSE_CACHE_MAX_BYTES must be a non-negative integer")
+		}
+		cfg.ResponseCache.MaxBytes = n
+	}
+
+	if v := os.Getenv("ADMIN_USERS"); v != "" {
+		cfg.AdminUsers = strings.Split(v, ",")
+	}
+	if v := os.Getenv("TRUSTED_PROXIES"); v != "" {
+		cfg.TrustedProxies = strings.Split(v, ",")
+	}
+
+	for key, dst := range map[string]*Duration{
+		"USERS_SNAPSHOT_TTL": &cfg.UsersSnapshotTTL,
+		"SHUTDOWN_TIMEOUT":   &cfg.ShutdownTimeout,
+		"DRAIN_DELAY":        &cfg.DrainDelay,
+		"BODY_READ_TIMEOUT":  &cfg.Limits.BodyReadTimeout,
+		"LIVE_IDLE_TIMEOUT":  &cfg.Live.IdleTimeout,
+		"PROFILE_CACHE_TTL":  &cfg.Attendees.ProfileTTL,
+		"BATCH_TIMEOUT":      &cfg.Batch.Timeout,
+		"HEALTH_CACHE_TTL":   &cfg.Health.CacheTTL,
+	} {
+		if err := durationFromEnv(dst, key); err != nil {
+			return nil, err
+		}
+	}
+
+	if raw := os.Getenv("GRPC_STATUS_MAPPING"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &cfg.GrpcStatusMapping); err != nil {
+			return nil, fmt.Errorf("parse GRPC_STATUS_MAPPING: %w", err)
+		}
+	}
+
+	for _, encoding := range cfg.Compression.Encodings {
+		if encoding != "br" && encoding != "zstd" && encoding != "gzip" {
+			return nil, fmt.Errorf("unsupported compression encoding %q", encoding)
+		}
+	}
+
+	if cfg.Port == "" {
+		return nil, fmt.Errorf("PORT environment variable not set")
+	}
+	return cfg, nil
+}
+
+func setFromEnv(dst *string, key string) {
+	if v := os.Getenv(key); v != "" {
+		*dst = v
+	}
+}
+
+func boolFromEnv(dst *bool, key string) error {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", key, err)
+	}
+	*dst = b
+	return nil
+}
+
+func durationFromEnv(dst *Duration, key string) error {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", key, err)
+	}
+	*dst = Duration(d)
+	return nil
+}
@@ -0,0 +1,46 @@
+package controller_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rekib0023/event-horizon-gateway/gatewaytest"
+)
+
+func TestExpandAttendees(t *testing.T) {
+	h := gatewaytest.New(t, nil)
+	h.Auth.SetNextID(1000000)
+	ada := h.AddUser("ada@example.com")
+	h.Events.Handle(http.MethodGet, "/events/7/attendees", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"attendees":[{"id":%d},{"id":4}]}`, ada.Id)
+	})
+
+	rec := h.DoAs(ada, http.MethodGet, "/api/events/7/attendees?expand=users", nil)
+	h.ExpectStatus(rec, http.StatusOK)
+	var doc struct {
+		Attendees []struct {
+			ID   any            `json:"id"`
+			User map[string]any `json:"user"`
+		} `json:"attendees"`
+	}
+	h.Decode(rec, &doc)
+	if len(doc.Attendees) != 2 || doc.Attendees[0].User["userName"] != "ada@example.com" || doc.Attendees[1].User != nil {
+		t.Fatalf("attendees = %+v", doc.Attendees)
+	}
+	if _, ok := doc.Attendees[0].User["email"]; ok {
+		t.Fatalf("expanded profile carries an email: %+v", doc.Attendees[0].User)
+	}
+	if !strings.Contains(rec.Body.String(), `"id":1000000`) {
+		t.Fatalf("large id was not kept as an integer: %s", rec.Body.String())
+	}
+}
+
+func TestExpandAttendeesRejectsUnknownExpansions(t *testing.T) {
+	h := gatewaytest.New(t, nil)
+	user := h.AddUser("ada@example.com")
+
+	h.ExpectStatus(h.DoAs(user, http.MethodGet, "/api/events/7/attendees?expand=events", nil), http.StatusBadRequest)
+}
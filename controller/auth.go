@@ -1,136 +1,81 @@
 package controller
 
 import (
-	"context"
-	"log"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rekib0023/event-horizon-gateway/audit"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/problem"
 	pb "github.com/rekib0023/event-horizon-gateway/proto"
-	"github.com/rekib0023/event-horizon-gateway/utils"
-	"google.golang.org/grpc/status"
+	"github.com/rekib0023/event-horizon-gateway/transcoder"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
-type AuthController struct {
-	gRpc pb.AuthServiceClient
+var authRoutes = []config.Route{
+	{Method: http.MethodPost, Path: "/auth/signup", RPC: pb.AuthService_Signup_FullMethodName, Body: "*", Hooks: []string{"auditEmail", "tokenCookie"}, Audit: "auth.signup"},
+	{Method: http.MethodPost, Path: "/auth/login", RPC: pb.AuthService_Login_FullMethodName, Body: "*", Hooks: []string{"auditEmail", "tokenCookie"}, Audit: "auth.login"},
+	{Method: http.MethodGet, Path: "/auth/verify-token", RPC: pb.AuthService_VerifyToken_FullMethodName, Body: "*"},
+	{Method: http.MethodPost, Path: "/auth/refresh-token", RPC: pb.AuthService_RefreshToken_FullMethodName, Hooks: []string{"bearerToken", "tokenCookie", "tokenRefreshed"}, Audit: "auth.refresh_token"},
 }
 
-var authController *AuthController
+func (c *ControllerInterface) InitAuthController() error {
+	c.transcoder.RegisterHook("auditEmail", transcoder.Hook{Before: auditEmail})
+	c.transcoder.RegisterHook("bearerToken", transcoder.Hook{Before: bearerToken})
+	c.transcoder.RegisterHook("tokenCookie", transcoder.Hook{After: setTokenCookie})
+	c.transcoder.RegisterHook("tokenRefreshed", transcoder.Hook{After: tokenRefreshed})
 
-func (c *ControllerInterface) InitAuthController() {
-	authController = &AuthController{
-		gRpc: c.gRpc,
-	}
-
-	POST("/auth/signup", authController.signup)
-	POST("/auth/login", authController.login)
-	GET("/auth/verify-token", authController.verifyToken)
-	POST("/auth/refresh-token", authController.refreshToken)
+	return c.registerRoutes(authRoutes)
 }
 
-func (o *AuthController) signup(c *gin.Context) {
-	var reqData pb.SignupRequest
-	if err := c.ShouldBindJSON(&reqData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
-		return
-	}
-
-	res, err := o.gRpc.Signup(context.Background(), &pb.SignupRequest{FirstName: reqData.FirstName, LastName: reqData.LastName, UserName: reqData.UserName, Email: reqData.Email, Password: reqData.Password})
-	if err != nil {
-		log.Printf("could not call Signup: %v", err)
-		if s, ok := status.FromError(err); ok {
-			httpStatusCode := utils.GetHttpStatusCode(s.Code())
-			c.JSON(httpStatusCode, gin.H{"error": s.Message()})
-			return
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-			return
-		}
+// bearerToken binds the Authorization bearer token to the request's token
+// field.
+func bearerToken(c *gin.Context, req proto.Message) error {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		return problem.New(http.StatusUnauthorized, "Authorization header is required")
 	}
 
-	c.SetCookie("token", res.Token, 3600, "/", "", false, true)
-	res.Token = ""
-	c.JSON(http.StatusOK, res)
-}
-
-func (o *AuthController) login(c *gin.Context) {
-	var reqData pb.LoginRequest
-	if err := c.ShouldBindJSON(&reqData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
-		return
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return problem.New(http.StatusUnauthorized, "Invalid Authorization header format")
 	}
 
-	res, err := o.gRpc.Login(context.Background(), &pb.LoginRequest{Email: reqData.Email, Password: reqData.Password})
-	if err != nil {
-		log.Printf("could not call Login: %v", err)
-		if s, ok := status.FromError(err); ok {
-			httpStatusCode := utils.GetHttpStatusCode(s.Code())
-			c.JSON(httpStatusCode, gin.H{"error": s.Message()})
-			return
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-			return
-		}
+	msg := req.ProtoReflect()
+	fd := msg.Descriptor().Fields().ByName("token")
+	if fd == nil || fd.Kind() != protoreflect.StringKind {
+		return problem.New(http.StatusInternalServerError, "Internal server error")
 	}
-
-	c.SetCookie("token", res.Token, 3600, "/", "", false, true)
-	res.Token = ""
-	c.JSON(http.StatusOK, res)
+	msg.Set(fd, protoreflect.ValueOfString(parts[1]))
+	return nil
 }
 
-func (o *AuthController) verifyToken(c *gin.Context) {
-	var reqData pb.Token
-	if err := c.ShouldBindJSON(&reqData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
-		return
+// auditEmail names the email in the request as the actor of an
+// unauthenticated action.
+func auditEmail(c *gin.Context, req proto.Message) error {
+	msg := req.ProtoReflect()
+	if fd := msg.Descriptor().Fields().ByName("email"); fd != nil && fd.Kind() == protoreflect.StringKind {
+		c.Set(audit.ActorKey, msg.Get(fd).String())
 	}
-
-	res, err := o.gRpc.VerifyToken(context.Background(), &pb.Token{Token: reqData.Token})
-	if err != nil {
-		log.Printf("could not call VerifyToken: %v", err)
-		if s, ok := status.FromError(err); ok {
-			httpStatusCode := utils.GetHttpStatusCode(s.Code())
-			c.JSON(httpStatusCode, gin.H{"error": s.Message()})
-			return
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-			return
-		}
-	}
-	c.JSON(http.StatusOK, res)
+	return nil
 }
 
-func (o *AuthController) refreshToken(c *gin.Context) {
-	authHeader := c.GetHeader("Authorization")
-	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
-		c.Abort()
-		return
+// setTokenCookie moves the response's token field into the token cookie.
+func setTokenCookie(c *gin.Context, res proto.Message) error {
+	msg := res.ProtoReflect()
+	fd := msg.Descriptor().Fields().ByName("token")
+	if fd == nil || fd.Kind() != protoreflect.StringKind {
+		return nil
 	}
 
-	parts := strings.Split(authHeader, " ")
-	if len(parts) != 2 || parts[0] != "Bearer" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Authorization header format"})
-		c.Abort()
-		return
-	}
-
-	token := parts[1]
-
-	res, err := o.gRpc.RefreshToken(context.Background(), &pb.Token{Token: token})
-	if err != nil {
-		log.Printf("could not call RefreshToken: %v", err)
-		if s, ok := status.FromError(err); ok {
-			httpStatusCode := utils.GetHttpStatusCode(s.Code())
-			c.JSON(httpStatusCode, gin.H{"error": s.Message()})
-			return
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-			return
-		}
-	}
+	c.SetCookie("token", msg.Get(fd).String(), 3600, "/", "", false, true)
+	msg.Clear(fd)
+	return nil
+}
 
-	c.SetCookie("token", res.Token, 3600, "/", "", false, true)
+func tokenRefreshed(c *gin.Context, res proto.Message) error {
 	c.JSON(http.StatusCreated, gin.H{"message": "Token refreshed"})
+	return nil
 }
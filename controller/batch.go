@@ -0,0 +1,7 @@
+package controller
+
+import "github.com/rekib0023/event-horizon-gateway/batch"
+
+func (o *ControllerInterface) InitBatchController() {
+	o.POST("/batch", o.auth, batch.Handler(o.e, o.cfg.Batch))
+}
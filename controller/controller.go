@@ -2,130 +2,238 @@ package controller
 
 import (
 	"encoding/json"
-	"log"
+	"mime"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rekib0023/event-horizon-gateway/admin"
+	"github.com/rekib0023/event-horizon-gateway/audit"
+	"github.com/rekib0023/event-horizon-gateway/compose"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/httpcache"
+	"github.com/rekib0023/event-horizon-gateway/live"
+	"github.com/rekib0023/event-horizon-gateway/logging"
+	"github.com/rekib0023/event-horizon-gateway/metrics"
+	"github.com/rekib0023/event-horizon-gateway/notify"
+	"github.com/rekib0023/event-horizon-gateway/problem"
 	pb "github.com/rekib0023/event-horizon-gateway/proto"
+	"github.com/rekib0023/event-horizon-gateway/render"
+	"github.com/rekib0023/event-horizon-gateway/shaping"
+	"github.com/rekib0023/event-horizon-gateway/tracing"
+	"github.com/rekib0023/event-horizon-gateway/transcoder"
+	"github.com/rekib0023/event-horizon-gateway/users"
+	"github.com/rekib0023/event-horizon-gateway/utils"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
 	"google.golang.org/grpc"
 )
 
 type ControllerInterface struct {
-	r          *gin.RouterGroup
-	gRpc       pb.AuthServiceClient
-	httpClient *http.Client
+	e             *gin.Engine
+	r             *gin.RouterGroup
+	cfg           *config.Config
+	gRpc          pb.AuthServiceClient
+	httpClient    *http.Client
+	statusMapping *utils.StatusMapping
+	renderer      *render.Renderer
+	transcoder    *transcoder.Transcoder
+	composer      *compose.Composer
+	userLister    users.Lister
+	profiles      *users.Profiles
+	audit         *audit.Recorder
+	live          *live.Proxy
+	hub           *notify.Hub
+	auth          gin.HandlerFunc
+	chains        map[string][]string
 }
 
-var controller *ControllerInterface
+// Deps are the collaborators the controllers call into.
+type Deps struct {
+	Config        *config.Config
+	AuthConn      grpc.ClientConnInterface
+	HTTPClient    *http.Client
+	StatusMapping *utils.StatusMapping
+	Renderer      *render.Renderer
+	UserLister    users.Lister
+	Profiles      *users.Profiles
+	Audit         *audit.Recorder
+	Live          *live.Proxy
+	Hub           *notify.Hub
+	// Auth authenticates requests to protected routes.
+	Auth gin.HandlerFunc
+}
 
-func Init() {
-	controller.InitAuthController()
-	controller.InitProfileController()
-	controller.InitEventController()
+// Register mounts the API routes under /api on e.
+func Register(e *gin.Engine, d Deps) (*ControllerInterface, error) {
+	o := &ControllerInterface{
+		e:             e,
+		r:             e.Group("/api"),
+		cfg:           d.Config,
+		gRpc:          pb.NewAuthServiceClient(d.AuthConn),
+		httpClient:    d.HTTPClient,
+		statusMapping: d.StatusMapping,
+		renderer:      d.Renderer,
+		transcoder:    transcoder.New(d.AuthConn, d.StatusMapping, d.Renderer),
+		composer:      compose.New(d.AuthConn, d.HTTPClient, d.Config.EventService, d.StatusMapping, d.Renderer),
+		userLister:    d.UserLister,
+		profiles:      d.Profiles,
+		audit:         d.Audit,
+		live:          d.Live,
+		hub:           d.Hub,
+		auth:          d.Auth,
+		chains:        map[string][]string{},
+	}
+	if err := o.Init(); err != nil {
+		return nil, err
+	}
+	return o, nil
 }
 
-var e *gin.Engine
+func (o *ControllerInterface) Init() error {
+	if err := o.InitAuthController(); err != nil {
+		return err
+	}
+	if err := o.InitProfileController(); err != nil {
+		return err
+	}
+	o.InitEventController()
+	if err := o.InitGraphQLController(); err != nil {
+		return err
+	}
+	if err := o.registerComposites(o.cfg.Composites); err != nil {
+		return err
+	}
+	o.InitBatchController()
+	return o.registerRoutes(o.cfg.Routes)
+}
 
-func Start() {
-	e = gin.Default()
+func (o *ControllerInterface) UserLister() users.Lister {
+	return o.userLister
+}
 
-	log.Println("Dialing to:", os.Getenv("AUTH_SVC"))
-	conn, err := grpc.Dial(os.Getenv("AUTH_SVC"), grpc.WithInsecure())
-	if err != nil {
-		log.Printf("did not connect: %v", err)
-	} else {
-		defer conn.Close()
-		gRpc := pb.NewAuthServiceClient(conn)
-		apiGroup := e.Group("/api")
-		controller = &ControllerInterface{
-			r:    apiGroup,
-			gRpc: gRpc,
-		}
+// ShapingRoutes merges the configured shaping rules over the built-in ones.
+func ShapingRoutes(cfg *config.Config) map[string]config.ShapingRoute {
+	routes := map[string]config.ShapingRoute{}
+	for key, route := range profileShaping {
+		routes[key] = route
 	}
-	Init()
-
-	port := os.Getenv("PORT")
-	if port == "" {
-		log.Fatal("PORT environment variable not set")
+	for key, route := range cfg.Shaping {
+		routes[key] = route
 	}
+	return routes
+}
 
-	serverErr := e.Run(":" + port).Error()
-	if serverErr != "" {
-		log.Fatalf("Failed to start server. Error: %s", serverErr)
+// RouteTable lists every route on the engine with its handler chain. Routes
+// registered outside the controller helpers only run engine middleware.
+func (o *ControllerInterface) RouteTable() []admin.Route {
+	var routes []admin.Route
+	for _, info := range o.e.Routes() {
+		chain, ok := o.chains[info.Method+" "+info.Path]
+		if !ok {
+			chain = append(admin.HandlerNames(o.e.Handlers), admin.HandlerNames([]gin.HandlerFunc{info.HandlerFunc})...)
+		}
+		routes = append(routes, admin.Route{Method: info.Method, Path: info.Path, Handlers: chain})
 	}
-	log.Println("Starting server on :" + port + "...")
+	return routes
 }
 
 func (o *ControllerInterface) eventsPassThrough(c *gin.Context) {
+	o.forwardEvents(c, "application/json", nil)
+}
+
+// eventsUpload streams multipart uploads to the event service as they
+// arrive, without buffering them in the gateway.
+func (o *ControllerInterface) eventsUpload(c *gin.Context) {
+	mediaType, params, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		problem.Abort(c, problem.New(http.StatusUnsupportedMediaType, "Uploads must be multipart/form-data"))
+		return
+	}
+	o.forwardEvents(c, c.GetHeader("Content-Type"), nil)
+}
+
+// forwardEvents proxies the request to the event service. enrich, when
+// set, may add to the decoded response before it is rendered.
+func (o *ControllerInterface) forwardEvents(c *gin.Context, contentType string, enrich func(c *gin.Context, data interface{}) *problem.Problem) {
 	userValue, exists := c.Get("user")
 	if !exists {
-		o.jsonError(c, "Internal server error", http.StatusInternalServerError)
+		problem.Abort(c, problem.New(http.StatusInternalServerError, "Internal server error"))
 		return
 	}
 
 	currentUser, ok := userValue.(*pb.TokenVerification)
 	if !ok {
-		o.jsonError(c, "Internal server error", http.StatusInternalServerError)
+		problem.Abort(c, problem.New(http.StatusInternalServerError, "Internal server error"))
 		return
 	}
 
+	ctx, span := tracing.Start(c.Request.Context(), "eventsPassThrough", semconv.HTTPRoute(c.FullPath()))
+	defer span.End()
+
 	endpoint := strings.TrimPrefix(c.Request.URL.Path, "/api")
-	baseURL := os.Getenv("EVENT_MGT_SVC") + endpoint
+	baseURL := o.cfg.EventService + endpoint
 
 	u, err := url.Parse(baseURL)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		problem.Abort(c, problem.New(http.StatusInternalServerError, "Internal server error"))
 		return
 	}
 
 	queryParams := c.Request.URL.Query()
 	u.RawQuery = queryParams.Encode()
-	req, err := http.NewRequest(c.Request.Method, u.String(), c.Request.Body)
+	operation := c.Request.Method + " " + strings.TrimPrefix(c.FullPath(), "/api")
+	ctx = metrics.WithOperation(ctx, operation)
+	ctx = httpcache.WithRoute(ctx, operation)
+	req, err := http.NewRequestWithContext(ctx, c.Request.Method, u.String(), c.Request.Body)
 
 	if err != nil {
-		o.jsonError(c, err.Error(), http.StatusInternalServerError)
+		problem.Abort(c, problem.New(http.StatusInternalServerError, "Internal server error"))
 		return
 	}
 
 	req.Header.Set("X-User-ID", currentUser.Id)
 	req.Header.Set("X-User-Email", currentUser.Email)
-	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Content-Type", contentType)
+	req.ContentLength = c.Request.ContentLength
 
 	resp, err := o.httpClient.Do(req)
+	if p := problem.FromBodyError(err); p != nil {
+		problem.Abort(c, p)
+		return
+	}
 	if err != nil {
-		o.jsonError(c, err.Error(), http.StatusInternalServerError)
+		logging.FromContext(ctx).Warn("upstream call failed", "upstream", "event-service", "error", err)
+		problem.Abort(c, problem.New(http.StatusBadGateway, "Event service is unavailable"))
 		return
 	}
 
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		var errResp struct {
-			Errors []struct {
-				Message string `json:"message"`
-			} `json:"errors"`
-		}
-		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
-			o.jsonError(c, "Failed to decode error response", http.StatusInternalServerError)
-			return
-		}
-		var errMsgs []string
-		for _, e := range errResp.Errors {
-			errMsgs = append(errMsgs, e.Message)
-		}
-		errMsg := strings.Join(errMsgs, ", ")
-		o.jsonError(c, errMsg, resp.StatusCode)
+		problem.Abort(c, problem.FromUpstream(resp))
 		return
 	}
 
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
 	var data interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		o.jsonError(c, err.Error(), http.StatusInternalServerError)
+	if err := dec.Decode(&data); err != nil {
+		logging.FromContext(ctx).Warn("could not decode upstream response", "upstream", "event-service", "error", err)
+		problem.Abort(c, problem.New(http.StatusBadGateway, "Invalid response from event service"))
 		return
 	}
 
-	c.JSON(http.StatusOK, data)
+	if v := resp.Header.Get(httpcache.Header); v != "" {
+		c.Header(httpcache.Header, v)
+	}
+	if enrich != nil {
+		if p := enrich(c, data); p != nil {
+			problem.Abort(c, p)
+			return
+		}
+		o.renderer.Value(c, http.StatusOK, data)
+		return
+	}
+	c.JSON(http.StatusOK, shaping.FromContext(c).Apply(data))
 }
@@ -0,0 +1,107 @@
+package controller_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/gatewaytest"
+	pb "github.com/rekib0023/event-horizon-gateway/proto"
+)
+
+func TestUserETags(t *testing.T) {
+	h := gatewaytest.New(t, nil)
+	user := h.AddUser("ada@example.com")
+	path := fmt.Sprintf("/api/users/%d", user.Id)
+
+	rec := h.DoAs(user, http.MethodGet, path, nil)
+	h.ExpectStatus(rec, http.StatusOK)
+	etag := rec.Header().Get("ETag")
+	if etag == "" || etag[0] != '"' {
+		t.Fatalf("ETag = %q, want a strong tag", etag)
+	}
+
+	req := gatewaytest.As(h.Request(http.MethodGet, path, nil), user.GetToken())
+	req.Header.Set("If-None-Match", etag)
+	h.ExpectStatus(h.Do(req), http.StatusNotModified)
+
+	req = gatewaytest.As(h.Request(http.MethodPut, path, map[string]any{"firstName": "Ada"}), user.GetToken())
+	req.Header.Set("If-Match", etag)
+	rec = h.Do(req)
+	h.ExpectStatus(rec, http.StatusOK)
+	if rec.Header().Get("ETag") == etag {
+		t.Fatalf("ETag did not change after an update")
+	}
+}
+
+func TestUserIfMatchConflict(t *testing.T) {
+	h := gatewaytest.New(t, nil)
+	user := h.AddUser("ada@example.com")
+	path := fmt.Sprintf("/api/users/%d", user.Id)
+
+	req := gatewaytest.As(h.Request(http.MethodPut, path, map[string]any{"firstName": "Ada"}), user.GetToken())
+	req.Header.Set("If-Match", `"stale"`)
+	rec := h.Do(req)
+	h.ExpectStatus(rec, http.StatusPreconditionFailed)
+	if rec.Header().Get("ETag") == "" {
+		t.Fatalf("412 without the current ETag")
+	}
+
+	req = gatewaytest.As(h.Request(http.MethodDelete, path, nil), user.GetToken())
+	req.Header.Set("If-Match", `W/"weak"`)
+	h.ExpectStatus(h.Do(req), http.StatusPreconditionFailed)
+}
+
+func TestUserETagNamesTheRepresentation(t *testing.T) {
+	h := gatewaytest.New(t, func(cfg *config.Config) { cfg.AdminUsers = []string{"admin@example.com"} })
+	user := h.AddUser("ada@example.com")
+	admin := h.AddUser("admin@example.com")
+	path := fmt.Sprintf("/api/users/%d", user.Id)
+
+	get := func(as *pb.UserResponse, target, accept, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := gatewaytest.As(h.Request(http.MethodGet, target, nil), as.GetToken())
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		return h.Do(req)
+	}
+
+	jsonTag := get(user, path, "", "").Header().Get("ETag")
+	tags := map[string]string{
+		"protobuf": get(user, path, "application/x-protobuf", "").Header().Get("ETag"),
+		"fields":   get(user, path+"?fields=id", "", "").Header().Get("ETag"),
+		"admin":    get(admin, path, "", "").Header().Get("ETag"),
+	}
+	for name, tag := range tags {
+		if tag == "" || tag == jsonTag {
+			t.Errorf("%s ETag = %q, want one differing from the JSON tag %q", name, tag, jsonTag)
+		}
+	}
+
+	h.ExpectStatus(get(user, path, "application/x-protobuf", jsonTag), http.StatusOK)
+	rec := get(user, path, "", jsonTag)
+	h.ExpectStatus(rec, http.StatusNotModified)
+	if got := rec.Header().Get("Vary"); got != "Accept" {
+		t.Fatalf("304 Vary = %q, want Accept", got)
+	}
+
+	// Any representation's tag names the version a write applies to.
+	req := gatewaytest.As(h.Request(http.MethodPatch, path, map[string]any{"lastName": "Lovelace"}), user.GetToken())
+	req.Header.Set("If-Match", tags["protobuf"])
+	rec = h.Do(req)
+	h.ExpectStatus(rec, http.StatusOK)
+	var patched map[string]any
+	h.Decode(rec, &patched)
+	if patched["lastName"] != "Lovelace" || patched["email"] != "ada@example.com" {
+		t.Fatalf("patched user = %v", patched)
+	}
+
+	req = gatewaytest.As(h.Request(http.MethodPatch, path, map[string]any{"lastName": "Byron"}), user.GetToken())
+	req.Header.Set("If-Match", jsonTag)
+	h.ExpectStatus(h.Do(req), http.StatusPreconditionFailed)
+}
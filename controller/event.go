@@ -2,21 +2,92 @@ package controller
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
 
-	"github.com/rekib0023/event-horizon-gateway/middlewares"
+	"github.com/gin-gonic/gin"
+	"github.com/rekib0023/event-horizon-gateway/problem"
+	pb "github.com/rekib0023/event-horizon-gateway/proto"
+	"github.com/rekib0023/event-horizon-gateway/users"
+	"github.com/rekib0023/event-horizon-gateway/utils"
 )
 
 func (o *ControllerInterface) InitEventController() {
-	o.httpClient = &http.Client{}
-
-	USE(middlewares.TokenAuthMiddleware(o.gRpc))
-
-	GET("/events/search", o.eventsPassThrough)
-	POST("/events", o.eventsPassThrough)
-	GET("/events/:eventId", o.eventsPassThrough)
-	PUT("/events/:eventId", o.eventsPassThrough)
-	DELETE("/events/:eventId", o.eventsPassThrough)
-	GET("/events/:eventId/attendees", o.eventsPassThrough)
-	POST("/events/:eventId/attendEvent", o.eventsPassThrough)
-	POST("/events/:eventId/register", o.eventsPassThrough)
+	o.GET("/events/search", o.auth, o.eventsPassThrough)
+	o.POST("/events", o.audit.Middleware("event.create"), o.auth, o.eventsPassThrough)
+	o.GET("/events/:eventId", o.auth, o.eventsPassThrough)
+	o.PUT("/events/:eventId", o.audit.Middleware("event.update"), o.auth, o.eventsPassThrough)
+	o.DELETE("/events/:eventId", o.audit.Middleware("event.delete"), o.auth, o.eventsPassThrough)
+	o.GET("/events/:eventId/attendees", o.auth, o.eventAttendees)
+	o.POST("/events/:eventId/attendEvent", o.audit.Middleware("event.attend"), o.auth, o.eventsPassThrough)
+	o.POST("/events/:eventId/register", o.audit.Middleware("event.register"), o.auth, o.eventsPassThrough)
+	o.PUT("/events/:eventId/cover", o.audit.Middleware("event.cover"), o.auth, o.eventsUpload)
+	o.GET("/events/:eventId/live", o.auth, o.live.Handler(o.eventsURL))
+
+	notifications := o.live.Notifications(o.hub, o.cfg.Notifications.Heartbeat.Std())
+	o.GET("/notifications", o.auth, notifications)
+	o.GET("/events/:eventId/notifications", o.auth, notifications)
+}
+
+func (o *ControllerInterface) eventsURL(c *gin.Context) string {
+	u := o.cfg.EventService + strings.TrimPrefix(c.Request.URL.Path, "/api")
+	if c.Request.URL.RawQuery != "" {
+		u += "?" + c.Request.URL.RawQuery
+	}
+	return u
+}
+
+// eventAttendees passes attendee lists through and, with ?expand=users,
+// embeds each attendee's public profile under "user", null for users
+// that no longer exist.
+func (o *ControllerInterface) eventAttendees(c *gin.Context) {
+	query := c.Request.URL.Query()
+	expand, ok := query["expand"]
+	if !ok {
+		o.eventsPassThrough(c)
+		return
+	}
+	for _, v := range strings.Split(strings.Join(expand, ","), ",") {
+		if strings.TrimSpace(v) != "users" {
+			problem.Abort(c, problem.New(http.StatusBadRequest, "expand only supports users"))
+			return
+		}
+	}
+	query.Del("expand")
+	c.Request.URL.RawQuery = query.Encode()
+	o.forwardEvents(c, "application/json", o.expandUsers)
+}
+
+func (o *ControllerInterface) expandUsers(c *gin.Context, data interface{}) *problem.Problem {
+	items := utils.ListAt(data, o.cfg.Attendees.Path)
+	ids := make([]int32, 0, len(items))
+	for _, item := range items {
+		if id, ok := userID(item); ok {
+			ids = append(ids, id)
+		}
+	}
+
+	profiles, err := o.profiles.Get(c.Request.Context(), ids)
+	if err != nil {
+		return problem.FromGRPC(err, pb.AuthService_GetUserById_FullMethodName, o.statusMapping)
+	}
+
+	for i, item := range items {
+		var user interface{}
+		if id, ok := userID(item); ok && profiles[id] != nil {
+			user = users.PublicProfile(profiles[id])
+		}
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			obj = map[string]interface{}{"userId": item}
+			items[i] = obj
+		}
+		obj["user"] = user
+	}
+	return nil
+}
+
+func userID(item interface{}) (int32, bool) {
+	n, err := strconv.ParseInt(utils.ItemID(item), 10, 32)
+	return int32(n), err == nil
 }
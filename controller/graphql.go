@@ -0,0 +1,353 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"github.com/rekib0023/event-horizon-gateway/graph"
+	"github.com/rekib0023/event-horizon-gateway/logging"
+	"github.com/rekib0023/event-horizon-gateway/metrics"
+	"github.com/rekib0023/event-horizon-gateway/problem"
+	pb "github.com/rekib0023/event-horizon-gateway/proto"
+	"github.com/rekib0023/event-horizon-gateway/shaping"
+	"github.com/rekib0023/event-horizon-gateway/users"
+	"github.com/rekib0023/event-horizon-gateway/utils"
+)
+
+// loaderConcurrency bounds the calls a loader makes at once for backends
+// without a batch API.
+const loaderConcurrency = 8
+
+type graphContextKey struct{}
+
+// graphContext is what resolvers of one GraphQL request share: the
+// caller and the loaders that batch and cache their upstream calls.
+type graphContext struct {
+	c          *gin.Context
+	user       *pb.TokenVerification
+	users      *graph.Loader[string, *pb.UserResponse]
+	events     *graph.Loader[string, interface{}]
+	attendees  *graph.Loader[string, []string]
+	userEvents *graph.Loader[string, []interface{}]
+}
+
+func graphContextFrom(ctx context.Context) *graphContext {
+	return ctx.Value(graphContextKey{}).(*graphContext)
+}
+
+// userRef stands for a user known only by id, so that selecting just the
+// id of attendees costs no lookups.
+type userRef string
+
+func (o *ControllerInterface) InitGraphQLController() error {
+	schema, err := o.graphSchema()
+	if err != nil {
+		return fmt.Errorf("graphql schema: %w", err)
+	}
+	server, err := graph.NewServer(schema, o.cfg.GraphQL)
+	if err != nil {
+		return err
+	}
+	handler := server.Handler(o.graphContext)
+	o.GET("/graphql", o.auth, handler)
+	o.POST("/graphql", o.auth, handler)
+	return nil
+}
+
+func (o *ControllerInterface) graphContext(c *gin.Context) context.Context {
+	currentUser, _ := c.MustGet("user").(*pb.TokenVerification)
+	gc := &graphContext{c: c, user: currentUser}
+	gc.users = graph.NewLoader(utils.Parallel(loaderConcurrency, o.loadUser))
+	gc.events = graph.NewLoader(utils.Parallel(loaderConcurrency, func(ctx context.Context, id string) (interface{}, error) {
+		return o.eventsGet(ctx, gc, "/events/"+url.PathEscape(id), "GET /events/:eventId")
+	}))
+	gc.attendees = graph.NewLoader(utils.Parallel(loaderConcurrency, func(ctx context.Context, id string) ([]string, error) {
+		doc, err := o.eventsGet(ctx, gc, "/events/"+url.PathEscape(id)+"/attendees", "GET /events/:eventId/attendees")
+		return utils.CollectIDs(doc, o.cfg.Attendees.Path), err
+	}))
+	gc.userEvents = graph.NewLoader(utils.Parallel(loaderConcurrency, func(ctx context.Context, id string) ([]interface{}, error) {
+		doc, err := o.eventsGet(ctx, gc, "/users/"+url.PathEscape(id)+"/events", "GET /users/:userId/events")
+		return listOf(doc, "events"), err
+	}))
+	return context.WithValue(c.Request.Context(), graphContextKey{}, gc)
+}
+
+func (o *ControllerInterface) loadUser(ctx context.Context, id string) (*pb.UserResponse, error) {
+	n, err := strconv.ParseInt(id, 10, 32)
+	if err != nil {
+		return nil, graph.FromProblem(problem.New(http.StatusNotFound, "User not found"))
+	}
+	res, err := o.gRpc.GetUserById(ctx, &pb.UserId{Id: int32(n)})
+	if err != nil {
+		return nil, graph.FromProblem(problem.FromGRPC(err, pb.AuthService_GetUserById_FullMethodName, o.statusMapping))
+	}
+	return res, nil
+}
+
+// eventsGet fetches a JSON document from the event service as the caller.
+func (o *ControllerInterface) eventsGet(ctx context.Context, gc *graphContext, path, operation string) (interface{}, error) {
+	ctx = metrics.WithOperation(ctx, operation)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.cfg.EventService+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-User-ID", gc.user.GetId())
+	req.Header.Set("X-User-Email", gc.user.GetEmail())
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		logging.FromContext(ctx).Warn("upstream call failed", "upstream", "event-service", "error", err)
+		return nil, graph.FromProblem(problem.New(http.StatusBadGateway, "Event service is unavailable"))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, graph.FromProblem(problem.FromUpstream(resp))
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		logging.FromContext(ctx).Warn("could not decode upstream response", "upstream", "event-service", "error", err)
+		return nil, graph.FromProblem(problem.New(http.StatusBadGateway, "Invalid response from event service"))
+	}
+	return doc, nil
+}
+
+// shapeEvent applies the redaction rules of the REST event route to a
+// copy of event, which the loaders share between fields.
+func shapeEvent(c *gin.Context, event interface{}) (interface{}, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	data, err = shaping.FromContext(c).Route(http.MethodGet + " /api/events/:eventId").ShapeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc interface{}
+	err = dec.Decode(&doc)
+	return doc, err
+}
+
+// listOf returns the list doc is, or holds under key.
+func listOf(doc interface{}, key string) []interface{} {
+	if obj, ok := doc.(map[string]interface{}); ok {
+		doc = obj[key]
+	}
+	items, _ := doc.([]interface{})
+	return items
+}
+
+func (o *ControllerInterface) graphSchema() (graphql.Schema, error) {
+	jsonScalar := graphql.NewScalar(graphql.ScalarConfig{
+		Name:        "JSON",
+		Description: "A JSON document as returned by the event service.",
+		Serialize:   func(v interface{}) interface{} { return v },
+	})
+
+	userType := graphql.NewObject(graphql.ObjectConfig{Name: "User", Fields: graphql.Fields{}})
+	eventType := graphql.NewObject(graphql.ObjectConfig{Name: "Event", Fields: graphql.Fields{}})
+
+	userType.AddFieldConfig("id", &graphql.Field{
+		Type: graphql.NewNonNull(graphql.ID),
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			if ref, ok := p.Source.(userRef); ok {
+				return string(ref), nil
+			}
+			return strconv.Itoa(int(p.Source.(*pb.UserResponse).GetId())), nil
+		},
+	})
+	for name, get := range map[string]func(*pb.UserResponse) string{
+		"firstName": (*pb.UserResponse).GetFirstName,
+		"lastName":  (*pb.UserResponse).GetLastName,
+		"userName":  (*pb.UserResponse).GetUserName,
+	} {
+		get := get
+		userType.AddFieldConfig(name, &graphql.Field{Type: graphql.String, Resolve: userField(func(_ graphql.ResolveParams, u *pb.UserResponse) (interface{}, error) {
+			return get(u), nil
+		})})
+	}
+	userType.AddFieldConfig("email", &graphql.Field{
+		Type:        graphql.String,
+		Description: "Only visible to the user themselves and to admins.",
+		Resolve: userField(func(p graphql.ResolveParams, u *pb.UserResponse) (interface{}, error) {
+			if !shaping.FromContext(graphContextFrom(p.Context).c).Visible(emailVisibility, u.GetId()) {
+				return nil, nil
+			}
+			return u.GetEmail(), nil
+		}),
+	})
+	userType.AddFieldConfig("createdAt", &graphql.Field{Type: graphql.DateTime, Resolve: userField(func(_ graphql.ResolveParams, u *pb.UserResponse) (interface{}, error) {
+		return timeOf(u.GetCreatedAt().AsTime(), u.GetCreatedAt() != nil), nil
+	})})
+	userType.AddFieldConfig("updatedAt", &graphql.Field{Type: graphql.DateTime, Resolve: userField(func(_ graphql.ResolveParams, u *pb.UserResponse) (interface{}, error) {
+		return timeOf(u.GetUpdatedAt().AsTime(), u.GetUpdatedAt() != nil), nil
+	})})
+	userType.AddFieldConfig("events", &graphql.Field{
+		Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(eventType))),
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			id := string(refOf(p.Source))
+			return graphContextFrom(p.Context).userEvents.Load(p.Context, id), nil
+		},
+	})
+
+	eventType.AddFieldConfig("id", &graphql.Field{
+		Type: graphql.NewNonNull(graphql.ID),
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return eventID(p.Source), nil
+		},
+	})
+	eventType.AddFieldConfig("data", &graphql.Field{
+		Type:        jsonScalar,
+		Description: "The event as the event service describes it, shaped like GET /api/events/{eventId}.",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return shapeEvent(graphContextFrom(p.Context).c, p.Source)
+		},
+	})
+	eventType.AddFieldConfig("attendees", &graphql.Field{
+		Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(userType))),
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			load := graphContextFrom(p.Context).attendees.Load(p.Context, eventID(p.Source))
+			return func() (interface{}, error) {
+				ids, err := load()
+				if err != nil {
+					return nil, err
+				}
+				refs := []userRef{}
+				for _, id := range ids.([]string) {
+					refs = append(refs, userRef(id))
+				}
+				return refs, nil
+			}, nil
+		},
+	})
+
+	idArgs := graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}}
+	userPageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "UserPage",
+		Fields: graphql.Fields{
+			"users":         &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(userType)))},
+			"nextPageToken": &graphql.Field{Type: graphql.String},
+			"totalSize":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"me": &graphql.Field{
+				Type: userType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					gc := graphContextFrom(p.Context)
+					return gc.users.Load(p.Context, gc.user.GetId()), nil
+				},
+			},
+			"user": &graphql.Field{
+				Type: userType,
+				Args: idArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return graphContextFrom(p.Context).users.Load(p.Context, p.Args["id"].(string)), nil
+				},
+			},
+			"users": &graphql.Field{
+				Type:        graphql.NewNonNull(userPageType),
+				Description: "Pages through users like GET /api/users.",
+				Args: graphql.FieldConfigArgument{
+					"limit":     &graphql.ArgumentConfig{Type: graphql.Int},
+					"sort":      &graphql.ArgumentConfig{Type: graphql.String},
+					"email":     &graphql.ArgumentConfig{Type: graphql.String},
+					"userName":  &graphql.ArgumentConfig{Type: graphql.String},
+					"pageToken": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: o.resolveUsers,
+			},
+			"event": &graphql.Field{
+				Type: eventType,
+				Args: idArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return graphContextFrom(p.Context).events.Load(p.Context, p.Args["id"].(string)), nil
+				},
+			},
+		},
+	})
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query})
+}
+
+func (o *ControllerInterface) resolveUsers(p graphql.ResolveParams) (interface{}, error) {
+	values := url.Values{}
+	for arg, param := range map[string]string{"limit": "limit", "sort": "sort", "email": "email", "userName": "userName", "pageToken": "page_token"} {
+		if v, ok := p.Args[arg]; ok {
+			values.Set(param, fmt.Sprint(v))
+		}
+	}
+	query, prob := users.ParseQuery(values)
+	if prob == nil {
+		prob = checkEmailQuery(graphContextFrom(p.Context).c, query)
+	}
+	if prob != nil {
+		return nil, graph.FromProblem(prob)
+	}
+	page, err := o.userLister.List(p.Context, query)
+	if err != nil {
+		return nil, graph.FromProblem(problem.FromGRPC(err, pb.AuthService_GetUsers_FullMethodName, o.statusMapping))
+	}
+	return map[string]interface{}{
+		"users":         page.Users,
+		"nextPageToken": page.NextPageToken,
+		"totalSize":     page.TotalSize,
+	}, nil
+}
+
+// userField resolves a User field, loading the user first when only its
+// id is known.
+func userField(fn func(p graphql.ResolveParams, u *pb.UserResponse) (interface{}, error)) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		if u, ok := p.Source.(*pb.UserResponse); ok {
+			return fn(p, u)
+		}
+		load := graphContextFrom(p.Context).users.Load(p.Context, string(p.Source.(userRef)))
+		return func() (interface{}, error) {
+			u, err := load()
+			if err != nil {
+				return nil, err
+			}
+			return fn(p, u.(*pb.UserResponse))
+		}, nil
+	}
+}
+
+func refOf(source interface{}) userRef {
+	if u, ok := source.(*pb.UserResponse); ok {
+		return userRef(strconv.Itoa(int(u.GetId())))
+	}
+	return source.(userRef)
+}
+
+func eventID(doc interface{}) string {
+	if obj, ok := doc.(map[string]interface{}); ok {
+		for _, key := range []string{"id", "_id", "eventId"} {
+			if v, ok := obj[key]; ok && v != nil {
+				return fmt.Sprint(v)
+			}
+		}
+	}
+	return ""
+}
+
+func timeOf(t time.Time, set bool) interface{} {
+	if !set {
+		return nil
+	}
+	return t
+}
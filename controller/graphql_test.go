@@ -0,0 +1,156 @@
+package controller_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/gatewaytest"
+)
+
+type graphResult struct {
+	Data   map[string]any `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func TestGraphQLQuery(t *testing.T) {
+	h := gatewaytest.New(t, nil)
+	ada, bob := h.AddUser("ada@example.com"), h.AddUser("bob@example.com")
+	json := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, body)
+		}
+	}
+	h.Events.Handle(http.MethodGet, fmt.Sprintf("/users/%d/events", ada.Id), json(`{"events":[{"id":7},{"id":8}]}`))
+	h.Events.Handle(http.MethodGet, "/events/7/attendees", json(fmt.Sprintf(`{"attendees":[{"id":%d}]}`, bob.Id)))
+	h.Events.Handle(http.MethodGet, "/events/8/attendees", json(fmt.Sprintf(`{"attendees":[{"id":%d}]}`, bob.Id)))
+
+	query := map[string]any{"query": `{ me { userName events { id attendees { id userName } } } }`}
+	var res graphResult
+	h.Decode(h.DoAs(ada, http.MethodPost, "/api/graphql", query), &res)
+	if len(res.Errors) != 0 {
+		t.Fatalf("errors = %+v", res.Errors)
+	}
+	me := res.Data["me"].(map[string]any)
+	events := me["events"].([]any)
+	if me["userName"] != "ada@example.com" || len(events) != 2 {
+		t.Fatalf("me = %+v", me)
+	}
+	attendee := events[1].(map[string]any)["attendees"].([]any)[0].(map[string]any)
+	if attendee["id"] != fmt.Sprint(bob.Id) || attendee["userName"] != "bob@example.com" {
+		t.Fatalf("attendee = %+v", attendee)
+	}
+
+	// GET runs queries too.
+	res = graphResult{}
+	h.Decode(h.DoAs(ada, http.MethodGet, "/api/graphql?query="+url.QueryEscape(fmt.Sprintf(`{ user(id: "%d") { userName } }`, bob.Id)), nil), &res)
+	if user, _ := res.Data["user"].(map[string]any); len(res.Errors) != 0 || user["userName"] != "bob@example.com" {
+		t.Fatalf("GET result = %+v", res)
+	}
+}
+
+func TestGraphQLLimits(t *testing.T) {
+	h := gatewaytest.New(t, func(cfg *config.Config) {
+		cfg.GraphQL.MaxDepth = 3
+		cfg.GraphQL.MaxComplexity = 15
+	})
+	user := h.AddUser("ada@example.com")
+
+	for query, code := range map[string]string{
+		`{ me { events { attendees { events { id } } } } }`: "QUERY_TOO_DEEP",
+		`{ me { events { id data } } }`:                     "QUERY_TOO_COMPLEX",
+		`{ me { nope } }`:                                   "",
+	} {
+		var res graphResult
+		h.Decode(h.DoAs(user, http.MethodPost, "/api/graphql", map[string]any{"query": query}), &res)
+		if len(res.Errors) != 1 || (code != "" && res.Errors[0].Extensions["code"] != code) {
+			t.Fatalf("%s: errors = %+v, want %s", query, res.Errors, code)
+		}
+	}
+
+	h.ExpectStatus(h.DoAs(user, http.MethodPost, "/api/graphql", nil), http.StatusBadRequest)
+}
+
+func TestGraphQLPersistedQueries(t *testing.T) {
+	h := gatewaytest.New(t, nil)
+	user := h.AddUser("ada@example.com")
+	query := `{ me { userName } }`
+	sum := sha256.Sum256([]byte(query))
+	extensions := fmt.Sprintf(`{"persistedQuery":{"version":1,"sha256Hash":%q}}`, hex.EncodeToString(sum[:]))
+	path := "/api/graphql?extensions=" + url.QueryEscape(extensions)
+
+	var res graphResult
+	h.Decode(h.DoAs(user, http.MethodGet, path, nil), &res)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "PERSISTED_QUERY_NOT_FOUND" {
+		t.Fatalf("unknown hash: %+v", res)
+	}
+
+	res = graphResult{}
+	h.Decode(h.DoAs(user, http.MethodGet, path+"&query="+url.QueryEscape(query), nil), &res)
+	if len(res.Errors) != 0 {
+		t.Fatalf("register: %+v", res.Errors)
+	}
+
+	res = graphResult{}
+	h.Decode(h.DoAs(user, http.MethodGet, path, nil), &res)
+	if me, _ := res.Data["me"].(map[string]any); me["userName"] != "ada@example.com" {
+		t.Fatalf("persisted query: %+v", res)
+	}
+}
+
+func TestGraphQLEventDataIsShaped(t *testing.T) {
+	h := gatewaytest.New(t, func(cfg *config.Config) {
+		cfg.AdminUsers = []string{"admin@example.com"}
+		cfg.Shaping = map[string]config.ShapingRoute{
+			"GET /api/events/:eventId": {Rules: []config.ShapingRule{{Path: "budget", VisibleTo: []string{"admin"}}}},
+		}
+	})
+	user, admin := h.AddUser("ada@example.com"), h.AddUser("admin@example.com")
+	h.Events.Handle(http.MethodGet, "/events/7", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":7,"name":"Launch","budget":1000}`)
+	})
+
+	query := map[string]any{"query": `{ event(id: "7") { id data } }`}
+	for caller, wantBudget := range map[string]bool{user.GetToken(): false, admin.GetToken(): true} {
+		var res graphResult
+		h.Decode(h.Do(gatewaytest.As(h.Request(http.MethodPost, "/api/graphql", query), caller)), &res)
+		event, _ := res.Data["event"].(map[string]any)
+		data, _ := event["data"].(map[string]any)
+		if len(res.Errors) != 0 || data["name"] != "Launch" {
+			t.Fatalf("result = %+v", res)
+		}
+		if _, ok := data["budget"]; ok != wantBudget {
+			t.Fatalf("budget visible = %v, want %v: %+v", ok, wantBudget, data)
+		}
+	}
+}
+
+func TestGraphQLAttendeesPath(t *testing.T) {
+	h := gatewaytest.New(t, func(cfg *config.Config) { cfg.Attendees.Path = "data.people" })
+	ada, bob := h.AddUser("ada@example.com"), h.AddUser("bob@example.com")
+	h.Events.Handle(http.MethodGet, "/events/7", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":7}`)
+	})
+	h.Events.Handle(http.MethodGet, "/events/7/attendees", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"data":{"people":[{"userId":%d}]}}`, bob.Id)
+	})
+
+	var res graphResult
+	h.Decode(h.DoAs(ada, http.MethodPost, "/api/graphql", map[string]any{"query": `{ event(id: "7") { attendees { userName } } }`}), &res)
+	event, _ := res.Data["event"].(map[string]any)
+	attendees, _ := event["attendees"].([]any)
+	if len(res.Errors) != 0 || len(attendees) != 1 || attendees[0].(map[string]any)["userName"] != "bob@example.com" {
+		t.Fatalf("result = %+v", res)
+	}
+}
@@ -1,33 +1,81 @@
 package controller
 
 import (
+	"fmt"
+	"net/http"
+	"strings"
+
 	"github.com/gin-gonic/gin"
+	"github.com/rekib0023/event-horizon-gateway/admin"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/transcoder"
 )
 
-func POST(pattern string, handler gin.HandlerFunc) {
-	controller.r.POST(pattern, handler)
+func (o *ControllerInterface) POST(pattern string, handlers ...gin.HandlerFunc) {
+	o.HANDLE(http.MethodPost, pattern, handlers...)
+}
+
+func (o *ControllerInterface) GET(pattern string, handlers ...gin.HandlerFunc) {
+	o.HANDLE(http.MethodGet, pattern, handlers...)
+}
+
+func (o *ControllerInterface) PUT(pattern string, handlers ...gin.HandlerFunc) {
+	o.HANDLE(http.MethodPut, pattern, handlers...)
+}
+
+func (o *ControllerInterface) DELETE(pattern string, handlers ...gin.HandlerFunc) {
+	o.HANDLE(http.MethodDelete, pattern, handlers...)
 }
 
-func GET(pattern string, handler gin.HandlerFunc) {
-	controller.r.GET(pattern, handler)
+func (o *ControllerInterface) ANY(pattern string, handlers ...gin.HandlerFunc) {
+	o.r.Any(pattern, handlers...)
 }
 
-func PUT(pattern string, handler gin.HandlerFunc) {
-	controller.r.PUT(pattern, handler)
+func (o *ControllerInterface) HANDLE(method, pattern string, handlers ...gin.HandlerFunc) {
+	o.r.Handle(method, pattern, handlers...)
+	chain := append(append([]gin.HandlerFunc{}, o.r.Handlers...), handlers...)
+	o.chains[method+" "+joinPaths(o.r.BasePath(), pattern)] = admin.HandlerNames(chain)
 }
 
-func DELETE(pattern string, handler gin.HandlerFunc) {
-	controller.r.DELETE(pattern, handler)
+func (o *ControllerInterface) USE(middlewares ...gin.HandlerFunc) {
+	o.r.Use(middlewares...)
 }
 
-func ANY(pattern string, handler gin.HandlerFunc) {
-	controller.r.Any(pattern, handler)
+func joinPaths(base, pattern string) string {
+	if pattern == "" {
+		return base
+	}
+	return strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(pattern, "/")
 }
 
-func USE(middlewares ...gin.HandlerFunc) {
-	controller.r.Use(middlewares...)
+// registerRoutes exposes transcoded RPC routes, failing on routes that do not
+// match the proto descriptors.
+func (o *ControllerInterface) registerRoutes(routes []config.Route) error {
+	for _, route := range routes {
+		handler, err := o.transcoder.Handler(route)
+		if err != nil {
+			return fmt.Errorf("invalid route %s %s: %w", route.Method, route.Path, err)
+		}
+
+		var handlers []gin.HandlerFunc
+		if route.Audit != "" {
+			handlers = append(handlers, o.audit.Middleware(route.Audit))
+		}
+		if route.Auth {
+			handlers = append(handlers, o.auth)
+		}
+		o.HANDLE(route.Method, transcoder.GinPath(route.Path), append(handlers, handler)...)
+	}
+	return nil
 }
 
-func (o *ControllerInterface) jsonError(c *gin.Context, message string, statusCode int) {
-	c.JSON(statusCode, gin.H{"error": message})
+func (o *ControllerInterface) registerComposites(composites []config.Composite) error {
+	for _, composite := range composites {
+		handler, err := o.composer.Handler(composite)
+		if err != nil {
+			return fmt.Errorf("invalid composite %s: %w", composite.Path, err)
+		}
+		o.GET(transcoder.GinPath(composite.Path), o.auth, handler)
+	}
+	return nil
 }
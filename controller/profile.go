@@ -1,134 +1,173 @@
 package controller
 
 import (
-	"context"
-	"log"
 	"net/http"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"github.com/rekib0023/event-horizon-gateway/middlewares"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/problem"
 	pb "github.com/rekib0023/event-horizon-gateway/proto"
+	"github.com/rekib0023/event-horizon-gateway/render"
+	"github.com/rekib0023/event-horizon-gateway/shaping"
+	"github.com/rekib0023/event-horizon-gateway/transcoder"
+	"github.com/rekib0023/event-horizon-gateway/users"
 	"github.com/rekib0023/event-horizon-gateway/utils"
-	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
-type ProfileController struct {
-	gRpc pb.AuthServiceClient
+var profileRoutes = []config.Route{
+	{Method: http.MethodGet, Path: "/users/{userId}", RPC: pb.AuthService_GetUserById_FullMethodName, Fields: map[string]string{"userId": "id"}, Auth: true, Hooks: []string{"userETag"}},
+	{Method: http.MethodPut, Path: "/users/{userId}", RPC: pb.AuthService_UpdateUser_FullMethodName, Body: "user", Fields: map[string]string{"userId": "userId.id"}, Auth: true, Hooks: []string{"ifMatch", "invalidateUsers", "userETag"}, Audit: "user.update"},
+	{Method: http.MethodPatch, Path: "/users/{userId}", RPC: pb.AuthService_UpdateUser_FullMethodName, Body: "user", Fields: map[string]string{"userId": "userId.id"}, Auth: true, Hooks: []string{"ifMatch", "invalidateUsers", "userETag"}, Audit: "user.update"},
+	{Method: http.MethodDelete, Path: "/users/{userId}", RPC: pb.AuthService_DeleteUser_FullMethodName, Fields: map[string]string{"userId": "id"}, Status: http.StatusNoContent, Auth: true, Hooks: []string{"ifMatch", "invalidateUsers"}, Audit: "user.delete"},
 }
 
-var profileController *ProfileController
+var emailVisibility = config.ShapingRule{Path: "email", VisibleTo: []string{shaping.RoleSelf, shaping.RoleAdmin}}
 
-func (c *ControllerInterface) InitProfileController() {
-	profileController = &ProfileController{
-		gRpc: c.gRpc,
-	}
+var profileShaping = map[string]config.ShapingRoute{
+	"GET /api/users": {
+		Collection: "users",
+		Rules:      []config.ShapingRule{{Path: "users.*.email", VisibleTo: emailVisibility.VisibleTo}},
+	},
+	"GET /api/users/:userId":   {Rules: []config.ShapingRule{emailVisibility}},
+	"PUT /api/users/:userId":   {Rules: []config.ShapingRule{emailVisibility}},
+	"PATCH /api/users/:userId": {Rules: []config.ShapingRule{emailVisibility}},
+}
 
-	c.r.Use(middlewares.TokenAuthMiddleware(c.gRpc))
+func (c *ControllerInterface) InitProfileController() error {
+	c.transcoder.RegisterHook("invalidateUsers", transcoder.Hook{After: c.invalidateUsers})
+	c.transcoder.RegisterHook("userETag", transcoder.Hook{After: userETag})
+	c.transcoder.RegisterHook("ifMatch", transcoder.Hook{Before: c.ifMatch})
+
+	c.GET("/users", c.auth, c.getUsers)
+	if err := c.registerRoutes(profileRoutes); err != nil {
+		return err
+	}
 
-	GET("/users", profileController.getUsers)
-	GET("/users/:userId", profileController.getUserById)
-	PUT("/users/:userId", profileController.updateUser)
-	DELETE("/users/:userId", profileController.deleteUser)
-	GET("/users/:userId/events", c.eventsPassThrough)
+	c.GET("/users/:userId/events", c.auth, c.eventsPassThrough)
+	return nil
 }
 
-func (o *ProfileController) getUsers(c *gin.Context) {
-	_, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+func (o *ControllerInterface) getUsers(c *gin.Context) {
+	query, p := users.ParseQuery(c.Request.URL.Query())
+	if p == nil {
+		p = shaping.FromContext(c).CheckFields((&pb.UserListResponse{}).ProtoReflect().Descriptor())
+	}
+	if p == nil {
+		p = checkEmailQuery(c, query)
+	}
+	if p != nil {
+		problem.Abort(c, p)
 		return
 	}
 
-	res, err := o.gRpc.GetUsers(context.Background(), &pb.Empty{})
+	page, err := o.userLister.List(c.Request.Context(), query)
 	if err != nil {
-		log.Printf("could not call GetUsers: %v", err)
-		if s, ok := status.FromError(err); ok {
-			httpStatusCode := utils.GetHttpStatusCode(s.Code())
-			c.JSON(httpStatusCode, gin.H{"error": s.Message()})
-			return
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-			return
-		}
-	}
-	c.JSON(http.StatusOK, res)
+		problem.Abort(c, problem.FromGRPC(err, pb.AuthService_GetUsers_FullMethodName, o.statusMapping))
+		return
+	}
+
+	links := []string{utils.PageLink(c.Request.URL, "page_token", "", "first")}
+	if page.NextPageToken != "" {
+		links = append(links, utils.PageLink(c.Request.URL, "page_token", page.NextPageToken, "next"))
+	}
+	c.Header("Link", utils.JoinLinks(links...))
+
+	items := make([]interface{}, len(page.Users))
+	for i, u := range page.Users {
+		items[i] = u
+	}
+	o.renderer.Value(c, http.StatusOK, map[string]interface{}{
+		"users":           items,
+		"next_page_token": page.NextPageToken,
+		"total_size":      page.TotalSize,
+	})
 }
 
-func (o *ProfileController) getUserById(c *gin.Context) {
-	idStr := c.Param("userId")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		log.Printf("could not parse userId: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-		return
+// checkEmailQuery refuses email filters and sorts to callers who may not
+// see other users' emails. The owner is unknown, so only admins pass.
+func checkEmailQuery(c *gin.Context, query users.Query) *problem.Problem {
+	if query.UsesEmail() && !shaping.FromContext(c).Visible(emailVisibility, nil) {
+		return problem.New(http.StatusForbidden, "Only admins can filter or sort users by email")
 	}
+	return nil
+}
 
-	res, err := o.gRpc.GetUserById(context.Background(), &pb.UserId{Id: int32(id)})
-	if err != nil {
-		log.Printf("could not call GetUserById: %v", err)
-		if s, ok := status.FromError(err); ok {
-			httpStatusCode := utils.GetHttpStatusCode(s.Code())
-			c.JSON(httpStatusCode, gin.H{"error": s.Message()})
-			return
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-			return
-		}
-	}
-	c.JSON(http.StatusOK, res)
+// invalidateUsers drops the cached user list and profiles after a user
+// was changed through the gateway.
+func (o *ControllerInterface) invalidateUsers(c *gin.Context, res proto.Message) error {
+	if inv, ok := o.userLister.(interface{ Invalidate() }); ok {
+		inv.Invalidate()
+	}
+	o.profiles.Invalidate()
+	return nil
 }
 
-func (o *ProfileController) updateUser(c *gin.Context) {
-	idStr := c.Param("userId")
-	id, err := strconv.Atoi(idStr)
+// userETag tags user responses and answers a matching If-None-Match on
+// reads with 304. The tag names the representation: the user's version,
+// the negotiated media type and the fields the caller gets to see.
+func userETag(c *gin.Context, res proto.Message) error {
+	version, err := userVersion(res)
 	if err != nil {
-		log.Printf("could not parse userId: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-		return
+		return problem.New(http.StatusInternalServerError, "Internal server error")
 	}
+	etag := representationETag(c, version)
+	c.Header("ETag", etag)
 
-	var reqData pb.SignupRequest
-	if err := c.ShouldBindJSON(&reqData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
-		return
+	method := c.Request.Method
+	if (method == http.MethodGet || method == http.MethodHead) && utils.MatchETag(c.GetHeader("If-None-Match"), etag, true) {
+		c.Writer.Header().Add("Vary", "Accept")
+		c.AbortWithStatus(http.StatusNotModified)
 	}
+	return nil
+}
 
-	res, err := o.gRpc.UpdateUser(context.Background(), &pb.UpdateUserRequest{UserId: &pb.UserId{Id: int32(id)}, User: &pb.SignupRequest{FirstName: reqData.FirstName, LastName: reqData.LastName, UserName: reqData.UserName, Email: reqData.Email, Password: reqData.Password}})
-	if err != nil {
-		log.Printf("could not call Update: %v", err)
-		if s, ok := status.FromError(err); ok {
-			httpStatusCode := utils.GetHttpStatusCode(s.Code())
-			c.JSON(httpStatusCode, gin.H{"error": s.Message()})
-			return
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-			return
-		}
-	}
-	c.JSON(http.StatusOK, res)
+func representationETag(c *gin.Context, version string) string {
+	return utils.WithVariant(version, render.Negotiate(c)+";"+shaping.FromContext(c).Variant())
 }
 
-func (o *ProfileController) deleteUser(c *gin.Context) {
-	idStr := c.Param("userId")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		log.Printf("could not parse userId: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-		return
+// ifMatch rejects writes whose If-Match does not name the user's current
+// version, in any representation, read through GetUserById. Requests
+// without If-Match pass.
+func (o *ControllerInterface) ifMatch(c *gin.Context, req proto.Message) error {
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		return nil
+	}
+
+	var id int32
+	switch r := req.(type) {
+	case interface{ GetUserId() *pb.UserId }:
+		id = r.GetUserId().GetId()
+	case interface{ GetId() int32 }:
+		id = r.GetId()
+	default:
+		return problem.New(http.StatusInternalServerError, "Internal server error")
 	}
 
-	res, err := o.gRpc.DeleteUser(context.Background(), &pb.UserId{Id: int32(id)})
+	current, err := o.gRpc.GetUserById(c.Request.Context(), &pb.UserId{Id: id})
+	if err != nil {
+		return problem.FromGRPC(err, pb.AuthService_GetUserById_FullMethodName, o.statusMapping)
+	}
+	version, err := userVersion(current)
 	if err != nil {
-		log.Printf("could not call Delete: %v", err)
-		if s, ok := status.FromError(err); ok {
-			httpStatusCode := utils.GetHttpStatusCode(s.Code())
-			c.JSON(httpStatusCode, gin.H{"error": s.Message()})
-			return
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-			return
-		}
-	}
-	c.JSON(http.StatusNoContent, res)
+		return problem.New(http.StatusInternalServerError, "Internal server error")
+	}
+	if !utils.MatchVersion(ifMatch, version) {
+		p := problem.New(http.StatusPreconditionFailed, "The user was modified since it was read")
+		p.Headers = http.Header{"ETag": {representationETag(c, version)}}
+		return p
+	}
+	return nil
+}
+
+// userVersion tags a user by its content, leaving out the session token
+// some RPCs include.
+func userVersion(msg proto.Message) (string, error) {
+	if u, ok := msg.(*pb.UserResponse); ok && u.GetToken() != "" {
+		u = proto.Clone(u).(*pb.UserResponse)
+		u.Token = ""
+		msg = u
+	}
+	return utils.ETag(msg)
 }
@@ -0,0 +1,74 @@
+package controller_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/gatewaytest"
+)
+
+func TestListUsers(t *testing.T) {
+	h := gatewaytest.New(t, nil)
+	user := h.AddUser("ada@example.com")
+	h.AddUser("bob@example.com")
+
+	rec := h.DoAs(user, http.MethodGet, "/api/users?limit=1", nil)
+	h.ExpectStatus(rec, http.StatusOK)
+	var page struct {
+		Users         []map[string]any `json:"users"`
+		NextPageToken string           `json:"next_page_token"`
+		TotalSize     int              `json:"total_size"`
+	}
+	h.Decode(rec, &page)
+	if len(page.Users) != 1 || page.TotalSize != 2 || page.NextPageToken == "" {
+		t.Fatalf("page = %+v", page)
+	}
+
+	h.ExpectStatus(h.DoAs(user, http.MethodGet, "/api/users?limit=0", nil), http.StatusBadRequest)
+	h.ExpectStatus(h.DoAs(user, http.MethodGet, "/api/users?fields=id,userName", nil), http.StatusOK)
+	h.ExpectStatus(h.DoAs(user, http.MethodGet, "/api/users?fields=id,nickname", nil), http.StatusBadRequest)
+}
+
+func TestUnknownFieldsRejectedBeforeWrite(t *testing.T) {
+	h := gatewaytest.New(t, nil)
+	user := h.AddUser("ada@example.com")
+	path := fmt.Sprintf("/api/users/%d", user.Id)
+
+	h.ExpectStatus(h.DoAs(user, http.MethodPut, path+"?fields=nickname", map[string]any{"firstName": "Eve"}), http.StatusBadRequest)
+
+	var got map[string]any
+	h.Decode(h.DoAs(user, http.MethodGet, path, nil), &got)
+	if got["firstName"] == "Eve" {
+		t.Fatal("user was updated despite the rejected fields parameter")
+	}
+}
+
+func TestEmailQueriesNeedAdmin(t *testing.T) {
+	h := gatewaytest.New(t, func(cfg *config.Config) { cfg.AdminUsers = []string{"admin@example.com"} })
+	user := h.AddUser("ada@example.com")
+	admin := h.AddUser("admin@example.com")
+
+	for _, path := range []string{"/api/users?email=admin@example.com", "/api/users?sort=-email"} {
+		h.ExpectStatus(h.DoAs(user, http.MethodGet, path, nil), http.StatusForbidden)
+		h.ExpectStatus(h.DoAs(admin, http.MethodGet, path, nil), http.StatusOK)
+	}
+
+	query := map[string]any{"query": `{ users(email: "admin@example.com") { totalSize } }`}
+	var res struct {
+		Data   map[string]any `json:"data"`
+		Errors []struct {
+			Extensions map[string]any `json:"extensions"`
+		} `json:"errors"`
+	}
+	h.Decode(h.DoAs(user, http.MethodPost, "/api/graphql", query), &res)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["status"] != float64(http.StatusForbidden) {
+		t.Fatalf("graphql as user = %+v", res)
+	}
+	res.Errors = nil
+	h.Decode(h.DoAs(admin, http.MethodPost, "/api/graphql", query), &res)
+	if len(res.Errors) != 0 {
+		t.Fatalf("graphql as admin = %+v", res)
+	}
+}
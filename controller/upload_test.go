@@ -0,0 +1,50 @@
+package controller_test
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rekib0023/event-horizon-gateway/gatewaytest"
+)
+
+func TestCoverUpload(t *testing.T) {
+	h := gatewaytest.New(t, nil)
+	user := h.AddUser("ada@example.com")
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("cover", "cover.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte("not really a png"))
+	mw.Close()
+
+	req := gatewaytest.As(h.Request(http.MethodPut, "/api/events/7/cover", &body), user.GetToken())
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	h.ExpectStatus(h.Do(req), http.StatusOK)
+
+	proxied, _ := h.Events.LastRequest()
+	if proxied.Path != "/events/7/cover" || proxied.Header.Get("Content-Type") != mw.FormDataContentType() {
+		t.Fatalf("proxied %s with Content-Type %q", proxied.Path, proxied.Header.Get("Content-Type"))
+	}
+	if !strings.Contains(string(proxied.Body), "not really a png") {
+		t.Fatalf("proxied body = %q", proxied.Body)
+	}
+	h.ExpectProxiedHeader("X-User-Email", "ada@example.com")
+}
+
+func TestCoverUploadNeedsMultipart(t *testing.T) {
+	h := gatewaytest.New(t, nil)
+	user := h.AddUser("ada@example.com")
+
+	h.ExpectStatus(h.DoAs(user, http.MethodPut, "/api/events/7/cover", map[string]any{"cover": "x"}), http.StatusUnsupportedMediaType)
+	for _, r := range h.Events.Requests() {
+		if r.Path == "/events/7/cover" {
+			t.Fatalf("a rejected upload reached the event service")
+		}
+	}
+}
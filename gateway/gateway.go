@@ -0,0 +1,381 @@
+// Package gateway wires the API gateway from its config and collaborators
+// so that it can be embedded in other binaries and composed in tests.
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rekib0023/event-horizon-gateway/accesslog"
+	"github.com/rekib0023/event-horizon-gateway/admin"
+	"github.com/rekib0023/event-horizon-gateway/audit"
+	"github.com/rekib0023/event-horizon-gateway/compression"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/controller"
+	"github.com/rekib0023/event-horizon-gateway/health"
+	"github.com/rekib0023/event-horizon-gateway/httpcache"
+	"github.com/rekib0023/event-horizon-gateway/limits"
+	"github.com/rekib0023/event-horizon-gateway/live"
+	"github.com/rekib0023/event-horizon-gateway/logging"
+	"github.com/rekib0023/event-horizon-gateway/metrics"
+	"github.com/rekib0023/event-horizon-gateway/middlewares"
+	"github.com/rekib0023/event-horizon-gateway/notify"
+	"github.com/rekib0023/event-horizon-gateway/problem"
+	pb "github.com/rekib0023/event-horizon-gateway/proto"
+	"github.com/rekib0023/event-horizon-gateway/render"
+	"github.com/rekib0023/event-horizon-gateway/shaping"
+	"github.com/rekib0023/event-horizon-gateway/tracing"
+	"github.com/rekib0023/event-horizon-gateway/users"
+	"github.com/rekib0023/event-horizon-gateway/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// Gateway is a fully wired gateway and the resources it owns. Several
+// gateways can live in one process.
+type Gateway struct {
+	cfg        *config.Config
+	opts       options
+	engine     *gin.Engine
+	health     *health.Checker
+	metrics    *metrics.Metrics
+	controller *controller.ControllerInterface
+	conn       grpc.ClientConnInterface
+	// responseCache is nil when disabled.
+	responseCache *httpcache.Cache
+	live          *live.Proxy
+	hub           *notify.Hub
+	profiles      *users.Profiles
+	closers       []func(context.Context) error
+}
+
+func New(cfg *config.Config, opts ...Option) (*Gateway, error) {
+	g := &Gateway{cfg: cfg, opts: options{now: time.Now, logger: slog.Default()}}
+	for _, opt := range opts {
+		opt(&g.opts)
+	}
+	if g.opts.logLevel == nil {
+		g.opts.logLevel = new(slog.LevelVar)
+	}
+	if err := g.init(); err != nil {
+		g.Close(context.Background())
+		return nil, err
+	}
+	return g, nil
+}
+
+func (g *Gateway) init() error {
+	cfg, logger := g.cfg, g.opts.logger
+	statusMapping, err := utils.NewStatusMapping(cfg.GrpcStatusMapping)
+	if err != nil {
+		return fmt.Errorf("invalid gRPC status mapping: %w", err)
+	}
+
+	accessLogOutput, err := accesslog.Open(cfg.AccessLog)
+	if err != nil {
+		return fmt.Errorf("open access log: %w", err)
+	}
+	if f, ok := accessLogOutput.(*accesslog.RotatingFile); ok {
+		g.closers = append(g.closers, func(context.Context) error { return f.Close() })
+	}
+	accessLog, err := accesslog.New(accessLogOutput, cfg.AccessLog)
+	if err != nil {
+		return fmt.Errorf("invalid access log config: %w", err)
+	}
+
+	auditSinks, err := g.auditSinks()
+	if err != nil {
+		return err
+	}
+	auditRecorder := audit.NewRecorder(cfg.Audit.BufferSize, auditSinks...)
+	auditRecorder.Now = g.opts.now
+	g.closers = append(g.closers, auditRecorder.Close)
+
+	m := metrics.New()
+	g.metrics = m
+
+	e := gin.New()
+	if err := e.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	e.Use(m.Middleware(), tracing.Middleware(), logging.Middleware(logger), accessLog.Middleware(), limits.Middleware(cfg.Limits), compression.Middleware(cfg.Compression), gin.CustomRecovery(func(c *gin.Context, err any) {
+		logging.FromContext(c.Request.Context()).Error("panic recovered", "error", err)
+		problem.Abort(c, problem.New(http.StatusInternalServerError, "Internal server error"))
+	}))
+	e.Use(shaping.Middleware(controller.ShapingRoutes(cfg), cfg.AdminUsers))
+	e.HandleMethodNotAllowed = true
+	e.NoRoute(func(c *gin.Context) {
+		problem.Abort(c, problem.New(http.StatusNotFound, "No route matches "+c.Request.URL.Path))
+	})
+	e.NoMethod(func(c *gin.Context) {
+		problem.Abort(c, problem.New(http.StatusMethodNotAllowed, c.Request.Method+" is not allowed on "+c.Request.URL.Path))
+	})
+	g.engine = e
+
+	g.conn = g.opts.authConn
+	if g.conn == nil {
+		logger.Info("Dialing auth service", "address", cfg.AuthService)
+		dialOpts := append([]grpc.DialOption{grpc.WithInsecure(), grpc.WithChainUnaryInterceptor(m.UnaryClientInterceptor(), tracing.UnaryClientInterceptor(), logging.UnaryClientInterceptor(), accesslog.UnaryClientInterceptor())}, g.opts.dialOpts...)
+		conn, err := grpc.Dial(cfg.AuthService, dialOpts...)
+		if err != nil {
+			return fmt.Errorf("dial auth service: %w", err)
+		}
+		g.conn = conn
+		g.closers = append(g.closers, func(context.Context) error { return conn.Close() })
+	}
+
+	var eventTransport http.RoundTripper = m.Transport(metrics.UpstreamEvents, tracing.Transport(logging.Transport(accesslog.Transport(g.opts.eventTransport))))
+	if cfg.ResponseCache.MaxEntries > 0 {
+		rules := map[string]httpcache.Rule{}
+		for route, r := range cfg.ResponseCache.Routes {
+			rules[route] = httpcache.Rule{TTL: r.TTL.Std(), StaleWhileRevalidate: r.StaleWhileRevalidate.Std(), PerUser: r.PerUser}
+		}
+		g.responseCache = httpcache.New(eventTransport, cfg.ResponseCache.MaxEntries, cfg.ResponseCache.MaxBytes, rules)
+		g.responseCache.Now = g.opts.now
+		eventTransport = g.responseCache
+	}
+	httpClient := &http.Client{Transport: eventTransport}
+	g.health = health.New(g.conn, cfg.EventService, httpClient, cfg.Health)
+	g.health.Register(e)
+
+	userLister := g.opts.userLister
+	if userLister == nil {
+		snapshot := users.NewSnapshotLister(pb.NewAuthServiceClient(g.conn), cfg.UsersSnapshotTTL.Std())
+		snapshot.Now = g.opts.now
+		userLister = snapshot
+	}
+	g.profiles = users.NewProfiles(pb.NewAuthServiceClient(g.conn), cfg.Attendees.ProfileTTL.Std(), cfg.Attendees.Concurrency, cfg.Attendees.MaxProfiles)
+	g.profiles.Now = g.opts.now
+
+	g.live = live.New(cfg.Live, httpClient)
+
+	broker := g.opts.broker
+	if broker == nil {
+		broker = notify.NewMemoryBroker()
+	}
+	attendance := notify.NewEventAttendance(httpClient, cfg.EventService, cfg.Attendees.Path, cfg.Notifications.AttendanceTTL.Std())
+	attendance.Now = g.opts.now
+	g.hub = notify.NewHub(cfg.Notifications, broker, attendance)
+	g.hub.Now = g.opts.now
+	g.closers = append(g.closers, func(context.Context) error { g.hub.Close(); return nil })
+	if cfg.Notifications.Token != "" {
+		g.hub.Register(e)
+	}
+
+	g.controller, err = controller.Register(e, controller.Deps{
+		Config:        cfg,
+		AuthConn:      g.conn,
+		HTTPClient:    httpClient,
+		StatusMapping: statusMapping,
+		Renderer:      render.New(cfg.Render),
+		UserLister:    userLister,
+		Profiles:      g.profiles,
+		Audit:         auditRecorder,
+		Live:          g.live,
+		Hub:           g.hub,
+		Auth:          middlewares.TokenAuthMiddleware(pb.NewAuthServiceClient(g.conn), statusMapping, m),
+	})
+	if err != nil {
+		return err
+	}
+
+	if cfg.MetricsPort == "" {
+		e.GET("/metrics", gin.WrapH(m.Handler()))
+	}
+	return nil
+}
+
+func (g *Gateway) auditSinks() ([]audit.Sink, error) {
+	if g.opts.auditSinksSet {
+		return g.opts.auditSinks, nil
+	}
+	var sinks []audit.Sink
+	if g.cfg.Audit.File != "" {
+		fileSink, err := audit.OpenFileSink(g.cfg.Audit.File)
+		if err != nil {
+			return nil, fmt.Errorf("open audit log: %w", err)
+		}
+		g.closers = append(g.closers, func(context.Context) error { return fileSink.Close() })
+		sinks = append(sinks, fileSink)
+	}
+	if g.cfg.Audit.WebhookURL != "" {
+		sinks = append(sinks, audit.NewWebhookSink(g.cfg.Audit.WebhookURL))
+	}
+	return sinks, nil
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.engine.ServeHTTP(w, r)
+}
+
+func (g *Gateway) Health() *health.Checker {
+	return g.health
+}
+
+func (g *Gateway) Live() *live.Proxy {
+	return g.live
+}
+
+// Admin returns the admin API for the gateway.
+func (g *Gateway) Admin() *admin.Server {
+	s := &admin.Server{
+		Config:    g.cfg,
+		LogLevel:  g.opts.logLevel,
+		Routes:    g.controller.RouteTable,
+		Upstreams: g.upstreams,
+		Caches:    map[string]admin.Cache{},
+	}
+	if snapshot, ok := g.controller.UserLister().(*users.SnapshotLister); ok {
+		s.Caches["users"] = snapshotCache{snapshot}
+	}
+	s.Caches["profiles"] = profileCache{g.profiles}
+	if g.responseCache != nil {
+		s.Caches["events"] = responseCache{g.responseCache}
+	}
+	return s
+}
+
+// Run serves the gateway, and the metrics and admin listeners when they
+// have their own ports, until ctx is done. It then fails readiness for
+// cfg.DrainDelay and shuts down gracefully within cfg.ShutdownTimeout.
+func (g *Gateway) Run(ctx context.Context) error {
+	// The metrics and admin servers stop when Run returns, whichever way.
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errc := make(chan error, 3)
+	if g.cfg.MetricsPort != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", g.metrics.Handler())
+		g.opts.logger.Info("Serving metrics", "port", g.cfg.MetricsPort)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serve(ctx, errc, &http.Server{Addr: ":" + g.cfg.MetricsPort, Handler: mux})
+		}()
+	}
+	if g.cfg.Admin.Port != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := g.Admin().Serve(ctx); err != nil {
+				errc <- fmt.Errorf("admin server: %w", err)
+			}
+		}()
+	}
+
+	srv := limits.Server(&http.Server{Addr: ":" + g.cfg.Port, Handler: g}, g.cfg.Limits)
+	go func() {
+		g.opts.logger.Info("Starting server", "port", g.cfg.Port)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			errc <- err
+		}
+	}()
+
+	select {
+	case err := <-errc:
+		srv.Close()
+		return err
+	case <-ctx.Done():
+	}
+
+	g.opts.logger.Info("Draining", "delay", g.cfg.DrainDelay.Std())
+	g.health.SetDraining()
+	time.Sleep(g.cfg.DrainDelay.Std())
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), g.cfg.ShutdownTimeout.Std())
+	defer cancel()
+	// Live connections are hijacked or never go idle, so Shutdown would
+	// not wait for or end them.
+	if err := g.live.Shutdown(shutdownCtx); err != nil {
+		g.opts.logger.Warn("Live connections did not close", "error", err)
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("graceful shutdown: %w", err)
+	}
+	g.opts.logger.Info("Server stopped")
+	return nil
+}
+
+func serve(ctx context.Context, errc chan<- error, srv *http.Server) {
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		errc <- err
+	}
+}
+
+// Close releases the gateway's resources, flushing pending audit events.
+func (g *Gateway) Close(ctx context.Context) error {
+	var errs []error
+	for i := len(g.closers) - 1; i >= 0; i-- {
+		errs = append(errs, g.closers[i](ctx))
+	}
+	g.closers = nil
+	return errors.Join(errs...)
+}
+
+func (g *Gateway) upstreams() []admin.Upstream {
+	last := g.health.Last()
+	auth := admin.Upstream{Name: health.DependencyAuth, Address: g.cfg.AuthService}
+	if conn, ok := g.conn.(interface{ GetState() connectivity.State }); ok {
+		auth.State = conn.GetState().String()
+	}
+	events := admin.Upstream{Name: health.DependencyEvents, Address: g.cfg.EventService}
+	if s, ok := last[health.DependencyAuth]; ok {
+		auth.Health = &s
+	}
+	if s, ok := last[health.DependencyEvents]; ok {
+		events.Health = &s
+	}
+	return []admin.Upstream{auth, events}
+}
+
+type snapshotCache struct {
+	*users.SnapshotLister
+}
+
+func (c snapshotCache) Stats() admin.CacheStats {
+	s := c.SnapshotLister.Stats()
+	stats := admin.CacheStats{Entries: s.Users, Hits: s.Hits, Misses: s.Misses, TTL: s.TTL.String()}
+	if !s.FetchedAt.IsZero() {
+		stats.FetchedAt = &s.FetchedAt
+	}
+	return stats
+}
+
+func (c snapshotCache) Flush() {
+	c.Invalidate()
+}
+
+type profileCache struct {
+	*users.Profiles
+}
+
+func (c profileCache) Stats() admin.CacheStats {
+	s := c.Profiles.Stats()
+	return admin.CacheStats{Entries: s.Entries, Hits: s.Hits, Misses: s.Misses, TTL: s.TTL.String()}
+}
+
+func (c profileCache) Flush() {
+	c.Invalidate()
+}
+
+type responseCache struct {
+	*httpcache.Cache
+}
+
+func (c responseCache) Stats() admin.CacheStats {
+	s := c.Cache.Stats()
+	return admin.CacheStats{Entries: s.Entries, Hits: s.Hits + s.StaleHits, Misses: s.Misses + s.Revalidations, TTL: "per route"}
+}
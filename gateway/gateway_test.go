@@ -0,0 +1,139 @@
+package gateway_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/gateway"
+	"github.com/rekib0023/event-horizon-gateway/gatewaytest"
+	pb "github.com/rekib0023/event-horizon-gateway/proto"
+	"github.com/rekib0023/event-horizon-gateway/users"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+type stubLister struct{ page *users.Page }
+
+func (l stubLister) List(ctx context.Context, q users.Query) (*users.Page, error) {
+	return l.page, nil
+}
+
+func TestOptionsReplaceCollaborators(t *testing.T) {
+	var proxied *http.Request
+	transport := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		proxied = r
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"id":"from-transport"}`)),
+			Request:    r,
+		}, nil
+	})
+	lister := stubLister{page: &users.Page{Users: []*pb.UserResponse{{Id: 7, UserName: "stub"}}, TotalSize: 1}}
+
+	h := gatewaytest.New(t, nil, gateway.WithEventTransport(transport), gateway.WithUserLister(lister))
+	user := h.AddUser("ada@example.com")
+
+	rec := h.DoAs(user, http.MethodGet, "/api/events/1", nil)
+	h.ExpectStatus(rec, http.StatusOK)
+	if proxied == nil || proxied.Header.Get("X-User-Email") != "ada@example.com" {
+		t.Fatalf("transport saw %v", proxied)
+	}
+	if !strings.Contains(rec.Body.String(), "from-transport") {
+		t.Fatalf("body = %s", rec.Body.String())
+	}
+
+	rec = h.DoAs(user, http.MethodGet, "/api/users", nil)
+	h.ExpectStatus(rec, http.StatusOK)
+	if !strings.Contains(rec.Body.String(), `"userName":"stub"`) {
+		t.Fatalf("users = %s", rec.Body.String())
+	}
+}
+
+func TestNewRejectsInvalidConfig(t *testing.T) {
+	cfg := config.Default()
+	cfg.GrpcStatusMapping = map[string]map[string]int{"auth.AuthService": {"NOT_A_CODE": 400}}
+	if _, err := gateway.New(cfg); err == nil {
+		t.Fatalf("New accepted an unknown gRPC code")
+	}
+}
+
+func TestRunStopsWithContext(t *testing.T) {
+	h := gatewaytest.New(t, func(cfg *config.Config) {
+		cfg.DrainDelay = 0
+		cfg.ShutdownTimeout = config.Duration(time.Second)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- h.Gateway.Run(ctx) }()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Run did not return after cancel")
+	}
+}
+
+func freePort(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	return strconv.Itoa(lis.Addr().(*net.TCPAddr).Port)
+}
+
+func TestRunStopsSideServersOnError(t *testing.T) {
+	busy, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	metricsPort, adminPort := freePort(t), freePort(t)
+
+	h := gatewaytest.New(t, func(cfg *config.Config) {
+		cfg.Port = strconv.Itoa(busy.Addr().(*net.TCPAddr).Port)
+		cfg.MetricsPort = metricsPort
+		cfg.Admin.Port = adminPort
+		cfg.Admin.Bind = "127.0.0.1"
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- h.Gateway.Run(context.Background()) }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("Run succeeded on a port in use")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the main listener failed")
+	}
+
+	// A leaked server may still be starting, so keep checking for a while.
+	deadline := time.Now().Add(300 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		for _, port := range []string{metricsPort, adminPort} {
+			if conn, err := net.Dial("tcp", "127.0.0.1:"+port); err == nil {
+				conn.Close()
+				t.Fatalf("port %s still served after Run returned", port)
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
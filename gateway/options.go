@@ -0,0 +1,79 @@
+package gateway
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/rekib0023/event-horizon-gateway/audit"
+	"github.com/rekib0023/event-horizon-gateway/notify"
+	"github.com/rekib0023/event-horizon-gateway/users"
+	"google.golang.org/grpc"
+)
+
+type Option func(*options)
+
+type options struct {
+	authConn       grpc.ClientConnInterface
+	dialOpts       []grpc.DialOption
+	eventTransport http.RoundTripper
+	now            func() time.Time
+	logger         *slog.Logger
+	logLevel       *slog.LevelVar
+	userLister     users.Lister
+	auditSinks     []audit.Sink
+	auditSinksSet  bool
+	broker         notify.Broker
+}
+
+// WithAuthConn uses conn for auth service calls instead of dialing
+// cfg.AuthService. Client interceptors are the caller's responsibility.
+func WithAuthConn(conn grpc.ClientConnInterface) Option {
+	return func(o *options) { o.authConn = conn }
+}
+
+// WithDialOptions adds options to the auth service connection, e.g. to
+// dial an in-memory listener.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(o *options) { o.dialOpts = append(o.dialOpts, opts...) }
+}
+
+// WithEventTransport sends event service requests through rt. The
+// gateway's instrumentation still wraps it.
+func WithEventTransport(rt http.RoundTripper) Option {
+	return func(o *options) { o.eventTransport = rt }
+}
+
+func WithClock(now func() time.Time) Option {
+	return func(o *options) { o.now = now }
+}
+
+// WithLogger sets the logger request loggers derive from. It defaults to
+// slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) { o.logger = logger }
+}
+
+// WithLogLevel lets the admin API change the level of the logger.
+func WithLogLevel(level *slog.LevelVar) Option {
+	return func(o *options) { o.logLevel = level }
+}
+
+// WithUserLister replaces the cached GetUsers snapshot behind GET /users.
+func WithUserLister(l users.Lister) Option {
+	return func(o *options) { o.userLister = l }
+}
+
+// WithAuditSinks replaces the audit sinks named in the config.
+func WithAuditSinks(sinks ...audit.Sink) Option {
+	return func(o *options) {
+		o.auditSinks = sinks
+		o.auditSinksSet = true
+	}
+}
+
+// WithBroker carries notifications through b. It defaults to an in-memory
+// broker, which only reaches clients of this gateway.
+func WithBroker(b notify.Broker) Option {
+	return func(o *options) { o.broker = b }
+}
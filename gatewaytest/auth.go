@@ -0,0 +1,207 @@
+package gatewaytest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	pb "github.com/rekib0023/event-horizon-gateway/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// AuthServer is an in-memory pb.AuthServiceServer. Tokens are opaque
+// strings valid until refreshed or until their user is deleted.
+type AuthServer struct {
+	pb.UnimplementedAuthServiceServer
+
+	Now func() time.Time
+
+	mu        sync.Mutex
+	nextID    int32
+	nextToken int
+	users     map[int32]*pb.UserResponse
+	passwords map[int32]string
+	tokens    map[string]int32
+	failures  map[string]error
+}
+
+func NewAuthServer() *AuthServer {
+	return &AuthServer{
+		Now:       time.Now,
+		users:     map[int32]*pb.UserResponse{},
+		passwords: map[int32]string{},
+		tokens:    map[string]int32{},
+		failures:  map[string]error{},
+	}
+}
+
+// Fail makes calls to fullMethod return err until it is set back to nil.
+func (s *AuthServer) Fail(fullMethod string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[fullMethod] = err
+}
+
+func (s *AuthServer) intercept(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	s.mu.Lock()
+	err := s.failures[info.FullMethod]
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// SetNextID makes id the id of the next user added, e.g. to test ids that
+// do not fit in a small float.
+func (s *AuthServer) SetNextID(id int32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID = id - 1
+}
+
+// AddUser stores a user and returns it with a valid token.
+func (s *AuthServer) AddUser(req *pb.SignupRequest) *pb.UserResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.add(req)
+}
+
+func (s *AuthServer) add(req *pb.SignupRequest) *pb.UserResponse {
+	s.nextID++
+	now := &timestamp.Timestamp{Seconds: s.Now().Unix()}
+	u := &pb.UserResponse{
+		Id:        s.nextID,
+		FirstName: req.GetFirstName(),
+		LastName:  req.GetLastName(),
+		UserName:  req.GetUserName(),
+		Email:     req.GetEmail(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	s.users[u.Id] = u
+	s.passwords[u.Id] = req.GetPassword()
+	return s.withToken(u)
+}
+
+func (s *AuthServer) withToken(u *pb.UserResponse) *pb.UserResponse {
+	s.nextToken++
+	token := fmt.Sprintf("token-%d-%d", u.Id, s.nextToken)
+	s.tokens[token] = u.Id
+	res := s.clone(u)
+	res.Token = token
+	return res
+}
+
+func (s *AuthServer) clone(u *pb.UserResponse) *pb.UserResponse {
+	c := proto.Clone(u).(*pb.UserResponse)
+	c.Token = ""
+	return c
+}
+
+func (s *AuthServer) Signup(ctx context.Context, req *pb.SignupRequest) (*pb.UserResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if u.Email == req.GetEmail() {
+			return nil, status.Error(codes.AlreadyExists, "email already registered")
+		}
+	}
+	return s.add(req), nil
+}
+
+func (s *AuthServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.UserResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, u := range s.users {
+		if u.Email == req.GetEmail() && s.passwords[id] == req.GetPassword() {
+			return s.withToken(u), nil
+		}
+	}
+	return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+}
+
+func (s *AuthServer) VerifyToken(ctx context.Context, req *pb.Token) (*pb.TokenVerification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, ok := s.tokens[req.GetToken()]
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	return &pb.TokenVerification{Id: strconv.Itoa(int(id)), Email: s.users[id].Email}, nil
+}
+
+func (s *AuthServer) RefreshToken(ctx context.Context, req *pb.Token) (*pb.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, ok := s.tokens[req.GetToken()]
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	delete(s.tokens, req.GetToken())
+	return &pb.Token{Token: s.withToken(s.users[id]).Token}, nil
+}
+
+func (s *AuthServer) GetUsers(ctx context.Context, req *pb.Empty) (*pb.UserListResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := &pb.UserListResponse{}
+	for _, u := range s.users {
+		res.Users = append(res.Users, s.clone(u))
+	}
+	sort.Slice(res.Users, func(i, j int) bool { return res.Users[i].Id < res.Users[j].Id })
+	return res, nil
+}
+
+func (s *AuthServer) GetUserById(ctx context.Context, req *pb.UserId) (*pb.UserResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[req.GetId()]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	return s.clone(u), nil
+}
+
+func (s *AuthServer) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.UserResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[req.GetUserId().GetId()]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	patch := req.GetUser()
+	for dst, v := range map[*string]string{&u.FirstName: patch.GetFirstName(), &u.LastName: patch.GetLastName(), &u.UserName: patch.GetUserName(), &u.Email: patch.GetEmail()} {
+		if v != "" {
+			*dst = v
+		}
+	}
+	if patch.GetPassword() != "" {
+		s.passwords[u.Id] = patch.GetPassword()
+	}
+	u.UpdatedAt = &timestamp.Timestamp{Seconds: s.Now().Unix()}
+	return s.clone(u), nil
+}
+
+func (s *AuthServer) DeleteUser(ctx context.Context, req *pb.UserId) (*pb.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[req.GetId()]; !ok {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	delete(s.users, req.GetId())
+	delete(s.passwords, req.GetId())
+	for token, id := range s.tokens {
+		if id == req.GetId() {
+			delete(s.tokens, token)
+		}
+	}
+	return &pb.Empty{}, nil
+}
@@ -0,0 +1,75 @@
+package gatewaytest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// Request is a request received by the fake event service.
+type Request struct {
+	Method   string
+	Path     string
+	RawQuery string
+	Header   http.Header
+	Body     []byte
+}
+
+// EventService is an httptest event service that records every request.
+// Unhandled requests are answered with a JSON echo of the method and path.
+type EventService struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []Request
+	handlers map[string]http.HandlerFunc
+}
+
+func NewEventService() *EventService {
+	s := &EventService{handlers: map[string]http.HandlerFunc{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Handle answers method and path with h instead of the echo.
+func (s *EventService) Handle(method, path string, h http.HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method+" "+path] = h
+}
+
+func (s *EventService) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// LastRequest returns the most recent request other than health probes.
+func (s *EventService) LastRequest() (Request, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.requests) - 1; i >= 0; i-- {
+		if s.requests[i].Path != "/health" {
+			return s.requests[i], true
+		}
+	}
+	return Request{}, false
+}
+
+func (s *EventService) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, RawQuery: r.URL.RawQuery, Header: r.Header.Clone(), Body: body})
+	h := s.handlers[r.Method+" "+r.URL.Path]
+	s.mu.Unlock()
+
+	if h != nil {
+		h(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"method": r.Method, "path": r.URL.Path})
+}
@@ -0,0 +1,150 @@
+// Package gatewaytest runs a gateway in-process against an in-memory auth
+// service and an httptest event service.
+package gatewaytest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/gateway"
+	"github.com/rekib0023/event-horizon-gateway/notify"
+	pb "github.com/rekib0023/event-horizon-gateway/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type Harness struct {
+	t       testing.TB
+	Config  *config.Config
+	Gateway *gateway.Gateway
+	Auth    *AuthServer
+	Events  *EventService
+	// Broker delivers notifications to the gateway's hub.
+	Broker *notify.MemoryBroker
+}
+
+// New starts the fake backends and a gateway wired to them. configure can
+// adjust the config and opts are passed to gateway.New. Everything is torn
+// down when the test ends.
+func New(t testing.TB, configure func(*config.Config), opts ...gateway.Option) *Harness {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	h := &Harness{t: t, Auth: NewAuthServer(), Events: NewEventService(), Broker: notify.NewMemoryBroker()}
+	t.Cleanup(h.Events.Close)
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(grpc.UnaryInterceptor(h.Auth.intercept))
+	pb.RegisterAuthServiceServer(srv, h.Auth)
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	cfg := config.Default()
+	cfg.Port = "0"
+	cfg.AuthService = "bufnet"
+	cfg.EventService = h.Events.URL
+	cfg.AccessLog.Output = "off"
+	cfg.Audit.File = ""
+	if configure != nil {
+		configure(cfg)
+	}
+	h.Config = cfg
+
+	dial := gateway.WithDialOptions(grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.DialContext(ctx)
+	}))
+	gw, err := gateway.New(cfg, append([]gateway.Option{dial, gateway.WithBroker(h.Broker)}, opts...)...)
+	if err != nil {
+		t.Fatalf("gatewaytest: build gateway: %v", err)
+	}
+	t.Cleanup(func() { gw.Close(context.Background()) })
+	h.Gateway = gw
+	return h
+}
+
+// Request builds a request to the gateway. A non-nil body is sent as JSON
+// unless it is already an io.Reader.
+func (h *Harness) Request(method, path string, body any) *http.Request {
+	h.t.Helper()
+	var r io.Reader
+	switch b := body.(type) {
+	case nil:
+	case io.Reader:
+		r = b
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			h.t.Fatalf("gatewaytest: encode body: %v", err)
+		}
+		r = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, r)
+	if r != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req
+}
+
+// As authenticates req with token the way browsers do, via the token
+// cookie.
+func As(req *http.Request, token string) *http.Request {
+	req.AddCookie(&http.Cookie{Name: "token", Value: token})
+	return req
+}
+
+func (h *Harness) Do(req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.Gateway.ServeHTTP(rec, req)
+	return rec
+}
+
+// DoAs issues an authenticated request as user, which must carry a token
+// such as the one returned by Auth.AddUser.
+func (h *Harness) DoAs(user *pb.UserResponse, method, path string, body any) *httptest.ResponseRecorder {
+	return h.Do(As(h.Request(method, path, body), user.GetToken()))
+}
+
+// AddUser registers a user with the fake auth service.
+func (h *Harness) AddUser(email string) *pb.UserResponse {
+	return h.Auth.AddUser(&pb.SignupRequest{Email: email, UserName: email, Password: "password"})
+}
+
+// ExpectStatus fails the test unless rec has the given status.
+func (h *Harness) ExpectStatus(rec *httptest.ResponseRecorder, want int) {
+	h.t.Helper()
+	if rec.Code != want {
+		h.t.Fatalf("status = %d, want %d; body: %s", rec.Code, want, rec.Body.String())
+	}
+}
+
+// Decode decodes the JSON body of rec into v.
+func (h *Harness) Decode(rec *httptest.ResponseRecorder, v any) {
+	h.t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		h.t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+}
+
+// ExpectProxiedHeader fails the test unless the last request proxied to
+// the event service carried header name with value want.
+func (h *Harness) ExpectProxiedHeader(name, want string) {
+	h.t.Helper()
+	req, ok := h.Events.LastRequest()
+	if !ok {
+		h.t.Fatalf("no request reached the event service")
+	}
+	if got := req.Header.Get(name); got != want {
+		h.t.Fatalf("proxied %s = %q, want %q", name, got, want)
+	}
+}
@@ -0,0 +1,92 @@
+package gatewaytest_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rekib0023/event-horizon-gateway/gatewaytest"
+)
+
+func TestEventsProxyForwardsUser(t *testing.T) {
+	h := gatewaytest.New(t, nil)
+	user := h.AddUser("ada@example.com")
+
+	rec := h.DoAs(user, http.MethodGet, "/api/events/42?status=open", nil)
+	h.ExpectStatus(rec, http.StatusOK)
+	h.ExpectProxiedHeader("X-User-ID", fmt.Sprint(user.Id))
+	h.ExpectProxiedHeader("X-User-Email", "ada@example.com")
+	req, _ := h.Events.LastRequest()
+	if req.Path != "/events/42" || req.RawQuery != "status=open" {
+		t.Fatalf("proxied %s?%s", req.Path, req.RawQuery)
+	}
+}
+
+func TestEventsProxyRejectsAnonymous(t *testing.T) {
+	h := gatewaytest.New(t, nil)
+
+	h.ExpectStatus(h.Do(h.Request(http.MethodGet, "/api/events/42", nil)), http.StatusUnauthorized)
+	if _, ok := h.Events.LastRequest(); ok {
+		t.Fatalf("an anonymous request reached the event service")
+	}
+}
+
+func TestLogin(t *testing.T) {
+	h := gatewaytest.New(t, nil)
+	h.AddUser("ada@example.com")
+
+	rec := h.Do(h.Request(http.MethodPost, "/api/auth/login", map[string]string{"email": "ada@example.com", "password": "password"}))
+	h.ExpectStatus(rec, http.StatusOK)
+	var token string
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "token" {
+			token = c.Value
+		}
+	}
+	if token == "" {
+		t.Fatalf("login set no token cookie")
+	}
+	h.ExpectStatus(h.Do(gatewaytest.As(h.Request(http.MethodGet, "/api/events/1", nil), token)), http.StatusOK)
+
+	rec = h.Do(h.Request(http.MethodPost, "/api/auth/login", map[string]string{"email": "ada@example.com", "password": "wrong"}))
+	if rec.Code < 400 || rec.Code >= 500 {
+		t.Fatalf("login with a wrong password: status %d", rec.Code)
+	}
+}
+
+// TestTwoGateways runs gateways side by side, which package-level state
+// used to prevent: each must talk to its own backends and keep its own
+// metrics.
+func TestTwoGateways(t *testing.T) {
+	a := gatewaytest.New(t, nil)
+	b := gatewaytest.New(t, nil)
+	userA := a.AddUser("ada@example.com")
+	userB := b.AddUser("bob@example.com")
+
+	a.ExpectStatus(a.DoAs(userA, http.MethodGet, "/api/events/1", nil), http.StatusOK)
+	a.ExpectStatus(a.DoAs(userA, http.MethodGet, "/api/events/1", nil), http.StatusOK)
+	b.ExpectStatus(b.DoAs(userB, http.MethodGet, "/api/events/2", nil), http.StatusOK)
+	a.ExpectProxiedHeader("X-User-Email", "ada@example.com")
+	b.ExpectProxiedHeader("X-User-Email", "bob@example.com")
+	if len(a.Events.Requests()) != 2 || len(b.Events.Requests()) != 1 {
+		t.Fatalf("event services saw %d and %d requests", len(a.Events.Requests()), len(b.Events.Requests()))
+	}
+
+	count := func(h *gatewaytest.Harness) string {
+		rec := h.Do(h.Request(http.MethodGet, "/metrics", nil))
+		h.ExpectStatus(rec, http.StatusOK)
+		for _, line := range strings.Split(rec.Body.String(), "\n") {
+			if strings.HasPrefix(line, "gateway_http_requests_total") && strings.Contains(line, `route="/api/events/:eventId"`) {
+				return line[strings.LastIndex(line, " ")+1:]
+			}
+		}
+		return ""
+	}
+	if got := count(a); got != "2" {
+		t.Errorf("gateway a counted %q requests, want 2", got)
+	}
+	if got := count(b); got != "1" {
+		t.Errorf("gateway b counted %q requests, want 1", got)
+	}
+}
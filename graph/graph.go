@@ -0,0 +1,244 @@
+// Package graph serves GraphQL over HTTP with query depth and complexity
+// limits, persisted queries and batching loaders for resolvers.
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/location"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/problem"
+)
+
+// Error codes reported in the "extensions" of GraphQL errors.
+const (
+	CodeBadRequest             = "BAD_REQUEST"
+	CodeQueryTooDeep           = "QUERY_TOO_DEEP"
+	CodeQueryTooComplex        = "QUERY_TOO_COMPLEX"
+	CodePersistedQueryNotFound = "PERSISTED_QUERY_NOT_FOUND"
+	CodePersistedQueryMismatch = "PERSISTED_QUERY_HASH_MISMATCH"
+	CodePersistedQueryRequired = "PERSISTED_QUERY_REQUIRED"
+	CodePersistedQueryVersion  = "PERSISTED_QUERY_VERSION_UNSUPPORTED"
+)
+
+type Server struct {
+	schema  graphql.Schema
+	cfg     config.GraphQL
+	queries *queryStore
+}
+
+func NewServer(schema graphql.Schema, cfg config.GraphQL) (*Server, error) {
+	queries, err := loadQueries(cfg.PersistedQueries, cfg.MaxRegistered)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{schema: schema, cfg: cfg, queries: queries}, nil
+}
+
+// Request is a GraphQL-over-HTTP request, with the persisted query
+// extension of automatic persisted queries.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	Extensions    struct {
+		PersistedQuery *struct {
+			Version    int    `json:"version"`
+			SHA256Hash string `json:"sha256Hash"`
+		} `json:"persistedQuery,omitempty"`
+	} `json:"extensions"`
+}
+
+// Handler serves GET requests, which may only run queries, and POST
+// requests with a JSON or application/graphql body. prepare returns the
+// context resolvers run with, e.g. carrying per-request loaders.
+func (s *Server) Handler(prepare func(c *gin.Context) context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, p := readRequest(c)
+		if p != nil {
+			problem.Abort(c, p)
+			return
+		}
+		c.JSON(http.StatusOK, s.Execute(prepare(c), req, c.Request.Method == http.MethodGet))
+	}
+}
+
+func readRequest(c *gin.Context) (Request, *problem.Problem) {
+	var req Request
+	invalid := problem.New(http.StatusBadRequest, "Invalid GraphQL request")
+
+	if c.Request.Method == http.MethodGet {
+		req.Query = c.Query("query")
+		req.OperationName = c.Query("operationName")
+		for param, dst := range map[string]any{"variables": &req.Variables, "extensions": &req.Extensions} {
+			if v := c.Query(param); v != "" {
+				if err := json.Unmarshal([]byte(v), dst); err != nil {
+					return req, invalid.WithFieldError(param, "must be a JSON object")
+				}
+			}
+		}
+		return req, nil
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if p := problem.FromBodyError(err); p != nil {
+		return req, p
+	}
+	if err != nil {
+		return req, invalid
+	}
+	switch mediaType, _, _ := mime.ParseMediaType(c.ContentType()); mediaType {
+	case "application/graphql":
+		req.Query = string(body)
+	case "application/json", "":
+		if err := json.Unmarshal(body, &req); err != nil {
+			return req, invalid
+		}
+	default:
+		return req, problem.New(http.StatusUnsupportedMediaType, "GraphQL requests must be application/json or application/graphql")
+	}
+	return req, nil
+}
+
+// Execute runs req. readOnly refuses mutations, for GET requests.
+func (s *Server) Execute(ctx context.Context, req Request, readOnly bool) *graphql.Result {
+	query, res := s.query(req)
+	if res != nil {
+		return res
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(query), Name: "GraphQL request"})})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+	if vr := graphql.ValidateDocument(&s.schema, doc, nil); !vr.IsValid {
+		return &graphql.Result{Errors: vr.Errors}
+	}
+	op, err := operation(doc, req.OperationName)
+	if err != nil {
+		return errorResult(CodeBadRequest, err.Error())
+	}
+	if readOnly && op.Operation != ast.OperationTypeQuery {
+		return errorResult(CodeBadRequest, "Only queries can be sent with GET")
+	}
+
+	depth, complexity := cost(&s.schema, doc, op, s.cfg.ListCost)
+	if s.cfg.MaxDepth > 0 && depth > s.cfg.MaxDepth {
+		return errorResult(CodeQueryTooDeep, "Query is too deep")
+	}
+	if s.cfg.MaxComplexity > 0 && complexity > s.cfg.MaxComplexity {
+		return errorResult(CodeQueryTooComplex, "Query is too complex")
+	}
+
+	res = graphql.Execute(graphql.ExecuteParams{
+		Schema:        s.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       ctx,
+	})
+	restoreExtensions(res.Errors)
+	return res
+}
+
+// restoreExtensions recovers the extensions of errors returned by thunks,
+// which graphql-go formats before locating them and so drops.
+func restoreExtensions(errs []gqlerrors.FormattedError) {
+	for i, e := range errs {
+		located, ok := e.OriginalError().(*gqlerrors.Error)
+		if e.Extensions != nil || !ok {
+			continue
+		}
+		if inner, ok := located.OriginalError.(gqlerrors.FormattedError); ok {
+			if extended, ok := inner.OriginalError().(gqlerrors.ExtendedError); ok {
+				errs[i].Extensions = extended.Extensions()
+			}
+		}
+	}
+}
+
+// query resolves the query text, looking it up or registering it when
+// the request carries a persisted query hash.
+func (s *Server) query(req Request) (string, *graphql.Result) {
+	pq := req.Extensions.PersistedQuery
+	if pq == nil {
+		if req.Query == "" {
+			return "", errorResult(CodeBadRequest, "Must provide query string")
+		}
+		if s.cfg.PersistedOnly {
+			if _, ok := s.queries.fixed[hashQuery(req.Query)]; !ok {
+				return "", errorResult(CodePersistedQueryRequired, "Only persisted queries are allowed")
+			}
+		}
+		return req.Query, nil
+	}
+
+	if pq.Version != 1 {
+		return "", errorResult(CodePersistedQueryVersion, "Unsupported persisted query version")
+	}
+	if req.Query == "" {
+		query, ok := s.queries.get(pq.SHA256Hash)
+		if !ok || (s.cfg.PersistedOnly && s.queries.fixed[pq.SHA256Hash] == "") {
+			return "", errorResult(CodePersistedQueryNotFound, "PersistedQueryNotFound")
+		}
+		return query, nil
+	}
+	if hashQuery(req.Query) != pq.SHA256Hash {
+		return "", errorResult(CodePersistedQueryMismatch, "Provided sha256Hash does not match query")
+	}
+	if s.cfg.PersistedOnly {
+		if _, ok := s.queries.fixed[pq.SHA256Hash]; !ok {
+			return "", errorResult(CodePersistedQueryRequired, "Only persisted queries are allowed")
+		}
+		return req.Query, nil
+	}
+	s.queries.register(pq.SHA256Hash, req.Query)
+	return req.Query, nil
+}
+
+func errorResult(code, message string) *graphql.Result {
+	return &graphql.Result{Errors: []gqlerrors.FormattedError{{
+		Message:    message,
+		Locations:  []location.SourceLocation{},
+		Extensions: map[string]interface{}{"code": code},
+	}}}
+}
+
+// problemError reports a gateway problem as a GraphQL field error.
+type problemError struct {
+	p *problem.Problem
+}
+
+// FromProblem turns p into a resolver error whose extensions carry the
+// HTTP status and reason the REST API would have answered with.
+func FromProblem(p *problem.Problem) error {
+	return problemError{p}
+}
+
+func (e problemError) Error() string {
+	return e.p.Error()
+}
+
+func (e problemError) Unwrap() error {
+	return e.p
+}
+
+func (e problemError) Extensions() map[string]interface{} {
+	code := strings.ToUpper(strings.ReplaceAll(http.StatusText(e.p.Status), " ", "_"))
+	ext := map[string]interface{}{"code": code, "status": e.p.Status}
+	if e.p.Reason != "" {
+		ext["reason"] = e.p.Reason
+	}
+	return ext
+}
@@ -0,0 +1,73 @@
+package graph_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/graph"
+)
+
+func testServer(t *testing.T, cfg config.GraphQL) *graph.Server {
+	t.Helper()
+	user := graphql.NewObject(graphql.ObjectConfig{Name: "User", Fields: graphql.Fields{
+		"name": &graphql.Field{Type: graphql.String},
+	}})
+	user.AddFieldConfig("friends", &graphql.Field{Type: graphql.NewList(user)})
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{"me": &graphql.Field{Type: user, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return map[string]interface{}{"name": "ada"}, nil
+		}}},
+	})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := graph.NewServer(schema, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func code(res *graphql.Result) interface{} {
+	if len(res.Errors) == 0 {
+		return nil
+	}
+	return res.Errors[0].Extensions["code"]
+}
+
+func TestLimits(t *testing.T) {
+	s := testServer(t, config.GraphQL{MaxDepth: 4, MaxComplexity: 30, ListCost: 10})
+
+	for _, tc := range []struct {
+		query string
+		want  interface{}
+	}{
+		{`{ me { name } }`, nil},
+		{`{ __typename me { __typename name } }`, nil},
+		{`{ me { friends { friends { friends { name } } } } }`, graph.CodeQueryTooDeep},
+		{`{ me { friends { name } friends2: friends { name } friends3: friends { name } } }`, graph.CodeQueryTooComplex},
+		{`{ __schema { types { fields { type { ofType { ofType { name } } } } } } }`, graph.CodeQueryTooDeep},
+		{`{ __type(name: "User") { fields { type { ofType { ofType { name } } } } } }`, graph.CodeQueryTooDeep},
+		{`{ __schema { types { fields { name } } } }`, graph.CodeQueryTooComplex},
+		{`{ ...on Query { me { friends { friends { friends { name } } } } } }`, graph.CodeQueryTooDeep},
+	} {
+		res := s.Execute(context.Background(), graph.Request{Query: tc.query}, false)
+		if got := code(res); got != tc.want {
+			t.Errorf("%s: code = %v, want %v (errors %v)", tc.query, got, tc.want, res.Errors)
+		}
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	s := testServer(t, config.GraphQL{})
+
+	if res := s.Execute(context.Background(), graph.Request{Query: `mutation { me }`}, true); len(res.Errors) == 0 {
+		t.Fatalf("a mutation ran over GET")
+	}
+	if res := s.Execute(context.Background(), graph.Request{}, false); code(res) != graph.CodeBadRequest {
+		t.Fatalf("empty query: %v", res.Errors)
+	}
+}
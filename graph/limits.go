@@ -0,0 +1,116 @@
+package graph
+
+import (
+	"fmt"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// cost reports the depth and complexity of the operation. Every field
+// costs 1, and the selections under a list field cost listCost times
+// theirs. Introspection fields are walked through the introspection
+// types, and the selections under a field of unknown type still count,
+// so that neither escapes the limits. doc must have passed validation.
+func cost(schema *graphql.Schema, doc *ast.Document, op *ast.OperationDefinition, listCost int) (depth, complexity int) {
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, def := range doc.Definitions {
+		if f, ok := def.(*ast.FragmentDefinition); ok {
+			fragments[f.Name.Value] = f
+		}
+	}
+
+	var walk func(set *ast.SelectionSet, parent graphql.Type) (int, int)
+	walk = func(set *ast.SelectionSet, parent graphql.Type) (depth, complexity int) {
+		if set == nil {
+			return 0, 0
+		}
+		for _, sel := range set.Selections {
+			var d, c int
+			switch sel := sel.(type) {
+			case *ast.Field:
+				def := fieldDef(parent, sel.Name.Value)
+				if def == nil {
+					subDepth, subCost := walk(sel.SelectionSet, nil)
+					d, c = 1+subDepth, 1+subCost
+					break
+				}
+				named, _ := graphql.GetNamed(def.Type).(graphql.Type)
+				subDepth, subCost := walk(sel.SelectionSet, named)
+				if isList(def.Type) {
+					subCost *= listCost
+				}
+				d, c = 1+subDepth, 1+subCost
+			case *ast.InlineFragment:
+				t := parent
+				if sel.TypeCondition != nil {
+					t = schema.Type(sel.TypeCondition.Name.Value)
+				}
+				d, c = walk(sel.SelectionSet, t)
+			case *ast.FragmentSpread:
+				if f := fragments[sel.Name.Value]; f != nil {
+					d, c = walk(f.SelectionSet, schema.Type(f.TypeCondition.Name.Value))
+				}
+			}
+			depth = max(depth, d)
+			complexity += c
+		}
+		return depth, complexity
+	}
+
+	root := graphql.Type(schema.QueryType())
+	if op.Operation == ast.OperationTypeMutation {
+		root = schema.MutationType()
+	}
+	return walk(op.SelectionSet, root)
+}
+
+func fieldDef(t graphql.Type, name string) *graphql.FieldDefinition {
+	switch name {
+	case graphql.SchemaMetaFieldDef.Name:
+		return graphql.SchemaMetaFieldDef
+	case graphql.TypeMetaFieldDef.Name:
+		return graphql.TypeMetaFieldDef
+	case graphql.TypeNameMetaFieldDef.Name:
+		return graphql.TypeNameMetaFieldDef
+	}
+	switch t := t.(type) {
+	case *graphql.Object:
+		return t.Fields()[name]
+	case *graphql.Interface:
+		return t.Fields()[name]
+	}
+	return nil
+}
+
+func isList(t graphql.Type) bool {
+	if nn, ok := t.(*graphql.NonNull); ok {
+		t = nn.OfType
+	}
+	_, ok := t.(*graphql.List)
+	return ok
+}
+
+// operation picks the operation to run, by name when there are several.
+func operation(doc *ast.Document, name string) (*ast.OperationDefinition, error) {
+	var found *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if name == "" && found != nil {
+			return nil, fmt.Errorf("must provide operation name if query contains multiple operations")
+		}
+		if name == "" || (op.Name != nil && op.Name.Value == name) {
+			found = op
+		}
+	}
+	if found == nil {
+		if name != "" {
+			return nil, fmt.Errorf("unknown operation named %q", name)
+		}
+		return nil, fmt.Errorf("must provide an operation")
+	}
+	return found, nil
+}
@@ -0,0 +1,64 @@
+package graph
+
+import (
+	"context"
+	"sync"
+)
+
+// Thunk defers a resolver result; the executor calls it once every field
+// of the current level has been resolved.
+type Thunk = func() (interface{}, error)
+
+// Loader collects the keys requested while one level of a query resolves
+// and fetches them in one batch when the first result is needed, so that
+// a list of N items costs one batch rather than N calls. Results are kept
+// for the rest of the request.
+type Loader[K comparable, V any] struct {
+	fetch func(ctx context.Context, keys []K) ([]V, []error)
+
+	mu      sync.Mutex
+	pending []K
+	results map[K]*result[V]
+}
+
+type result[V any] struct {
+	value V
+	err   error
+}
+
+// NewLoader batches with fetch, which returns one value and error per key,
+// in key order.
+func NewLoader[K comparable, V any](fetch func(ctx context.Context, keys []K) ([]V, []error)) *Loader[K, V] {
+	return &Loader[K, V]{fetch: fetch, results: map[K]*result[V]{}}
+}
+
+func (l *Loader[K, V]) Load(ctx context.Context, key K) Thunk {
+	l.mu.Lock()
+	if _, ok := l.results[key]; !ok {
+		l.results[key] = nil
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		l.dispatch(ctx)
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		r := l.results[key]
+		return r.value, r.err
+	}
+}
+
+func (l *Loader[K, V]) dispatch(ctx context.Context) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.pending) == 0 {
+		return
+	}
+	keys := l.pending
+	l.pending = nil
+	values, errs := l.fetch(ctx, keys)
+	for i, key := range keys {
+		l.results[key] = &result[V]{value: values[i], err: errs[i]}
+	}
+}
@@ -0,0 +1,77 @@
+package graph
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// queryStore holds persisted queries by SHA-256 hash: the ones loaded
+// from the config file, and those clients registered, oldest dropped
+// first beyond max.
+type queryStore struct {
+	fixed map[string]string
+	max   int
+
+	mu         sync.Mutex
+	registered map[string]string
+	order      []string
+}
+
+func loadQueries(path string, max int) (*queryStore, error) {
+	q := &queryStore{fixed: map[string]string{}, max: max, registered: map[string]string{}}
+	if path == "" {
+		return q, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read persisted queries: %w", err)
+	}
+	if err := json.Unmarshal(data, &q.fixed); err != nil {
+		return nil, fmt.Errorf("parse persisted queries %s: %w", path, err)
+	}
+	for hash, query := range q.fixed {
+		if hashQuery(query) != hash {
+			return nil, fmt.Errorf("persisted query %s does not match its hash", hash)
+		}
+	}
+	return q, nil
+}
+
+func (q *queryStore) get(hash string) (string, bool) {
+	if query, ok := q.fixed[hash]; ok {
+		return query, true
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	query, ok := q.registered[hash]
+	return query, ok
+}
+
+func (q *queryStore) register(hash, query string) {
+	if q.max <= 0 {
+		return
+	}
+	if _, ok := q.fixed[hash]; ok {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.registered[hash]; ok {
+		return
+	}
+	q.registered[hash] = query
+	q.order = append(q.order, hash)
+	if len(q.order) > q.max {
+		delete(q.registered, q.order[0])
+		q.order = q.order[1:]
+	}
+}
+
+func hashQuery(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
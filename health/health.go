@@ -0,0 +1,254 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// Dependency statuses.
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+const (
+	DependencyAuth   = "auth-service"
+	DependencyEvents = "event-service"
+)
+
+// DependencyStatus is the latest probe result for one upstream.
+type DependencyStatus struct {
+	Name        string     `json:"name"`
+	Status      string     `json:"status"`
+	LatencyMs   float64    `json:"latency_ms"`
+	Detail      string     `json:"detail,omitempty"`
+	CheckedAt   time.Time  `json:"checked_at"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// Checker probes the auth service over the gRPC health checking protocol
+// and the event service over HTTP.
+type Checker struct {
+	conn       grpc.ClientConnInterface
+	health     healthpb.HealthClient
+	cfg        config.Health
+	eventProbe string
+	httpClient *http.Client
+	draining   atomic.Bool
+
+	// probing serializes the rounds of probes started by Cached.
+	probing sync.Mutex
+
+	mu      sync.Mutex
+	results map[string]DependencyStatus
+}
+
+// connState is implemented by *grpc.ClientConn. Other connections are
+// checked with the health RPC alone.
+type connState interface {
+	GetState() connectivity.State
+	Connect()
+	WaitForStateChange(context.Context, connectivity.State) bool
+}
+
+func New(conn grpc.ClientConnInterface, eventService string, httpClient *http.Client, cfg config.Health) *Checker {
+	return &Checker{
+		conn:       conn,
+		health:     healthpb.NewHealthClient(conn),
+		cfg:        cfg,
+		eventProbe: eventService + cfg.EventProbePath,
+		httpClient: httpClient,
+		results:    map[string]DependencyStatus{},
+	}
+}
+
+// SetDraining makes readiness fail so that load balancers stop routing new
+// requests during graceful shutdown.
+func (h *Checker) SetDraining() {
+	h.draining.Store(true)
+}
+
+func (h *Checker) Draining() bool {
+	return h.draining.Load()
+}
+
+// Register mounts /healthz, /readyz and /health/dependencies.
+func (h *Checker) Register(r gin.IRoutes) {
+	r.GET("/healthz", h.liveness)
+	r.GET("/readyz", h.readiness)
+	r.GET("/health/dependencies", h.dependencies)
+}
+
+func (h *Checker) liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (h *Checker) readiness(c *gin.Context) {
+	if h.Draining() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "draining"})
+		return
+	}
+
+	report := h.Cached(c.Request.Context())
+	for _, dep := range report {
+		if dep.Status != StatusUp {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not ready", "dependencies": report})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
+
+func (h *Checker) dependencies(c *gin.Context) {
+	report := h.Cached(c.Request.Context())
+	statusCode := http.StatusOK
+	overall := StatusUp
+	for _, dep := range report {
+		if dep.Status != StatusUp {
+			statusCode, overall = http.StatusServiceUnavailable, StatusDown
+		}
+	}
+	c.JSON(statusCode, gin.H{"status": overall, "draining": h.Draining(), "dependencies": report})
+}
+
+// Check probes every dependency concurrently and returns their statuses.
+func (h *Checker) Check(ctx context.Context) []DependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, h.cfg.Timeout.Std())
+	defer cancel()
+
+	probes := []struct {
+		name  string
+		probe func(context.Context) (string, error)
+	}{
+		{DependencyAuth, h.probeAuth},
+		{DependencyEvents, h.probeEvents},
+	}
+
+	report := make([]DependencyStatus, len(probes))
+	var wg sync.WaitGroup
+	for i, p := range probes {
+		wg.Add(1)
+		go func(i int, name string, probe func(context.Context) (string, error)) {
+			defer wg.Done()
+			start := time.Now()
+			detail, err := probe(ctx)
+			report[i] = h.update(name, detail, err, start)
+		}(i, p.name, p.probe)
+	}
+	wg.Wait()
+	return report
+}
+
+// Cached returns the latest results while every dependency was checked
+// within the cache TTL and probes again otherwise, so that frequent health
+// checks do not each reach the upstreams. Concurrent callers share one
+// round of probes, which is not cut short by a caller going away.
+func (h *Checker) Cached(ctx context.Context) []DependencyStatus {
+	h.probing.Lock()
+	defer h.probing.Unlock()
+	if report, ok := h.fresh(); ok {
+		return report
+	}
+	return h.Check(context.WithoutCancel(ctx))
+}
+
+func (h *Checker) fresh() ([]DependencyStatus, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	report := make([]DependencyStatus, 0, 2)
+	for _, name := range []string{DependencyAuth, DependencyEvents} {
+		s, ok := h.results[name]
+		if !ok || time.Since(s.CheckedAt) >= h.cfg.CacheTTL.Std() {
+			return nil, false
+		}
+		report = append(report, s)
+	}
+	return report, true
+}
+
+// Last returns the most recent result per dependency without probing.
+func (h *Checker) Last() map[string]DependencyStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make(map[string]DependencyStatus, len(h.results))
+	for k, v := range h.results {
+		out[k] = v
+	}
+	return out
+}
+
+func (h *Checker) update(name, detail string, err error, start time.Time) DependencyStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	s := h.results[name]
+	s.Name = name
+	s.Detail = detail
+	s.CheckedAt = now
+	s.LatencyMs = float64(now.Sub(start).Microseconds()) / 1000
+	s.Status = StatusUp
+	if err != nil {
+		s.Status = StatusDown
+		s.LastError = err.Error()
+		s.LastErrorAt = &now
+	}
+	h.results[name] = s
+	return s
+}
+
+func (h *Checker) probeAuth(ctx context.Context) (string, error) {
+	if conn, ok := h.conn.(connState); ok {
+		state := conn.GetState()
+		if state == connectivity.Idle {
+			conn.Connect()
+		}
+		for state != connectivity.Ready {
+			if state == connectivity.TransientFailure || state == connectivity.Shutdown || !conn.WaitForStateChange(ctx, state) {
+				return state.String(), errors.New("auth service connection is " + state.String())
+			}
+			state = conn.GetState()
+		}
+	}
+
+	res, err := h.health.Check(ctx, &healthpb.HealthCheckRequest{Service: h.cfg.AuthService})
+	if status.Code(err) == codes.Unimplemented {
+		return "health service not implemented", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if res.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return res.GetStatus().String(), errors.New("auth service reports " + res.GetStatus().String())
+	}
+	return res.GetStatus().String(), nil
+}
+
+func (h *Checker) probeEvents(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.eventProbe, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return resp.Status, errors.New("event service probe responded " + resp.Status)
+	}
+	return resp.Status, nil
+}
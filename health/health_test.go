@@ -0,0 +1,180 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/health"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type fixture struct {
+	checker *health.Checker
+	engine  *gin.Engine
+	probes  atomic.Int32
+	status  atomic.Int32
+}
+
+// newFixture starts an auth service, with the gRPC health service when
+// withHealth is set, and an event service answering its probe path with
+// f.status.
+func newFixture(t *testing.T, withHealth bool, cacheTTL time.Duration) *fixture {
+	t.Helper()
+	f := &fixture{}
+	f.status.Store(http.StatusOK)
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	if withHealth {
+		healthpb.RegisterHealthServer(srv, grpchealth.NewServer())
+	}
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	events := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			f.probes.Add(1)
+			w.WriteHeader(int(f.status.Load()))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(events.Close)
+
+	f.checker = health.New(conn, events.URL, events.Client(), config.Health{
+		EventProbePath: "/health",
+		Timeout:        config.Duration(2 * time.Second),
+		CacheTTL:       config.Duration(cacheTTL),
+	})
+	gin.SetMode(gin.TestMode)
+	f.engine = gin.New()
+	f.checker.Register(f.engine)
+	return f
+}
+
+type report struct {
+	Status       string                    `json:"status"`
+	Draining     bool                      `json:"draining"`
+	Dependencies []health.DependencyStatus `json:"dependencies"`
+}
+
+func (f *fixture) get(t *testing.T, path string, want int) report {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	f.engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != want {
+		t.Fatalf("GET %s = %d, want %d: %s", path, rec.Code, want, rec.Body)
+	}
+	var r report
+	if err := json.Unmarshal(rec.Body.Bytes(), &r); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func dependency(r report, name string) health.DependencyStatus {
+	for _, d := range r.Dependencies {
+		if d.Name == name {
+			return d
+		}
+	}
+	return health.DependencyStatus{}
+}
+
+func TestReady(t *testing.T) {
+	f := newFixture(t, true, time.Minute)
+	f.get(t, "/healthz", http.StatusOK)
+	if r := f.get(t, "/readyz", http.StatusOK); r.Status != "ready" {
+		t.Fatalf("readyz = %+v", r)
+	}
+	r := f.get(t, "/health/dependencies", http.StatusOK)
+	if d := dependency(r, health.DependencyAuth); d.Status != health.StatusUp || d.Detail != "SERVING" {
+		t.Fatalf("auth = %+v", d)
+	}
+}
+
+func TestDrainingIsNotReady(t *testing.T) {
+	f := newFixture(t, true, time.Minute)
+	f.checker.SetDraining()
+	if r := f.get(t, "/readyz", http.StatusServiceUnavailable); r.Status != "draining" {
+		t.Fatalf("readyz = %+v", r)
+	}
+	f.get(t, "/healthz", http.StatusOK)
+	if r := f.get(t, "/health/dependencies", http.StatusOK); !r.Draining {
+		t.Fatalf("dependencies = %+v, want draining", r)
+	}
+	if f.probes.Load() != 1 {
+		t.Fatalf("probed %d times", f.probes.Load())
+	}
+}
+
+func TestAuthHealthUnimplemented(t *testing.T) {
+	f := newFixture(t, false, time.Minute)
+	r := f.get(t, "/health/dependencies", http.StatusOK)
+	if d := dependency(r, health.DependencyAuth); d.Status != health.StatusUp || d.Detail != "health service not implemented" {
+		t.Fatalf("auth = %+v", d)
+	}
+}
+
+func TestFailingEventProbe(t *testing.T) {
+	f := newFixture(t, true, 0)
+	f.status.Store(http.StatusInternalServerError)
+	r := f.get(t, "/readyz", http.StatusServiceUnavailable)
+	if d := dependency(r, health.DependencyEvents); r.Status != "not ready" || d.Status != health.StatusDown || d.LastError == "" || d.LastErrorAt == nil {
+		t.Fatalf("readyz = %+v", r)
+	}
+
+	f.status.Store(http.StatusOK)
+	r = f.get(t, "/health/dependencies", http.StatusOK)
+	if d := dependency(r, health.DependencyEvents); d.Status != health.StatusUp || d.LastError == "" {
+		t.Fatalf("events = %+v, want up with the last error kept", d)
+	}
+}
+
+func TestResultsAreCached(t *testing.T) {
+	f := newFixture(t, true, 100*time.Millisecond)
+	for i := 0; i < 3; i++ {
+		f.get(t, "/readyz", http.StatusOK)
+		f.get(t, "/health/dependencies", http.StatusOK)
+	}
+	if n := f.probes.Load(); n != 1 {
+		t.Fatalf("probed %d times within the TTL, want 1", n)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	f.get(t, "/readyz", http.StatusOK)
+	if n := f.probes.Load(); n != 2 {
+		t.Fatalf("probed %d times after the TTL, want 2", n)
+	}
+}
+
+func TestCachedSurvivesCanceledCaller(t *testing.T) {
+	f := newFixture(t, true, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, d := range f.checker.Cached(ctx) {
+		if d.Status != health.StatusUp {
+			t.Fatalf("%s = %+v after a canceled caller", d.Name, d)
+		}
+	}
+}
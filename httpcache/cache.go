@@ -0,0 +1,379 @@
+// Package httpcache is a shared HTTP cache for upstream calls. It honors
+// Cache-Control, ETag and Vary, revalidates with conditional requests,
+// serves stale entries while revalidating in the background and drops
+// entries when their resource or collection is changed through it.
+package httpcache
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Header reports how a response was served: HIT, STALE, REVALIDATED or
+// MISS.
+const Header = "X-Cache"
+
+// UserHeader identifies the user a request is made for. Per-user rules
+// key entries by it.
+const UserHeader = "X-User-ID"
+
+const maxVariants = 8
+
+// Rule enables caching for a route. TTL, when set, overrides the
+// upstream's freshness lifetime. StaleWhileRevalidate applies when the
+// upstream does not send the directive itself.
+type Rule struct {
+	TTL                  time.Duration
+	StaleWhileRevalidate time.Duration
+	PerUser              bool
+}
+
+type routeKey struct{}
+
+// WithRoute names the route a request is made for. Only requests for
+// routes with a rule are cached.
+func WithRoute(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, routeKey{}, route)
+}
+
+type Stats struct {
+	Entries       int
+	Bytes         int64
+	Hits          uint64
+	StaleHits     uint64
+	Revalidations uint64
+	Misses        uint64
+}
+
+// Cache is an http.RoundTripper caching GET responses of next.
+type Cache struct {
+	Now func() time.Time
+
+	next       http.RoundTripper
+	rules      map[string]Rule
+	maxEntries int
+	maxBytes   int64
+
+	mu         sync.Mutex
+	lru        *list.List
+	items      map[string]*list.Element
+	bytes      int64
+	refreshing map[string]bool
+
+	hits, staleHits, revalidations, misses atomic.Uint64
+}
+
+type item struct {
+	key      string
+	path     string
+	variants []*entry
+	size     int64
+}
+
+type entry struct {
+	vary   map[string]string
+	status int
+	header http.Header
+	body   []byte
+	stored time.Time
+	fresh  time.Duration
+	stale  time.Duration
+}
+
+// New caches up to maxEntries URLs holding at most maxBytes of bodies in
+// total; maxBytes 0 leaves the size unbounded. A nil next uses
+// http.DefaultTransport.
+func New(next http.RoundTripper, maxEntries int, maxBytes int64, rules map[string]Rule) *Cache {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Cache{
+		Now:        time.Now,
+		next:       next,
+		rules:      rules,
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		lru:        list.New(),
+		items:      map[string]*list.Element{},
+		refreshing: map[string]bool{},
+	}
+}
+
+func (c *Cache) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		resp, err := c.next.RoundTrip(req)
+		if err == nil && resp.StatusCode < 400 {
+			c.Invalidate(req.URL.Path)
+		}
+		return resp, err
+	}
+
+	route, _ := req.Context().Value(routeKey{}).(string)
+	rule, ok := c.rules[route]
+	if !ok || req.Method != http.MethodGet {
+		return c.next.RoundTrip(req)
+	}
+
+	key := req.URL.String()
+	if rule.PerUser {
+		key += "\x00" + req.Header.Get(UserHeader)
+	}
+
+	e := c.lookup(key, req)
+	if e != nil && !parseCacheControl(req.Header).has("no-cache") {
+		age := c.Now().Sub(e.stored)
+		if age < e.fresh {
+			c.hits.Add(1)
+			return e.response(req, "HIT", age), nil
+		}
+		if age < e.fresh+e.stale {
+			c.staleHits.Add(1)
+			c.refresh(key, req, rule, e)
+			return e.response(req, "STALE", age), nil
+		}
+	}
+
+	resp, err := c.fetch(req, key, rule, e)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Header.Get(Header) == "REVALIDATED" {
+		c.revalidations.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	return resp, nil
+}
+
+// fetch requests req upstream, conditionally when e has a validator, and
+// stores the result.
+func (c *Cache) fetch(req *http.Request, key string, rule Rule, e *entry) (*http.Response, error) {
+	out := req
+	if e != nil {
+		etag, modified := e.header.Get("ETag"), e.header.Get("Last-Modified")
+		if etag != "" || modified != "" {
+			out = req.Clone(req.Context())
+			if etag != "" {
+				out.Header.Set("If-None-Match", etag)
+			}
+			if modified != "" {
+				out.Header.Set("If-Modified-Since", modified)
+			}
+		}
+	}
+
+	resp, err := c.next.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	now := c.Now()
+
+	if resp.StatusCode == http.StatusNotModified && e != nil && out != req {
+		resp.Body.Close()
+		updated := e.revalidated(resp, rule, now)
+		c.store(key, req, updated)
+		return updated.response(req, "REVALIDATED", 0), nil
+	}
+
+	resp.Header.Set(Header, "MISS")
+	if !storable(resp, rule) {
+		return resp, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	stored := &entry{status: resp.StatusCode, header: resp.Header.Clone(), body: body, stored: now, vary: map[string]string{}}
+	stored.header.Del(Header)
+	stored.fresh, stored.stale = freshness(resp, rule, now)
+	for _, name := range varyHeaders(resp.Header) {
+		stored.vary[name] = req.Header.Get(name)
+	}
+	c.store(key, req, stored)
+	return resp, nil
+}
+
+// refresh revalidates e in the background, once per key at a time.
+func (c *Cache) refresh(key string, req *http.Request, rule Rule, e *entry) {
+	c.mu.Lock()
+	if c.refreshing[key] {
+		c.mu.Unlock()
+		return
+	}
+	c.refreshing[key] = true
+	c.mu.Unlock()
+
+	bg := req.Clone(context.WithoutCancel(req.Context()))
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			delete(c.refreshing, key)
+			c.mu.Unlock()
+		}()
+		if resp, err := c.fetch(bg, key, rule, e); err == nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}()
+}
+
+func (e *entry) matches(req *http.Request) bool {
+	for name, value := range e.vary {
+		if req.Header.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// revalidated applies the headers of a 304 response to a copy of e.
+func (e *entry) revalidated(resp *http.Response, rule Rule, now time.Time) *entry {
+	updated := *e
+	updated.header = e.header.Clone()
+	for name, values := range resp.Header {
+		if name != "Content-Length" {
+			updated.header[name] = values
+		}
+	}
+	updated.stored = now
+	merged := &http.Response{StatusCode: e.status, Header: updated.header}
+	updated.fresh, updated.stale = freshness(merged, rule, now)
+	return &updated
+}
+
+func (e *entry) response(req *http.Request, how string, age time.Duration) *http.Response {
+	header := e.header.Clone()
+	header.Set(Header, how)
+	header.Set("Age", strconv.Itoa(int(age/time.Second)))
+	return &http.Response{
+		Status:        strconv.Itoa(e.status) + " " + http.StatusText(e.status),
+		StatusCode:    e.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}
+}
+
+func (c *Cache) lookup(key string, req *http.Request) *entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(el)
+	for _, e := range el.Value.(*item).variants {
+		if e.matches(req) {
+			return e
+		}
+	}
+	return nil
+}
+
+func (c *Cache) store(key string, req *http.Request, e *entry) {
+	if c.maxBytes > 0 && int64(len(e.body)) > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		el = c.lru.PushFront(&item{key: key, path: req.URL.Path})
+		c.items[key] = el
+	}
+	c.lru.MoveToFront(el)
+
+	it := el.Value.(*item)
+	variants := []*entry{e}
+	for _, v := range it.variants {
+		if !sameVary(v, e) && len(variants) < maxVariants {
+			variants = append(variants, v)
+		}
+	}
+	it.variants = variants
+	c.bytes -= it.size
+	it.size = 0
+	for _, v := range variants {
+		it.size += int64(len(v.body))
+	}
+	c.bytes += it.size
+
+	for c.lru.Len() > c.maxEntries || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *Cache) remove(el *list.Element) {
+	it := el.Value.(*item)
+	c.lru.Remove(el)
+	delete(c.items, it.key)
+	c.bytes -= it.size
+}
+
+func sameVary(a, b *entry) bool {
+	if len(a.vary) != len(b.vary) {
+		return false
+	}
+	for name, value := range a.vary {
+		if v, ok := b.vary[name]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Invalidate drops the entries a write to path may have changed: every
+// entry under its parent, whichever user or query it was cached for. PUT
+// /events/1 thus also drops /events/1/attendees and /events/search, and
+// POST /events/1/register drops /events/1 and its attendees. Writes to a
+// top-level path such as POST /events drop everything under it.
+func (c *Cache) Invalidate(path string) {
+	scope := strings.TrimSuffix(path, "/")
+	if i := strings.LastIndex(scope, "/"); i > 0 {
+		scope = scope[:i]
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, el := range c.items {
+		if p := el.Value.(*item).path; p == scope || strings.HasPrefix(p, scope+"/") {
+			c.remove(el)
+		}
+	}
+}
+
+func (c *Cache) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lru.Init()
+	c.items = map[string]*list.Element{}
+	c.bytes = 0
+}
+
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{
+		Entries:       c.lru.Len(),
+		Bytes:         c.bytes,
+		Hits:          c.hits.Load(),
+		StaleHits:     c.staleHits.Load(),
+		Revalidations: c.revalidations.Load(),
+		Misses:        c.misses.Load(),
+	}
+}
@@ -0,0 +1,112 @@
+package httpcache_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rekib0023/event-horizon-gateway/httpcache"
+)
+
+// upstream answers every request with body and counts requests per path.
+type upstream struct {
+	mu    sync.Mutex
+	calls map[string]int
+	body  string
+}
+
+func (u *upstream) RoundTrip(r *http.Request) (*http.Response, error) {
+	u.mu.Lock()
+	u.calls[r.Method+" "+r.URL.Path]++
+	u.mu.Unlock()
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(u.body)),
+		Request:    r,
+	}, nil
+}
+
+func (u *upstream) count(key string) int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.calls[key]
+}
+
+var rules = map[string]httpcache.Rule{"GET": {TTL: time.Minute}}
+
+func get(t *testing.T, c *httpcache.Cache, path string) string {
+	t.Helper()
+	return do(t, c, http.MethodGet, path)
+}
+
+func do(t *testing.T, c *httpcache.Cache, method, path string) string {
+	t.Helper()
+	req, _ := http.NewRequestWithContext(httpcache.WithRoute(context.Background(), method), method, "http://events"+path, nil)
+	resp, err := c.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp.Header.Get(httpcache.Header)
+}
+
+func TestCacheHits(t *testing.T) {
+	u := &upstream{calls: map[string]int{}, body: `{"id":1}`}
+	c := httpcache.New(u, 10, 0, rules)
+
+	if got := get(t, c, "/events/1"); got != "MISS" {
+		t.Fatalf("first read = %s", got)
+	}
+	if got := get(t, c, "/events/1"); got != "HIT" {
+		t.Fatalf("second read = %s", got)
+	}
+	if n := u.count("GET /events/1"); n != 1 {
+		t.Fatalf("upstream saw %d reads", n)
+	}
+}
+
+func TestWriteInvalidatesResourceAndCollection(t *testing.T) {
+	u := &upstream{calls: map[string]int{}, body: `{}`}
+	c := httpcache.New(u, 10, 0, rules)
+	for _, path := range []string{"/events/1", "/events/1/attendees", "/events/search?q=go", "/events", "/users/1"} {
+		get(t, c, path)
+	}
+
+	do(t, c, http.MethodPut, "/events/1")
+
+	for _, path := range []string{"/events/1", "/events/1/attendees", "/events/search?q=go", "/events"} {
+		if got := get(t, c, path); got != "MISS" {
+			t.Errorf("%s after PUT /events/1 = %s, want MISS", path, got)
+		}
+	}
+	if got := get(t, c, "/users/1"); got != "HIT" {
+		t.Errorf("/users/1 after PUT /events/1 = %s, want HIT", got)
+	}
+}
+
+func TestCacheBoundedByBytes(t *testing.T) {
+	u := &upstream{calls: map[string]int{}, body: strings.Repeat("x", 100)}
+	c := httpcache.New(u, 10, 250, rules)
+	for _, path := range []string{"/events/1", "/events/2", "/events/3"} {
+		get(t, c, path)
+	}
+	if s := c.Stats(); s.Entries != 2 || s.Bytes != 200 {
+		t.Fatalf("stats = %+v, want 2 entries of 200 bytes", s)
+	}
+	if got := get(t, c, "/events/1"); got != "MISS" {
+		t.Fatalf("oldest entry = %s, want it evicted", got)
+	}
+
+	big := &upstream{calls: map[string]int{}, body: strings.Repeat("x", 300)}
+	c = httpcache.New(big, 10, 250, rules)
+	get(t, c, "/events/1")
+	if s := c.Stats(); s.Entries != 0 {
+		t.Fatalf("stored a body over the byte limit: %+v", s)
+	}
+}
@@ -0,0 +1,107 @@
+package httpcache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type directives map[string]string
+
+func parseCacheControl(h http.Header) directives {
+	d := directives{}
+	for _, line := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name == "" {
+				continue
+			}
+			d[strings.ToLower(name)] = strings.Trim(value, `"`)
+		}
+	}
+	return d
+}
+
+func (d directives) has(name string) bool {
+	_, ok := d[name]
+	return ok
+}
+
+func (d directives) seconds(name string) (time.Duration, bool) {
+	v, ok := d[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// freshness returns how long a response stays fresh, and for how long
+// after that it may be served while it is revalidated. A shared cache
+// prefers s-maxage over max-age.
+func freshness(resp *http.Response, rule Rule, now time.Time) (fresh, stale time.Duration) {
+	cc := parseCacheControl(resp.Header)
+	switch {
+	case cc.has("no-cache"):
+		fresh = 0
+	case rule.TTL > 0:
+		fresh = rule.TTL
+	default:
+		if v, ok := cc.seconds("s-maxage"); ok {
+			fresh = v
+		} else if v, ok := cc.seconds("max-age"); ok {
+			fresh = v
+		} else if expires, err := http.ParseTime(resp.Header.Get("Expires")); err == nil {
+			fresh = expires.Sub(now)
+		}
+		if age, err := strconv.Atoi(resp.Header.Get("Age")); err == nil {
+			fresh -= time.Duration(age) * time.Second
+		}
+	}
+	if fresh < 0 {
+		fresh = 0
+	}
+
+	stale = rule.StaleWhileRevalidate
+	if v, ok := cc.seconds("stale-while-revalidate"); ok {
+		stale = v
+	}
+	if cc.has("must-revalidate") || cc.has("proxy-revalidate") || cc.has("no-cache") {
+		stale = 0
+	}
+	return fresh, stale
+}
+
+// storable reports whether a shared cache may keep resp. Private responses
+// are only kept when entries are keyed by user.
+func storable(resp *http.Response, rule Rule) bool {
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	cc := parseCacheControl(resp.Header)
+	if cc.has("no-store") || (cc.has("private") && !rule.PerUser) {
+		return false
+	}
+	for _, v := range resp.Header.Values("Vary") {
+		if strings.TrimSpace(v) == "*" {
+			return false
+		}
+	}
+	return true
+}
+
+func varyHeaders(h http.Header) []string {
+	var names []string
+	for _, line := range h.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,99 @@
+// Package limits protects the gateway from oversized and slow requests.
+package limits
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/problem"
+)
+
+const contextKey = "limits.maxBodyBytes"
+
+// Middleware caps request bodies at the route's limit, or cfg.MaxBodyBytes,
+// rejects requests with too many header fields and bounds the time spent
+// reading a body. Routes are keyed by "METHOD " + c.FullPath().
+func Middleware(cfg config.Limits) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cfg.MaxHeaderCount > 0 && headerCount(c.Request.Header) > cfg.MaxHeaderCount {
+			problem.Abort(c, problem.New(http.StatusRequestHeaderFieldsTooLarge, "Too many header fields"))
+			return
+		}
+
+		maxBytes, timeout := cfg.MaxBodyBytes, cfg.BodyReadTimeout.Std()
+		if route, ok := cfg.Routes[c.Request.Method+" "+c.FullPath()]; ok {
+			if route.MaxBodyBytes != 0 {
+				maxBytes = route.MaxBodyBytes
+			}
+			if route.BodyReadTimeout != 0 {
+				timeout = route.BodyReadTimeout.Std()
+			}
+		}
+
+		if maxBytes > 0 {
+			c.Set(contextKey, maxBytes)
+		}
+		if c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+		if maxBytes > 0 {
+			if c.Request.ContentLength > maxBytes {
+				problem.Abort(c, problem.FromBodyError(&http.MaxBytesError{Limit: maxBytes}))
+				return
+			}
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		}
+		if timeout > 0 {
+			rc := http.NewResponseController(c.Writer)
+			if err := rc.SetReadDeadline(time.Now().Add(timeout)); err == nil {
+				c.Request.Body = &deadlineBody{ReadCloser: c.Request.Body, rc: rc}
+			}
+		}
+		c.Next()
+	}
+}
+
+// MaxBodyBytes returns the body limit Middleware applied to the request, or
+// 0 if there is none. Middleware that replaces the body, such as decoding
+// a compressed one, uses it to keep the new body within the same limit.
+func MaxBodyBytes(c *gin.Context) int64 {
+	n, _ := c.Get(contextKey)
+	limit, _ := n.(int64)
+	return limit
+}
+
+func headerCount(h http.Header) int {
+	n := 0
+	for _, values := range h {
+		n += len(values)
+	}
+	return n
+}
+
+// deadlineBody lifts the read deadline once the body has been read, so
+// that it cannot cut off a handler that takes longer than the upload.
+type deadlineBody struct {
+	io.ReadCloser
+	rc *http.ResponseController
+}
+
+func (b *deadlineBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if errors.Is(err, io.EOF) {
+		b.rc.SetReadDeadline(time.Time{})
+	}
+	return n, err
+}
+
+// Server applies the connection-level limits to srv.
+func Server(srv *http.Server, cfg config.Limits) *http.Server {
+	srv.MaxHeaderBytes = cfg.MaxHeaderBytes
+	srv.ReadHeaderTimeout = cfg.ReadHeaderTimeout.Std()
+	srv.IdleTimeout = cfg.IdleTimeout.Std()
+	return srv
+}
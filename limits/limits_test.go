@@ -0,0 +1,59 @@
+package limits_test
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/gatewaytest"
+)
+
+func TestBodyLimit(t *testing.T) {
+	h := gatewaytest.New(t, func(cfg *config.Config) {
+		cfg.Limits.MaxBodyBytes = 64
+	})
+	user := h.AddUser("ada@example.com")
+
+	h.ExpectStatus(h.DoAs(user, http.MethodPost, "/api/events", map[string]any{"name": "Launch"}), http.StatusOK)
+
+	big := map[string]any{"name": strings.Repeat("x", 100)}
+	rec := h.DoAs(user, http.MethodPost, "/api/events", big)
+	h.ExpectStatus(rec, http.StatusRequestEntityTooLarge)
+
+	// Without a Content-Length the limit applies while reading.
+	req := gatewaytest.As(h.Request(http.MethodPost, "/api/events", io.MultiReader(strings.NewReader(`{"name":"`), strings.NewReader(strings.Repeat("x", 100)+`"}`))), user.GetToken())
+	req.ContentLength = -1
+	h.ExpectStatus(h.Do(req), http.StatusRequestEntityTooLarge)
+}
+
+func TestRouteBodyLimit(t *testing.T) {
+	h := gatewaytest.New(t, func(cfg *config.Config) {
+		cfg.Limits.MaxBodyBytes = 1 << 20
+		cfg.Limits.Routes = map[string]config.RouteLimit{"POST /api/events": {MaxBodyBytes: 16}}
+	})
+	user := h.AddUser("ada@example.com")
+
+	rec := h.DoAs(user, http.MethodPost, "/api/events", map[string]any{"name": "A long event name"})
+	h.ExpectStatus(rec, http.StatusRequestEntityTooLarge)
+	if !strings.Contains(rec.Body.String(), strconv.Itoa(16)) {
+		t.Fatalf("body = %s, want the route limit", rec.Body.String())
+	}
+	h.ExpectStatus(h.DoAs(user, http.MethodPut, "/api/events/1", map[string]any{"name": "A long event name"}), http.StatusOK)
+}
+
+func TestHeaderCount(t *testing.T) {
+	h := gatewaytest.New(t, func(cfg *config.Config) {
+		cfg.Limits.MaxHeaderCount = 4
+	})
+
+	req := h.Request(http.MethodGet, "/healthz", nil)
+	for i := 0; i < 5; i++ {
+		req.Header.Add("X-Padding", strconv.Itoa(i))
+	}
+	h.ExpectStatus(h.Do(req), http.StatusRequestHeaderFieldsTooLarge)
+
+	h.ExpectStatus(h.Do(h.Request(http.MethodGet, "/healthz", nil)), http.StatusOK)
+}
@@ -0,0 +1,206 @@
+// Package live proxies long-lived WebSocket and Server-Sent Events
+// connections to upstreams for authenticated users.
+package live
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/problem"
+	pb "github.com/rekib0023/event-horizon-gateway/proto"
+)
+
+// Proxy tracks live connections so that it can cap them per user and
+// close them on shutdown, which http.Server.Shutdown does not do for
+// hijacked or streaming connections.
+type Proxy struct {
+	cfg        config.Live
+	httpClient *http.Client
+	upgrader   websocket.Upgrader
+	dialer     *websocket.Dialer
+
+	mu       sync.Mutex
+	closing  bool
+	perUser  map[string]int
+	sessions map[*session]struct{}
+	wg       sync.WaitGroup
+}
+
+// session is one proxied connection. close ends it from outside.
+type session struct {
+	user     string
+	close    func()
+	activity atomic.Int64
+}
+
+func (s *session) touch() {
+	s.activity.Store(time.Now().UnixNano())
+}
+
+func (s *session) idleFor() time.Duration {
+	return time.Since(time.Unix(0, s.activity.Load()))
+}
+
+// New proxies to upstream URLs built by the caller. SSE requests go
+// through httpClient so that they are instrumented like other upstream
+// calls.
+func New(cfg config.Live, httpClient *http.Client) *Proxy {
+	p := &Proxy{
+		cfg:        cfg,
+		httpClient: httpClient,
+		dialer:     &websocket.Dialer{HandshakeTimeout: 10 * time.Second},
+		perUser:    map[string]int{},
+		sessions:   map[*session]struct{}{},
+	}
+	p.upgrader = websocket.Upgrader{CheckOrigin: p.checkOrigin}
+	return p
+}
+
+// checkOrigin allows same-host origins and the configured ones, so that
+// other sites cannot ride on the user's cookie.
+func (p *Proxy) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	for _, allowed := range p.cfg.AllowedOrigins {
+		if origin == allowed {
+			return true
+		}
+	}
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// Handler proxies to the URL returned by upstream, over WebSocket when the
+// client asks to upgrade and as SSE otherwise. It must run after the auth
+// middleware, which validates the token once for the whole connection.
+func (p *Proxy) Handler(upstream func(c *gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, s := p.start(c)
+		if s == nil {
+			return
+		}
+		defer p.release(s)
+
+		header := http.Header{}
+		header.Set("X-User-ID", currentUser.Id)
+		header.Set("X-User-Email", currentUser.Email)
+
+		if websocket.IsWebSocketUpgrade(c.Request) {
+			p.proxyWebSocket(c, s, upstream(c), header)
+			return
+		}
+		p.proxySSE(c, s, upstream(c), header)
+	}
+}
+
+// start opens a session for the authenticated user, or aborts.
+func (p *Proxy) start(c *gin.Context) (*pb.TokenVerification, *session) {
+	user, ok := c.Get("user")
+	currentUser, _ := user.(*pb.TokenVerification)
+	if !ok || currentUser == nil {
+		problem.Abort(c, problem.New(http.StatusInternalServerError, "Internal server error"))
+		return nil, nil
+	}
+	s, prob := p.open(currentUser.Id)
+	if prob != nil {
+		problem.Abort(c, prob)
+		return nil, nil
+	}
+	return currentUser, s
+}
+
+func (p *Proxy) open(user string) (*session, *problem.Problem) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closing {
+		return nil, problem.New(http.StatusServiceUnavailable, "The gateway is shutting down")
+	}
+	if p.cfg.MaxConnsPerUser > 0 && p.perUser[user] >= p.cfg.MaxConnsPerUser {
+		return nil, problem.New(http.StatusTooManyRequests, "Too many live connections")
+	}
+	s := &session{user: user, close: func() {}}
+	s.touch()
+	p.perUser[user]++
+	p.sessions[s] = struct{}{}
+	p.wg.Add(1)
+	return s, nil
+}
+
+func (p *Proxy) release(s *session) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.perUser[s.user]--; p.perUser[s.user] <= 0 {
+		delete(p.perUser, s.user)
+	}
+	delete(p.sessions, s)
+	p.wg.Done()
+}
+
+func (p *Proxy) setClose(s *session, close func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s.close = close
+}
+
+// watchIdle calls s.close once no message has passed for the idle
+// timeout, until ctx is done.
+func (p *Proxy) watchIdle(ctx context.Context, s *session) {
+	timeout := p.cfg.IdleTimeout.Std()
+	if timeout <= 0 {
+		return
+	}
+	ticker := time.NewTicker(timeout / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if s.idleFor() >= timeout {
+				s.close()
+				return
+			}
+		}
+	}
+}
+
+// Active returns the number of open live connections.
+func (p *Proxy) Active() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.sessions)
+}
+
+// Shutdown refuses new connections, closes open ones and waits for them
+// to finish or for ctx to expire.
+func (p *Proxy) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	p.closing = true
+	for s := range p.sessions {
+		s.close()
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.Join(errors.New("live connections still open"), ctx.Err())
+	}
+}
@@ -0,0 +1,115 @@
+package live_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/gatewaytest"
+)
+
+func TestLiveSSE(t *testing.T) {
+	h := gatewaytest.New(t, nil)
+	user := h.AddUser("ada@example.com")
+	h.Events.Handle(http.MethodGet, "/events/7/live", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "id: 1\ndata: {\"attendees\":3}\n\n")
+	})
+
+	rec := h.DoAs(user, http.MethodGet, "/api/events/7/live", nil)
+	h.ExpectStatus(rec, http.StatusOK)
+	if got := rec.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", got)
+	}
+	if !strings.Contains(rec.Body.String(), "data: {\"attendees\":3}\n\n") {
+		t.Fatalf("body = %q", rec.Body.String())
+	}
+	h.ExpectProxiedHeader("X-User-ID", fmt.Sprint(user.Id))
+}
+
+func TestLiveSSENeedsEventStream(t *testing.T) {
+	h := gatewaytest.New(t, nil)
+	user := h.AddUser("ada@example.com")
+
+	// The default fake answers with JSON.
+	h.ExpectStatus(h.DoAs(user, http.MethodGet, "/api/events/7/live", nil), http.StatusBadGateway)
+}
+
+func TestLiveConnectionLimit(t *testing.T) {
+	h := gatewaytest.New(t, func(cfg *config.Config) {
+		cfg.Live.MaxConnsPerUser = 1
+	})
+	user := h.AddUser("ada@example.com")
+	opened, release := make(chan struct{}), make(chan struct{})
+	h.Events.Handle(http.MethodGet, "/events/7/live", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		opened <- struct{}{}
+		<-release
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.DoAs(user, http.MethodGet, "/api/events/7/live", nil)
+	}()
+	<-opened
+
+	h.ExpectStatus(h.DoAs(user, http.MethodGet, "/api/events/7/live", nil), http.StatusTooManyRequests)
+	close(release)
+	<-done
+}
+
+func TestLiveWebSocket(t *testing.T) {
+	h := gatewaytest.New(t, nil)
+	user := h.AddUser("ada@example.com")
+	var upgrader websocket.Upgrader
+	h.Events.Handle(http.MethodGet, "/events/7/live", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		kind, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.WriteMessage(kind, []byte(r.Header.Get("X-User-Email")+": "+string(msg)))
+	})
+	srv := httptest.NewServer(h.Gateway)
+	t.Cleanup(srv.Close)
+
+	header := http.Header{"Cookie": {"token=" + user.GetToken()}}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/api/events/7/live", header)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	_, msg, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg) != "ada@example.com: hello" {
+		t.Fatalf("message = %q", msg)
+	}
+}
+
+func TestLiveWebSocketOrigin(t *testing.T) {
+	h := gatewaytest.New(t, nil)
+	user := h.AddUser("ada@example.com")
+
+	req := gatewaytest.As(h.Request(http.MethodGet, "/api/events/7/live", nil), user.GetToken())
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Origin", "https://evil.example")
+	h.ExpectStatus(h.Do(req), http.StatusForbidden)
+}
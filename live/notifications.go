@@ -0,0 +1,156 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/rekib0023/event-horizon-gateway/notify"
+)
+
+const writeTimeout = 10 * time.Second
+
+// Notifications streams the hub messages the user may see, narrowed to
+// the :eventId route parameter when there is one. Clients resume with
+// Last-Event-ID, or with the lastEventId query parameter over WebSocket
+// where browsers cannot set headers. A heartbeat is sent when nothing
+// else was for that long.
+func (p *Proxy) Notifications(hub *notify.Hub, heartbeat time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, s := p.start(c)
+		if s == nil {
+			return
+		}
+		defer p.release(s)
+
+		lastEventID := c.GetHeader("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = c.Query("lastEventId")
+		}
+		sub := hub.Subscribe(c.Param("eventId"), lastEventID)
+		defer sub.Close()
+
+		n := notifier{hub: hub, sub: sub, user: currentUser.Id, heartbeat: heartbeat}
+		if websocket.IsWebSocketUpgrade(c.Request) {
+			p.notifyWebSocket(c, s, n)
+			return
+		}
+		p.notifySSE(c, s, n)
+	}
+}
+
+type notifier struct {
+	hub       *notify.Hub
+	sub       *notify.Subscription
+	user      string
+	heartbeat time.Duration
+}
+
+// run sends the backlog and then new messages until ctx is done, a send
+// fails or the hub drops the subscription.
+func (n notifier) run(ctx context.Context, send func(notify.Message) error, ping func() error) {
+	for _, m := range n.sub.Backlog {
+		if (m.Type == notify.TypeReset || n.hub.Allowed(ctx, m, n.user)) && send(m) != nil {
+			return
+		}
+	}
+
+	var tick <-chan time.Time
+	if n.heartbeat > 0 {
+		ticker := time.NewTicker(n.heartbeat)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m, ok := <-n.sub.C:
+			if !ok {
+				return
+			}
+			if n.hub.Allowed(ctx, m, n.user) && send(m) != nil {
+				return
+			}
+		case <-tick:
+			if ping() != nil {
+				return
+			}
+		}
+	}
+}
+
+func (p *Proxy) notifySSE(c *gin.Context, s *session, n notifier) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	p.setClose(s, cancel)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache, no-transform")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	n.run(ctx, func(m notify.Message) error {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		if m.ID != "" {
+			fmt.Fprintf(c.Writer, "id: %s\n", m.ID)
+		}
+		if _, err := fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", m.Type, data); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	}, func() error {
+		_, err := c.Writer.WriteString(": ping\n\n")
+		c.Writer.Flush()
+		return err
+	})
+}
+
+func (p *Proxy) notifyWebSocket(c *gin.Context, s *session, n notifier) {
+	conn, err := p.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if p.cfg.MaxMessageBytes > 0 {
+		conn.SetReadLimit(p.cfg.MaxMessageBytes)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	closeWith := func(code int, text string) {
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(time.Second))
+		conn.Close()
+	}
+	p.setClose(s, func() { closeWith(websocket.CloseGoingAway, "going away") })
+
+	// Clients have nothing to say; reading processes their control frames
+	// and notices when they leave.
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	n.run(ctx, func(m notify.Message) error {
+		conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		return conn.WriteJSON(m)
+	}, func() error {
+		return conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout))
+	})
+	if n.sub.Lagged() {
+		closeWith(websocket.CloseTryAgainLater, "lagged")
+	}
+}
@@ -0,0 +1,90 @@
+package live
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rekib0023/event-horizon-gateway/logging"
+	"github.com/rekib0023/event-horizon-gateway/problem"
+)
+
+func (p *Proxy) proxySSE(c *gin.Context, s *session, target string, header http.Header) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	p.setClose(s, cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		problem.Abort(c, problem.New(http.StatusInternalServerError, "Internal server error"))
+		return
+	}
+	req.Header = header
+	req.Header.Set("Accept", "text/event-stream")
+	if id := c.GetHeader("Last-Event-ID"); id != "" {
+		req.Header.Set("Last-Event-ID", id)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		logging.FromContext(ctx).Warn("upstream call failed", "upstream", "event-service", "error", err)
+		problem.Abort(c, problem.New(http.StatusBadGateway, "Event service is unavailable"))
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		problem.Abort(c, problem.FromUpstream(resp))
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+		problem.Abort(c, problem.New(http.StatusBadGateway, "Event service did not open an event stream"))
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache, no-transform")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	go p.watchIdle(ctx, s)
+
+	// Events are relayed line by line and flushed at each blank line that
+	// ends one. An event over the size limit ends the stream.
+	r := bufio.NewReader(resp.Body)
+	size := int64(0)
+	for {
+		line, err := r.ReadSlice('\n')
+		if len(line) > 0 {
+			size += int64(len(line))
+			if p.cfg.MaxMessageBytes > 0 && size > p.cfg.MaxMessageBytes {
+				logging.FromContext(ctx).Warn("event stream message too big", "limit", p.cfg.MaxMessageBytes)
+				return
+			}
+			if _, werr := c.Writer.Write(line); werr != nil {
+				return
+			}
+			if len(line) <= 2 && (line[0] == '\n' || line[0] == '\r') {
+				c.Writer.Flush()
+				s.touch()
+				size = 0
+			}
+		}
+		if err != nil && !errors.Is(err, bufio.ErrBufferFull) {
+			return
+		}
+	}
+}
+
+func doneContext(done <-chan struct{}) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-done
+		cancel()
+	}()
+	return ctx
+}
@@ -0,0 +1,106 @@
+package live
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/rekib0023/event-horizon-gateway/logging"
+	"github.com/rekib0023/event-horizon-gateway/problem"
+	"github.com/rekib0023/event-horizon-gateway/tracing"
+)
+
+func (p *Proxy) proxyWebSocket(c *gin.Context, s *session, target string, header http.Header) {
+	if !p.checkOrigin(c.Request) {
+		problem.Abort(c, problem.New(http.StatusForbidden, "Origin not allowed"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	target = "ws" + strings.TrimPrefix(target, "http")
+	header.Set(logging.RequestIDHeader, logging.RequestIDFromContext(ctx))
+	tracing.Inject(ctx, header)
+	if protocols := c.GetHeader("Sec-WebSocket-Protocol"); protocols != "" {
+		header.Set("Sec-WebSocket-Protocol", protocols)
+	}
+
+	upstream, resp, err := p.dialer.DialContext(ctx, target, header)
+	if err != nil {
+		if resp != nil && resp.StatusCode >= 400 {
+			problem.Abort(c, problem.FromUpstream(resp))
+			return
+		}
+		logging.FromContext(ctx).Warn("upstream call failed", "upstream", "event-service", "error", err)
+		problem.Abort(c, problem.New(http.StatusBadGateway, "Event service is unavailable"))
+		return
+	}
+	defer upstream.Close()
+
+	responseHeader := http.Header{}
+	if protocol := upstream.Subprotocol(); protocol != "" {
+		responseHeader.Set("Sec-WebSocket-Protocol", protocol)
+	}
+	client, err := p.upgrader.Upgrade(c.Writer, c.Request, responseHeader)
+	if err != nil {
+		// The upgrader has answered the client already.
+		return
+	}
+	defer client.Close()
+
+	if p.cfg.MaxMessageBytes > 0 {
+		client.SetReadLimit(p.cfg.MaxMessageBytes)
+		upstream.SetReadLimit(p.cfg.MaxMessageBytes)
+	}
+
+	var once sync.Once
+	closeBoth := func(code int, text string) {
+		once.Do(func() {
+			msg := websocket.FormatCloseMessage(code, text)
+			deadline := time.Now().Add(time.Second)
+			client.WriteControl(websocket.CloseMessage, msg, deadline)
+			upstream.WriteControl(websocket.CloseMessage, msg, deadline)
+			client.Close()
+			upstream.Close()
+		})
+	}
+	p.setClose(s, func() { closeBoth(websocket.CloseGoingAway, "going away") })
+
+	done := make(chan struct{})
+	defer close(done)
+	go p.watchIdle(doneContext(done), s)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		closeBoth(pump(upstream, client, s))
+	}()
+	closeBoth(pump(client, upstream, s))
+	wg.Wait()
+}
+
+// pump copies messages from src to dst until either side fails, and
+// returns the close frame to send to both.
+func pump(dst, src *websocket.Conn, s *session) (int, string) {
+	for {
+		messageType, data, err := src.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			switch {
+			case errors.Is(err, websocket.ErrReadLimit):
+				return websocket.CloseMessageTooBig, "message too big"
+			case errors.As(err, &closeErr) && closeErr.Code != websocket.CloseNoStatusReceived && closeErr.Code != websocket.CloseAbnormalClosure:
+				return closeErr.Code, closeErr.Text
+			}
+			return websocket.CloseGoingAway, "going away"
+		}
+		s.touch()
+		if err := dst.WriteMessage(messageType, data); err != nil {
+			return websocket.CloseGoingAway, "going away"
+		}
+	}
+}
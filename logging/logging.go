@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/rekib0023/event-horizon-gateway/config"
+)
+
+const redacted = "[REDACTED]"
+
+var sensitiveKeys = []string{"password", "token", "cookie", "authorization", "secret"}
+
+// sensitiveValue matches credentials embedded in free-form strings such as
+// upstream error messages, e.g. `password=hunter2` or `"token": "abc"`.
+var sensitiveValue = regexp.MustCompile(`(?i)("?(?:password|token|cookie|authorization|secret)"?\s*[:=]\s*"?)(?:Bearer\s+)?[^\s",;&}]+`)
+
+// bearerToken matches bearer credentials that appear without a key.
+var bearerToken = regexp.MustCompile(`(?i)(\bBearer\s+)[^\s",;&}]+`)
+
+// New builds the gateway's JSON (or text) logger. The returned LevelVar
+// changes the level at runtime.
+func New(w io.Writer, cfg config.Logging) (*slog.Logger, *slog.LevelVar, error) {
+	level := new(slog.LevelVar)
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, nil, fmt.Errorf("invalid log level %q", cfg.Level)
+	}
+
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+	var handler slog.Handler
+	switch cfg.Format {
+	case "", "json":
+		handler = slog.NewJSONHandler(w, opts)
+	case "text":
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return nil, nil, fmt.Errorf("invalid log format %q", cfg.Format)
+	}
+	return slog.New(handler), level, nil
+}
+
+// redact masks attributes whose key names a credential and scrubs
+// credentials out of string values.
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if IsSensitive(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Scrub(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, Scrub(err.Error()))
+		}
+	}
+	return a
+}
+
+// IsSensitive reports whether a header, field or attribute name carries a
+// credential.
+func IsSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Scrub masks credentials embedded in s.
+func Scrub(s string) string {
+	s = sensitiveValue.ReplaceAllString(s, "${1}"+redacted)
+	return bearerToken.ReplaceAllString(s, "${1}"+redacted)
+}
+
+type loggerKey struct{}
+
+// FromContext returns the request-scoped logger, or the default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+// With returns a context whose logger carries the extra attributes.
+func With(ctx context.Context, args ...any) context.Context {
+	return context.WithValue(ctx, loggerKey{}, FromContext(ctx).With(args...))
+}
@@ -0,0 +1,93 @@
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/logging"
+)
+
+func TestScrub(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"login failed: password=hunter2", "login failed: password=[REDACTED]"},
+		{"PASSWORD = hunter2 for ada", "PASSWORD = [REDACTED] for ada"},
+		{`{"token": "abc.def.ghi", "user": "ada"}`, `{"token": "[REDACTED]", "user": "ada"}`},
+		{"/verify?token=abc&next=/home", "/verify?token=[REDACTED]&next=/home"},
+		{"Authorization: Bearer abc.def", "Authorization: [REDACTED]"},
+		{"upstream rejected Bearer abc.def", "upstream rejected Bearer [REDACTED]"},
+		{"client_secret=s3cr3t; cookie=sid=1", "client_secret=[REDACTED]; cookie=[REDACTED]"},
+		{"nothing to see here", "nothing to see here"},
+	} {
+		if got := logging.Scrub(tc.in); got != tc.want {
+			t.Errorf("Scrub(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestIsSensitive(t *testing.T) {
+	for key, want := range map[string]bool{
+		"Authorization": true,
+		"X-Auth-Token":  true,
+		"Set-Cookie":    true,
+		"password":      true,
+		"clientSecret":  true,
+		"X-Request-ID":  false,
+		"email":         false,
+	} {
+		if got := logging.IsSensitive(key); got != want {
+			t.Errorf("IsSensitive(%q) = %t, want %t", key, got, want)
+		}
+	}
+}
+
+func TestLoggerRedacts(t *testing.T) {
+	var buf bytes.Buffer
+	logger, _, err := logging.New(&buf, config.Logging{Level: "info"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("signup",
+		"password", "hunter2",
+		"detail", "retry with token=abc",
+		"error", errors.New("GetUser: Bearer abc.def"),
+		slog.Group("req", "authorization", "Bearer abc.def", "path", "/api/users"),
+	)
+
+	var entry struct {
+		Password string `json:"password"`
+		Detail   string `json:"detail"`
+		Error    string `json:"error"`
+		Req      struct {
+			Authorization string `json:"authorization"`
+			Path          string `json:"path"`
+		} `json:"req"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Password != "[REDACTED]" || entry.Req.Authorization != "[REDACTED]" {
+		t.Fatalf("sensitive keys kept: %s", buf.String())
+	}
+	if entry.Detail != "retry with token=[REDACTED]" || entry.Error != "GetUser: Bearer [REDACTED]" || entry.Req.Path != "/api/users" {
+		t.Fatalf("entry = %+v", entry)
+	}
+	if strings.Contains(buf.String(), "hunter2") || strings.Contains(buf.String(), "abc") {
+		t.Fatalf("credential leaked: %s", buf.String())
+	}
+}
+
+func TestNewRejectsBadConfig(t *testing.T) {
+	if _, _, err := logging.New(&bytes.Buffer{}, config.Logging{Level: "loud"}); err == nil {
+		t.Error("accepted an unknown level")
+	}
+	if _, _, err := logging.New(&bytes.Buffer{}, config.Logging{Level: "info", Format: "xml"}); err == nil {
+		t.Error("accepted an unknown format")
+	}
+}
@@ -0,0 +1,126 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	pb "github.com/rekib0023/event-horizon-gateway/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type requestIDKey struct{}
+
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// Middleware accepts a well-formed X-Request-ID or generates one, echoes it
+// in the response, and attaches a logger carrying the request id and route
+// to the request context. The completed request is logged at debug level,
+// or at error level for 5xx responses.
+func Middleware(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		c.Set("requestId", id)
+		c.Header(RequestIDHeader, id)
+
+		ctx := context.WithValue(c.Request.Context(), requestIDKey{}, id)
+		ctx = context.WithValue(ctx, loggerKey{}, logger.With(
+			"request_id", id,
+			"route", c.FullPath(),
+		))
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		statusCode := c.Writer.Status()
+		level := slog.LevelDebug
+		if statusCode >= 500 {
+			level = slog.LevelError
+		}
+		attrs := []any{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", statusCode,
+			"latency_ms", time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+		}
+		if v, ok := c.Get("user"); ok {
+			if user, ok := v.(*pb.TokenVerification); ok {
+				attrs = append(attrs, "user_id", user.GetId())
+			}
+		}
+		FromContext(c.Request.Context()).Log(c.Request.Context(), level, "request completed", attrs...)
+	}
+}
+
+// UnaryClientInterceptor forwards the request id to the auth service as
+// x-request-id metadata and logs each call.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if id := RequestIDFromContext(ctx); id != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "x-request-id", id)
+		}
+
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		attrs := []any{
+			"upstream", "auth-service",
+			"rpc", method,
+			"code", status.Code(err).String(),
+			"latency_ms", time.Since(start).Milliseconds(),
+		}
+		if err != nil {
+			FromContext(ctx).Warn("upstream call failed", append(attrs, "error", status.Convert(err).Message())...)
+		} else {
+			FromContext(ctx).Debug("upstream call", attrs...)
+		}
+		return err
+	}
+}
+
+// Transport forwards the request id to HTTP upstreams.
+func Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if id := RequestIDFromContext(req.Context()); id != "" {
+			req = req.Clone(req.Context())
+			req.Header.Set(RequestIDHeader, id)
+		}
+		return next.RoundTrip(req)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
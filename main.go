@@ -1,16 +1,58 @@
 package main
 
 import (
+	"context"
+	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/gin-gonic/gin"
-	"github.com/rekib0023/event-horizon-gateway/controller"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/gateway"
+	"github.com/rekib0023/event-horizon-gateway/logging"
+	"github.com/rekib0023/event-horizon-gateway/tracing"
 )
 
 func main() {
-	if os.Getenv("ENVIRONMENT") == "release" {
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("Failed to load config", "error", err)
+		os.Exit(1)
+	}
+
+	logger, logLevel, err := logging.New(os.Stdout, cfg.Logging)
+	if err != nil {
+		slog.Error("Failed to set up logging", "error", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
+
+	if cfg.Environment == "release" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	controller.Start()
+	if err := run(cfg, logger, logLevel); err != nil {
+		slog.Error("Gateway failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run(cfg *config.Config, logger *slog.Logger, logLevel *slog.LevelVar) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		return err
+	}
+	defer shutdownTracing(context.Background())
+
+	g, err := gateway.New(cfg, gateway.WithLogger(logger), gateway.WithLogLevel(logLevel))
+	if err != nil {
+		return err
+	}
+	defer g.Close(context.Background())
+
+	return g.Run(ctx)
 }
@@ -0,0 +1,169 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const namespace = "gateway"
+
+// Upstream names used in the upstream_* metrics.
+const (
+	UpstreamAuth   = "auth-service"
+	UpstreamEvents = "event-service"
+)
+
+// Metrics holds the gateway's Prometheus collectors on a dedicated
+// registry.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests          *prometheus.CounterVec
+	requestDuration   *prometheus.HistogramVec
+	inFlight          prometheus.Gauge
+	upstreamRequests  *prometheus.CounterVec
+	upstreamDuration  *prometheus.HistogramVec
+	authVerifications *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests handled by the gateway.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of HTTP requests handled by the gateway.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "HTTP requests currently being handled.",
+		}),
+		upstreamRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "upstream_requests_total",
+			Help:      "Calls made to upstream services, by result code.",
+		}, []string{"upstream", "operation", "code"}),
+		upstreamDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "upstream_request_duration_seconds",
+			Help:      "Latency of calls made to upstream services.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"upstream", "operation"}),
+		authVerifications: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "auth_verifications_total",
+			Help:      "Token verifications performed by the auth middleware, by outcome.",
+		}, []string{"outcome"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.inFlight,
+		m.upstreamRequests,
+		m.upstreamDuration,
+		m.authVerifications,
+	)
+	return m
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Middleware records request metrics labelled by route template, so that
+// /events/:eventId is a single series regardless of the id.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m.inFlight.Inc()
+		start := time.Now()
+
+		c.Next()
+
+		m.inFlight.Dec()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		code := strconv.Itoa(c.Writer.Status())
+		m.requests.WithLabelValues(route, c.Request.Method, code).Inc()
+		m.requestDuration.WithLabelValues(route, c.Request.Method, code).Observe(time.Since(start).Seconds())
+	}
+}
+
+// AuthVerified implements middlewares.AuthObserver.
+func (m *Metrics) AuthVerified(outcome string) {
+	m.authVerifications.WithLabelValues(outcome).Inc()
+}
+
+func (m *Metrics) observeUpstream(upstream, operation, code string, d time.Duration) {
+	m.upstreamRequests.WithLabelValues(upstream, operation, code).Inc()
+	m.upstreamDuration.WithLabelValues(upstream, operation).Observe(d.Seconds())
+}
+
+// UnaryClientInterceptor records latency and status codes of calls to the
+// auth service per gRPC method.
+func (m *Metrics) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		m.observeUpstream(UpstreamAuth, method, status.Code(err).String(), time.Since(start))
+		return err
+	}
+}
+
+type operationKey struct{}
+
+// WithOperation labels outgoing HTTP requests made with ctx, typically with
+// the route template being proxied.
+func WithOperation(ctx context.Context, operation string) context.Context {
+	return context.WithValue(ctx, operationKey{}, operation)
+}
+
+// Transport wraps next to record calls to an HTTP upstream. Transport
+// errors are counted with code "error".
+func (m *Metrics) Transport(upstream string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		operation, _ := req.Context().Value(operationKey{}).(string)
+		if operation == "" {
+			operation = req.Method
+		}
+
+		start := time.Now()
+		resp, err := next.RoundTrip(req)
+		code := "error"
+		if err == nil {
+			code = strconv.Itoa(resp.StatusCode)
+		}
+		m.observeUpstream(upstream, operation, code, time.Since(start))
+		return resp, err
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
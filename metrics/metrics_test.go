@@ -0,0 +1,100 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rekib0023/event-horizon-gateway/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func scrape(t *testing.T, m *metrics.Metrics) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	return rec.Body.String()
+}
+
+func expectSeries(t *testing.T, body string, series ...string) {
+	t.Helper()
+	for _, s := range series {
+		if !strings.Contains(body, s+"\n") {
+			t.Errorf("missing %s", s)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	m := metrics.New()
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(m.Middleware())
+	e.GET("/events/:eventId", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	for _, path := range []string{"/events/1", "/events/2", "/nowhere"} {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	expectSeries(t, scrape(t, m),
+		`gateway_http_requests_total{method="GET",route="/events/:eventId",status="204"} 2`,
+		`gateway_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`gateway_http_request_duration_seconds_count{method="GET",route="/events/:eventId",status="204"} 2`,
+		`gateway_http_requests_in_flight 0`,
+	)
+}
+
+func TestUpstreams(t *testing.T) {
+	m := metrics.New()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer upstream.Close()
+	client := &http.Client{Transport: m.Transport(metrics.UpstreamEvents, nil)}
+
+	req, _ := http.NewRequestWithContext(metrics.WithOperation(context.Background(), "GET /events/:eventId"), http.MethodGet, upstream.URL+"/events/1", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	failing := &http.Client{Transport: m.Transport(metrics.UpstreamEvents, roundTripper(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("refused")
+	}))}
+	if _, err := failing.Post(upstream.URL+"/events", "application/json", nil); err == nil {
+		t.Fatal("expected a transport error")
+	}
+
+	interceptor := m.UnaryClientInterceptor()
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return status.Error(codes.NotFound, "no such user")
+	}
+	interceptor(context.Background(), "/auth.AuthService/GetUserById", nil, nil, nil, invoker)
+
+	m.AuthVerified("valid")
+	m.AuthVerified("valid")
+
+	expectSeries(t, scrape(t, m),
+		`gateway_upstream_requests_total{code="404",operation="GET /events/:eventId",upstream="event-service"} 1`,
+		`gateway_upstream_requests_total{code="error",operation="POST",upstream="event-service"} 1`,
+		`gateway_upstream_requests_total{code="NotFound",operation="/auth.AuthService/GetUserById",upstream="auth-service"} 1`,
+		`gateway_upstream_request_duration_seconds_count{operation="GET /events/:eventId",upstream="event-service"} 1`,
+		`gateway_auth_verifications_total{outcome="valid"} 2`,
+	)
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -1,37 +1,66 @@
 package middlewares
 
 import (
-	"context"
-	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rekib0023/event-horizon-gateway/accesslog"
+	"github.com/rekib0023/event-horizon-gateway/logging"
+	"github.com/rekib0023/event-horizon-gateway/problem"
 	pb "github.com/rekib0023/event-horizon-gateway/proto"
+	"github.com/rekib0023/event-horizon-gateway/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-type AuthMiddleware struct {
+// Outcomes reported to an AuthObserver.
+const (
+	AuthSuccess      = "success"
+	AuthMissingToken = "missing_token"
+	AuthInvalidToken = "invalid_token"
+	AuthError        = "error"
+)
+
+// AuthObserver is notified of every token verification outcome.
+type AuthObserver interface {
+	AuthVerified(outcome string)
 }
 
-var authMiddleware *AuthMiddleware
+// TokenAuthMiddleware verifies the token cookie with the auth service. Only
+// a rejected token answers 401; other failures, such as the auth service
+// being unavailable, are mapped through statusMapping.
+func TokenAuthMiddleware(gRpc pb.AuthServiceClient, statusMapping *utils.StatusMapping, observers ...AuthObserver) gin.HandlerFunc {
+	observe := func(outcome string) {
+		for _, o := range observers {
+			o.AuthVerified(outcome)
+		}
+	}
 
-func TokenAuthMiddleware(gRpc pb.AuthServiceClient) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token, err := c.Cookie("token")
 
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
-			c.Abort()
+			observe(AuthMissingToken)
+			problem.Abort(c, problem.New(http.StatusUnauthorized, "Authorization header is required"))
 			return
 		}
 
-		res, err := gRpc.VerifyToken(context.Background(), &pb.Token{Token: token})
-		if err != nil {
-			log.Printf("could not call VerifyToken: %v", err)
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Token"})
-			c.Abort()
+		res, err := gRpc.VerifyToken(accesslog.WithAuthPhase(c.Request.Context()), &pb.Token{Token: token})
+		switch status.Code(err) {
+		case codes.OK:
+		case codes.Unauthenticated, codes.InvalidArgument:
+			observe(AuthInvalidToken)
+			problem.Abort(c, problem.New(http.StatusUnauthorized, "Invalid Token"))
+			return
+		default:
+			observe(AuthError)
+			logging.FromContext(c.Request.Context()).Warn("token verification failed", "upstream", "auth-service", "error", err)
+			problem.Abort(c, problem.FromGRPC(err, pb.AuthService_VerifyToken_FullMethodName, statusMapping))
 			return
 		}
+		observe(AuthSuccess)
 		c.Set("user", res)
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), "user_id", res.GetId()))
 		c.Next()
 	}
 }
@@ -0,0 +1,72 @@
+package middlewares_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rekib0023/event-horizon-gateway/middlewares"
+	pb "github.com/rekib0023/event-horizon-gateway/proto"
+	"github.com/rekib0023/event-horizon-gateway/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakeAuth struct {
+	pb.AuthServiceClient
+	err error
+}
+
+func (f fakeAuth) VerifyToken(ctx context.Context, in *pb.Token, opts ...grpc.CallOption) (*pb.TokenVerification, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if in.GetToken() != "valid" {
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	return &pb.TokenVerification{Id: "1"}, nil
+}
+
+func serve(t *testing.T, auth pb.AuthServiceClient, token string) int {
+	statusMapping, err := utils.NewStatusMapping(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.GET("/", middlewares.TokenAuthMiddleware(auth, statusMapping), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if token != "" {
+		req.AddCookie(&http.Cookie{Name: "token", Value: token})
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestTokenAuth(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		err   error
+		want  int
+	}{
+		{name: "valid", token: "valid", want: http.StatusOK},
+		{name: "missing", want: http.StatusUnauthorized},
+		{name: "forged", token: "forged", want: http.StatusUnauthorized},
+		{name: "invalid argument", token: "valid", err: status.Error(codes.InvalidArgument, "malformed"), want: http.StatusUnauthorized},
+		{name: "unavailable", token: "valid", err: status.Error(codes.Unavailable, "down"), want: http.StatusServiceUnavailable},
+		{name: "deadline", token: "valid", err: status.Error(codes.DeadlineExceeded, "slow"), want: http.StatusGatewayTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(t, fakeAuth{err: tt.err}, tt.token); got != tt.want {
+				t.Fatalf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/rekib0023/event-horizon-gateway/metrics"
+	"github.com/rekib0023/event-horizon-gateway/utils"
+)
+
+// EventAttendance answers from the event service's attendee lists. A list
+// is fetched as the user asking about it, since the event service may
+// show each caller a different list, and the answer is cached per event
+// and user for ttl.
+type EventAttendance struct {
+	Now func() time.Time
+
+	client  *http.Client
+	baseURL string
+	path    string
+	ttl     time.Duration
+
+	mu      sync.Mutex
+	answers map[string]map[string]answer
+}
+
+type answer struct {
+	attends bool
+	fetched time.Time
+}
+
+// NewEventAttendance reads attendee lists at the dotted path of the event
+// service response, like config.Attendees.Path.
+func NewEventAttendance(client *http.Client, baseURL, path string, ttl time.Duration) *EventAttendance {
+	return &EventAttendance{
+		Now:     time.Now,
+		client:  client,
+		baseURL: baseURL,
+		path:    path,
+		ttl:     ttl,
+		answers: map[string]map[string]answer{},
+	}
+}
+
+func (a *EventAttendance) Attends(ctx context.Context, eventID, userID string) (bool, error) {
+	a.mu.Lock()
+	cached, ok := a.answers[eventID][userID]
+	a.mu.Unlock()
+	if ok && a.Now().Sub(cached.fetched) < a.ttl {
+		return cached.attends, nil
+	}
+
+	ids, err := a.fetch(ctx, eventID, userID)
+	if err != nil {
+		return false, err
+	}
+	now := a.Now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	users := a.answers[eventID]
+	if users == nil {
+		users = map[string]answer{}
+		a.answers[eventID] = users
+	}
+	for user, cached := range users {
+		if now.Sub(cached.fetched) >= a.ttl {
+			delete(users, user)
+		}
+	}
+	users[userID] = answer{attends: ids[userID], fetched: now}
+	return ids[userID], nil
+}
+
+// Invalidate forgets what is known about the attendees of eventID.
+func (a *EventAttendance) Invalidate(eventID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.answers, eventID)
+}
+
+func (a *EventAttendance) fetch(ctx context.Context, eventID, userID string) (map[string]bool, error) {
+	ctx = metrics.WithOperation(ctx, "GET /events/:eventId/attendees")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.baseURL+"/events/"+url.PathEscape(eventID)+"/attendees", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-User-ID", userID)
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("event service answered %s", resp.Status)
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+	var data any
+	if err := dec.Decode(&data); err != nil {
+		return nil, fmt.Errorf("decode attendees: %w", err)
+	}
+	ids := map[string]bool{}
+	for _, id := range utils.CollectIDs(data, a.path) {
+		ids[id] = true
+	}
+	return ids, nil
+}
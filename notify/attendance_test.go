@@ -0,0 +1,63 @@
+package notify_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rekib0023/event-horizon-gateway/notify"
+)
+
+func TestEventAttendanceAsksAsEachUser(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path != "/events/7/attendees" {
+			http.NotFound(w, r)
+			return
+		}
+		// The list only shows attendees the caller is connected to.
+		if r.Header.Get("X-User-ID") == "1" {
+			fmt.Fprint(w, `{"attendees":[{"id":1},{"id":2}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"attendees":[]}`)
+	}))
+	defer srv.Close()
+
+	a := notify.NewEventAttendance(srv.Client(), srv.URL, "attendees", time.Minute)
+	now := time.Now()
+	a.Now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		user  string
+		want  bool
+		calls int32
+	}{
+		{"1", true, 1},
+		{"2", false, 2},
+		{"1", true, 2},
+	} {
+		got, err := a.Attends(ctx, "7", tc.user)
+		if err != nil || got != tc.want || calls.Load() != tc.calls {
+			t.Fatalf("user %s: attends = %v, %v after %d calls; want %v after %d", tc.user, got, err, calls.Load(), tc.want, tc.calls)
+		}
+	}
+
+	a.Invalidate("7")
+	a.Attends(ctx, "7", "1")
+	now = now.Add(2 * time.Minute)
+	a.Attends(ctx, "7", "1")
+	if calls.Load() != 4 {
+		t.Fatalf("calls = %d, want a refetch after invalidation and expiry", calls.Load())
+	}
+
+	if _, err := a.Attends(ctx, "8", "1"); err == nil {
+		t.Fatalf("a missing list was not an error")
+	}
+}
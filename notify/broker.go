@@ -0,0 +1,68 @@
+// Package notify fans event-change notifications out to the users they
+// concern.
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// TypeReset is sent ahead of the backlog when a client resumes from an
+// event id the hub no longer remembers, so it may have missed messages.
+const TypeReset = "reset"
+
+// Message is one change to an event. The hub assigns ID and Time.
+type Message struct {
+	ID      string          `json:"id,omitempty"`
+	EventID string          `json:"eventId"`
+	Type    string          `json:"type"`
+	OwnerID string          `json:"ownerId,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Time    time.Time       `json:"time"`
+
+	seq uint64
+}
+
+// Broker carries messages from publishers to the hub. A message broker
+// shared by several gateways lets every gateway reach its own clients.
+type Broker interface {
+	Publish(ctx context.Context, m Message) error
+	// Subscribe calls fn for each published message until unsubscribe is
+	// called. fn must not block.
+	Subscribe(fn func(Message)) (unsubscribe func())
+}
+
+// MemoryBroker delivers messages synchronously within one process.
+type MemoryBroker struct {
+	mu       sync.Mutex
+	next     int
+	handlers map[int]func(Message)
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{handlers: map[int]func(Message){}}
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, m Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, fn := range b.handlers {
+		fn(m)
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(fn func(Message)) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.next
+	b.next++
+	b.handlers[id] = fn
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.handlers, id)
+	}
+}
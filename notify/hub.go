@@ -0,0 +1,232 @@
+package notify
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/logging"
+	"github.com/rekib0023/event-horizon-gateway/problem"
+)
+
+// Attendance reports whether a user attends an event.
+type Attendance interface {
+	Attends(ctx context.Context, eventID, userID string) (bool, error)
+}
+
+// Hub numbers the messages coming from its broker, keeps the most recent
+// ones so that clients can resume, and hands them to subscriptions.
+// Subscribers that fall behind by more than the buffer are dropped rather
+// than slowing everyone down; they resume from their last event id.
+type Hub struct {
+	Now func() time.Time
+
+	cfg         config.Notifications
+	broker      Broker
+	attendance  Attendance
+	unsubscribe func()
+	// epoch tells apart ids issued before a restart.
+	epoch string
+
+	mu      sync.Mutex
+	seq     uint64
+	history []Message
+	subs    map[*Subscription]struct{}
+}
+
+func NewHub(cfg config.Notifications, broker Broker, attendance Attendance) *Hub {
+	h := &Hub{
+		Now:        time.Now,
+		cfg:        cfg,
+		broker:     broker,
+		attendance: attendance,
+		epoch:      strconv.FormatInt(time.Now().UnixNano(), 36),
+		subs:       map[*Subscription]struct{}{},
+	}
+	h.unsubscribe = broker.Subscribe(h.dispatch)
+	return h
+}
+
+func (h *Hub) Close() {
+	h.unsubscribe()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs {
+		delete(h.subs, s)
+		close(s.ch)
+	}
+}
+
+func (h *Hub) dispatch(m Message) {
+	if invalidator, ok := h.attendance.(interface{ Invalidate(string) }); ok && strings.HasPrefix(m.Type, "attendee.") {
+		invalidator.Invalidate(m.EventID)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seq++
+	m.seq = h.seq
+	m.ID = h.epoch + "-" + strconv.FormatUint(m.seq, 10)
+	m.Time = h.Now().UTC()
+	if h.cfg.History > 0 {
+		h.history = append(h.history, m)
+		if len(h.history) > h.cfg.History {
+			h.history = h.history[len(h.history)-h.cfg.History:]
+		}
+	}
+
+	for s := range h.subs {
+		if s.eventID != "" && s.eventID != m.EventID {
+			continue
+		}
+		select {
+		case s.ch <- m:
+		default:
+			s.lagged.Store(true)
+			delete(h.subs, s)
+			close(s.ch)
+		}
+	}
+}
+
+// Subscription receives the messages for one event, or for all events
+// when eventID is empty. Visibility is left to Allowed so that slow
+// attendance lookups only hold up their own subscriber.
+type Subscription struct {
+	C <-chan Message
+	// Backlog holds the remembered messages after the client's last event
+	// id. Reset reports that messages before them may have been missed, in
+	// which case Backlog starts with a TypeReset message.
+	Backlog []Message
+	Reset   bool
+
+	hub     *Hub
+	eventID string
+	ch      chan Message
+	lagged  atomic.Bool
+}
+
+// Subscribe starts a subscription resuming after lastEventID, which may be
+// empty for a fresh one.
+func (h *Hub) Subscribe(eventID, lastEventID string) *Subscription {
+	ch := make(chan Message, h.cfg.Buffer)
+	s := &Subscription{C: ch, hub: h, eventID: eventID, ch: ch}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if lastEventID != "" {
+		s.Backlog, s.Reset = h.after(lastEventID)
+		if eventID != "" {
+			backlog := s.Backlog[:0:0]
+			for _, m := range s.Backlog {
+				if m.EventID == eventID {
+					backlog = append(backlog, m)
+				}
+			}
+			s.Backlog = backlog
+		}
+		if s.Reset {
+			reset := Message{EventID: eventID, Type: TypeReset, Time: h.Now().UTC()}
+			s.Backlog = append([]Message{reset}, s.Backlog...)
+		}
+	}
+	h.subs[s] = struct{}{}
+	return s
+}
+
+// after returns the remembered messages following id.
+func (h *Hub) after(id string) ([]Message, bool) {
+	epoch, seq, ok := strings.Cut(id, "-")
+	last, err := strconv.ParseUint(seq, 10, 64)
+	if !ok || err != nil || epoch != h.epoch || last > h.seq {
+		return append([]Message(nil), h.history...), true
+	}
+	if len(h.history) == 0 || last >= h.seq {
+		return nil, false
+	}
+	first := h.history[0].seq
+	if last+1 < first {
+		return append([]Message(nil), h.history...), true
+	}
+	return append([]Message(nil), h.history[last+1-first:]...), false
+}
+
+// Lagged reports whether the hub dropped the subscription for falling
+// behind. C is closed by then.
+func (s *Subscription) Lagged() bool {
+	return s.lagged.Load()
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	if _, ok := s.hub.subs[s]; ok {
+		delete(s.hub.subs, s)
+		close(s.ch)
+	}
+}
+
+// Allowed reports whether userID may see m: they own or attend its event.
+func (h *Hub) Allowed(ctx context.Context, m Message, userID string) bool {
+	if m.OwnerID != "" && m.OwnerID == userID {
+		return true
+	}
+	if h.attendance == nil {
+		return false
+	}
+	ok, err := h.attendance.Attends(ctx, m.EventID, userID)
+	if err != nil {
+		logging.FromContext(ctx).Warn("could not check event attendance", "event_id", m.EventID, "error", err)
+		return false
+	}
+	return ok
+}
+
+// Register mounts the endpoint the event service posts changes to. Callers
+// authenticate with the configured token as a bearer token.
+func (h *Hub) Register(r gin.IRoutes) {
+	r.POST("/internal/notifications", h.authorize, h.publish)
+}
+
+func (h *Hub) authorize(c *gin.Context) {
+	given, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || h.cfg.Token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(h.cfg.Token)) != 1 {
+		p := problem.New(http.StatusUnauthorized, "Missing or invalid notification token")
+		p.Headers = http.Header{"WWW-Authenticate": {"Bearer"}}
+		problem.Abort(c, p)
+	}
+}
+
+func (h *Hub) publish(c *gin.Context) {
+	var m Message
+	if err := c.ShouldBindJSON(&m); err != nil {
+		problem.Abort(c, problem.FromBindError(err))
+		return
+	}
+	p := problem.New(http.StatusBadRequest, "Invalid notification")
+	if m.EventID == "" {
+		p = p.WithFieldError("eventId", "is required")
+	}
+	if m.Type == "" || m.Type == TypeReset {
+		p = p.WithFieldError("type", "is required and must not be "+TypeReset)
+	}
+	if len(p.Errors) > 0 {
+		problem.Abort(c, p)
+		return
+	}
+	m.ID, m.Time = "", time.Time{}
+
+	if err := h.broker.Publish(c.Request.Context(), m); err != nil {
+		logging.FromContext(c.Request.Context()).Error("could not publish notification", "error", err)
+		problem.Abort(c, problem.New(http.StatusServiceUnavailable, "Could not publish notification"))
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
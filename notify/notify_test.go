@@ -0,0 +1,131 @@
+package notify_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/gatewaytest"
+	"github.com/rekib0023/event-horizon-gateway/notify"
+	pb "github.com/rekib0023/event-horizon-gateway/proto"
+)
+
+const token = "notify-secret"
+
+func withToken(cfg *config.Config) {
+	cfg.Notifications.Token = token
+}
+
+// subscribe opens an SSE stream as user and returns the messages it
+// carries.
+func subscribe(t *testing.T, srv *httptest.Server, user *pb.UserResponse, path string) <-chan notify.Message {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+path, nil)
+	req.AddCookie(&http.Cookie{Name: "token", Value: user.GetToken()})
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("subscribe: status %d", resp.StatusCode)
+	}
+
+	messages := make(chan notify.Message, 8)
+	go func() {
+		defer resp.Body.Close()
+		defer close(messages)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok {
+				continue
+			}
+			var m notify.Message
+			if json.Unmarshal([]byte(data), &m) == nil {
+				messages <- m
+			}
+		}
+	}()
+	return messages
+}
+
+func next(t *testing.T, messages <-chan notify.Message) notify.Message {
+	t.Helper()
+	select {
+	case m := <-messages:
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification arrived")
+	}
+	return notify.Message{}
+}
+
+func publish(t *testing.T, h *gatewaytest.Harness, m map[string]any) {
+	t.Helper()
+	req := h.Request(http.MethodPost, "/internal/notifications", m)
+	req.Header.Set("Authorization", "Bearer "+token)
+	h.ExpectStatus(h.Do(req), http.StatusAccepted)
+}
+
+func TestNotificationsReachAttendeesAndOwners(t *testing.T) {
+	h := gatewaytest.New(t, withToken)
+	ada, bob := h.AddUser("ada@example.com"), h.AddUser("bob@example.com")
+	h.Events.Handle(http.MethodGet, "/events/7/attendees", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"attendees":[{"id":%d}]}`, ada.Id)
+	})
+	srv := httptest.NewServer(h.Gateway)
+	t.Cleanup(srv.Close)
+
+	adaMessages := subscribe(t, srv, ada, "/api/events/7/notifications")
+	bobMessages := subscribe(t, srv, bob, "/api/notifications")
+
+	publish(t, h, map[string]any{"eventId": "7", "type": "event.updated"})
+	if m := next(t, adaMessages); m.EventID != "7" || m.Type != "event.updated" || m.ID == "" {
+		t.Fatalf("attendee got %+v", m)
+	}
+
+	// Bob neither attends nor owns event 7, so the first message he sees
+	// is the one for the event he owns.
+	publish(t, h, map[string]any{"eventId": "8", "type": "event.updated", "ownerId": fmt.Sprint(bob.Id)})
+	if m := next(t, bobMessages); m.EventID != "8" {
+		t.Fatalf("owner got %+v", m)
+	}
+}
+
+func TestInternalNotificationsEndpoint(t *testing.T) {
+	h := gatewaytest.New(t, withToken)
+
+	req := h.Request(http.MethodPost, "/internal/notifications", map[string]any{"eventId": "7", "type": "event.updated"})
+	req.Header.Set("Authorization", "Bearer wrong")
+	rec := h.Do(req)
+	h.ExpectStatus(rec, http.StatusUnauthorized)
+	if rec.Header().Get("WWW-Authenticate") != "Bearer" {
+		t.Fatalf("401 without a bearer challenge")
+	}
+
+	req = h.Request(http.MethodPost, "/internal/notifications", map[string]any{"eventId": "7", "type": "event.updated"})
+	req.Header.Set("Authorization", token)
+	h.ExpectStatus(h.Do(req), http.StatusUnauthorized)
+
+	req = h.Request(http.MethodPost, "/internal/notifications", map[string]any{"type": notify.TypeReset})
+	req.Header.Set("Authorization", "Bearer "+token)
+	h.ExpectStatus(h.Do(req), http.StatusBadRequest)
+}
+
+func TestInternalNotificationsNeedToken(t *testing.T) {
+	h := gatewaytest.New(t, nil)
+
+	req := h.Request(http.MethodPost, "/internal/notifications", map[string]any{"eventId": "7", "type": "event.updated"})
+	req.Header.Set("Authorization", "Bearer ")
+	h.ExpectStatus(h.Do(req), http.StatusNotFound)
+}
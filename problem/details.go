@@ -0,0 +1,59 @@
+package problem
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// applyDetail folds a google.rpc error detail into the problem. Details
+// without a dedicated mapping are kept verbatim under "details".
+func (p *Problem) applyDetail(m proto.Message) {
+	switch d := m.(type) {
+	case *errdetails.BadRequest:
+		for _, v := range d.GetFieldViolations() {
+			p.WithFieldError(v.GetField(), v.GetDescription())
+		}
+	case *errdetails.RetryInfo:
+		delay := d.GetRetryDelay().AsDuration()
+		seconds := int64(math.Ceil(delay.Seconds()))
+		if seconds < 0 {
+			seconds = 0
+		}
+		p.RetryAfter = seconds
+		p.header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	case *errdetails.ErrorInfo:
+		p.Reason = d.GetReason()
+		p.Domain = d.GetDomain()
+		p.Metadata = d.GetMetadata()
+	case *errdetails.QuotaFailure:
+		for _, v := range d.GetViolations() {
+			p.QuotaViolations = append(p.QuotaViolations, QuotaViolation{
+				Subject:     v.GetSubject(),
+				Description: v.GetDescription(),
+			})
+		}
+	default:
+		detail, err := anypb.New(m)
+		if err != nil {
+			return
+		}
+		raw, err := protojson.Marshal(detail)
+		if err != nil {
+			return
+		}
+		p.Details = append(p.Details, raw)
+	}
+}
+
+func (p *Problem) header() http.Header {
+	if p.Headers == nil {
+		p.Headers = http.Header{}
+	}
+	return p.Headers
+}
@@ -0,0 +1,154 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/rekib0023/event-horizon-gateway/utils"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// ContentType is the media type of RFC 7807 problem documents.
+const ContentType = "application/problem+json"
+
+const defaultType = "about:blank"
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Problem is the single error model returned by the gateway, rendered as
+// an RFC 7807 problem document.
+type Problem struct {
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	Status    int               `json:"status"`
+	Detail    string            `json:"detail,omitempty"`
+	Instance  string            `json:"instance,omitempty"`
+	RequestID string            `json:"requestId,omitempty"`
+	Errors    []FieldError      `json:"errors,omitempty"`
+	Details   []json.RawMessage `json:"details,omitempty"`
+
+	Reason          string            `json:"reason,omitempty"`
+	Domain          string            `json:"domain,omitempty"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	RetryAfter      int64             `json:"retryAfter,omitempty"`
+	QuotaViolations []QuotaViolation  `json:"quotaViolations,omitempty"`
+
+	Headers http.Header `json:"-"`
+}
+
+type QuotaViolation struct {
+	Subject     string `json:"subject"`
+	Description string `json:"description"`
+}
+
+func New(statusCode int, detail string) *Problem {
+	return &Problem{
+		Type:   defaultType,
+		Title:  http.StatusText(statusCode),
+		Status: statusCode,
+		Detail: detail,
+	}
+}
+
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	return p.Title
+}
+
+func (p *Problem) WithFieldError(field, message string) *Problem {
+	p.Errors = append(p.Errors, FieldError{Field: field, Message: message})
+	return p
+}
+
+// FromGRPC maps an error returned by the gRPC method fullMethod to a
+// problem, resolving the HTTP status through mapping and unpacking any
+// status details.
+func FromGRPC(err error, fullMethod string, mapping *utils.StatusMapping) *Problem {
+	s, ok := status.FromError(err)
+	if !ok {
+		return New(http.StatusInternalServerError, "Internal server error")
+	}
+
+	p := New(mapping.HTTPStatus(fullMethod, s.Code()), s.Message())
+	for _, d := range s.Details() {
+		m, ok := d.(proto.Message)
+		if !ok {
+			continue
+		}
+		p.applyDetail(m)
+	}
+	return p
+}
+
+// FromBindError maps a gin binding error to a 400 problem, listing the
+// failing fields when the error comes from struct validation.
+func FromBindError(err error) *Problem {
+	p := New(http.StatusBadRequest, "Invalid request")
+
+	if p := FromBodyError(err); p != nil {
+		return p
+	}
+
+	var verrs validator.ValidationErrors
+	if errors.As(err, &verrs) {
+		for _, fe := range verrs {
+			p.WithFieldError(fe.Field(), fe.Error())
+		}
+	}
+	return p
+}
+
+// FromBodyError maps failures reading the request body that are the
+// client's doing: bodies over the size limit and clients too slow to send
+// them. It returns nil for other errors.
+func FromBodyError(err error) *Problem {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return New(http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit))
+	}
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return New(http.StatusRequestTimeout, "Timed out reading the request body")
+	}
+	return nil
+}
+
+// Abort renders p as application/problem+json and stops the handler chain.
+func Abort(c *gin.Context, p *Problem) {
+	if p.Instance == "" {
+		p.Instance = c.Request.URL.Path
+	}
+	if p.RequestID == "" {
+		p.RequestID = requestID(c)
+	}
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	for key, values := range p.Headers {
+		for _, v := range values {
+			c.Writer.Header().Add(key, v)
+		}
+	}
+	c.Data(p.Status, ContentType, body)
+	c.Abort()
+}
+
+func requestID(c *gin.Context) string {
+	if id := c.GetString("requestId"); id != "" {
+		return id
+	}
+	return c.GetHeader("X-Request-ID")
+}
@@ -0,0 +1,241 @@
+package problem_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rekib0023/event-horizon-gateway/problem"
+	"github.com/rekib0023/event-horizon-gateway/utils"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func withDetails(t *testing.T, code codes.Code, msg string, details ...protoadapt.MessageV1) error {
+	t.Helper()
+	s, err := status.New(code, msg).WithDetails(details...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.Err()
+}
+
+func TestFromGRPC(t *testing.T) {
+	mapping, err := utils.NewStatusMapping(map[string]map[string]int{
+		"auth.AuthService":       {"NOT_FOUND": http.StatusUnauthorized},
+		"auth.AuthService/Login": {"NOT_FOUND": http.StatusForbidden},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		err    error
+		method string
+		check  func(t *testing.T, p *problem.Problem)
+	}{
+		{
+			name: "not a status",
+			err:  errors.New("boom"),
+			check: func(t *testing.T, p *problem.Problem) {
+				if p.Status != http.StatusInternalServerError || p.Detail != "Internal server error" {
+					t.Fatalf("problem = %+v", p)
+				}
+			},
+		},
+		{
+			name: "default mapping",
+			err:  status.Error(codes.InvalidArgument, "bad email"),
+			check: func(t *testing.T, p *problem.Problem) {
+				if p.Status != http.StatusBadRequest || p.Title != "Bad Request" || p.Detail != "bad email" || p.Type != "about:blank" {
+					t.Fatalf("problem = %+v", p)
+				}
+			},
+		},
+		{
+			name:   "method override",
+			err:    status.Error(codes.NotFound, "no such user"),
+			method: "/auth.AuthService/Login",
+			check: func(t *testing.T, p *problem.Problem) {
+				if p.Status != http.StatusForbidden {
+					t.Fatalf("status = %d, want 403", p.Status)
+				}
+			},
+		},
+		{
+			name:   "service override",
+			err:    status.Error(codes.NotFound, "no such user"),
+			method: "/auth.AuthService/GetUser",
+			check: func(t *testing.T, p *problem.Problem) {
+				if p.Status != http.StatusUnauthorized {
+					t.Fatalf("status = %d, want 401", p.Status)
+				}
+			},
+		},
+		{
+			name: "bad request",
+			err: withDetails(t, codes.InvalidArgument, "invalid", &errdetails.BadRequest{
+				FieldViolations: []*errdetails.BadRequest_FieldViolation{
+					{Field: "email", Description: "must be an email"},
+					{Field: "password", Description: "too short"},
+				},
+			}),
+			check: func(t *testing.T, p *problem.Problem) {
+				if len(p.Errors) != 2 || p.Errors[0].Field != "email" || p.Errors[1].Message != "too short" {
+					t.Fatalf("errors = %+v", p.Errors)
+				}
+			},
+		},
+		{
+			name: "retry info",
+			err: withDetails(t, codes.ResourceExhausted, "slow down", &errdetails.RetryInfo{
+				RetryDelay: durationpb.New(1500 * time.Millisecond),
+			}),
+			check: func(t *testing.T, p *problem.Problem) {
+				if p.Status != http.StatusTooManyRequests || p.RetryAfter != 2 || p.Headers.Get("Retry-After") != "2" {
+					t.Fatalf("problem = %+v, headers %v", p, p.Headers)
+				}
+			},
+		},
+		{
+			name: "negative retry delay",
+			err: withDetails(t, codes.Unavailable, "down", &errdetails.RetryInfo{
+				RetryDelay: durationpb.New(-time.Second),
+			}),
+			check: func(t *testing.T, p *problem.Problem) {
+				if p.RetryAfter != 0 || p.Headers.Get("Retry-After") != "0" {
+					t.Fatalf("problem = %+v, headers %v", p, p.Headers)
+				}
+			},
+		},
+		{
+			name: "error info",
+			err: withDetails(t, codes.PermissionDenied, "denied", &errdetails.ErrorInfo{
+				Reason:   "ACCOUNT_LOCKED",
+				Domain:   "auth.example.com",
+				Metadata: map[string]string{"until": "tomorrow"},
+			}),
+			check: func(t *testing.T, p *problem.Problem) {
+				if p.Reason != "ACCOUNT_LOCKED" || p.Domain != "auth.example.com" || p.Metadata["until"] != "tomorrow" {
+					t.Fatalf("problem = %+v", p)
+				}
+			},
+		},
+		{
+			name: "quota failure",
+			err: withDetails(t, codes.ResourceExhausted, "quota", &errdetails.QuotaFailure{
+				Violations: []*errdetails.QuotaFailure_Violation{{Subject: "user:1", Description: "daily limit"}},
+			}),
+			check: func(t *testing.T, p *problem.Problem) {
+				if len(p.QuotaViolations) != 1 || p.QuotaViolations[0].Subject != "user:1" {
+					t.Fatalf("quota violations = %+v", p.QuotaViolations)
+				}
+			},
+		},
+		{
+			name: "unmapped detail",
+			err:  withDetails(t, codes.Internal, "oops", &errdetails.DebugInfo{Detail: "stack"}),
+			check: func(t *testing.T, p *problem.Problem) {
+				if len(p.Details) != 1 || !strings.Contains(string(p.Details[0]), "google.rpc.DebugInfo") {
+					t.Fatalf("details = %s", p.Details)
+				}
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.check(t, problem.FromGRPC(tc.err, tc.method, mapping))
+		})
+	}
+}
+
+func upstream(code int, contentType, body string, header http.Header) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	return &http.Response{StatusCode: code, Header: header, Body: io.NopCloser(strings.NewReader(body))}
+}
+
+func TestFromUpstream(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		resp  *http.Response
+		check func(t *testing.T, p *problem.Problem)
+	}{
+		{
+			name: "validator errors",
+			resp: upstream(http.StatusUnprocessableEntity, "application/json",
+				`{"errors":[{"msg":"required","param":"name"},{"message":"too long","path":"title"},{"message":"general"}]}`, nil),
+			check: func(t *testing.T, p *problem.Problem) {
+				if p.Status != http.StatusUnprocessableEntity || p.Detail != "general" {
+					t.Fatalf("problem = %+v", p)
+				}
+				if len(p.Errors) != 2 || p.Errors[0].Field != "name" || p.Errors[0].Message != "required" || p.Errors[1].Field != "title" {
+					t.Fatalf("errors = %+v", p.Errors)
+				}
+			},
+		},
+		{
+			name: "error body",
+			resp: upstream(http.StatusNotFound, "application/json", `{"error":"Event not found"}`, nil),
+			check: func(t *testing.T, p *problem.Problem) {
+				if p.Status != http.StatusNotFound || p.Detail != "Event not found" {
+					t.Fatalf("problem = %+v", p)
+				}
+			},
+		},
+		{
+			name: "message body",
+			resp: upstream(http.StatusConflict, "application/json", `{"message":"Already booked"}`, nil),
+			check: func(t *testing.T, p *problem.Problem) {
+				if p.Detail != "Already booked" {
+					t.Fatalf("problem = %+v", p)
+				}
+			},
+		},
+		{
+			name: "not json",
+			resp: upstream(http.StatusBadGateway, "text/html", `<h1>Bad gateway</h1>`, nil),
+			check: func(t *testing.T, p *problem.Problem) {
+				if p.Status != http.StatusBadGateway || p.Detail != "" || p.Title != "Bad Gateway" {
+					t.Fatalf("problem = %+v", p)
+				}
+			},
+		},
+		{
+			name: "retry after",
+			resp: upstream(http.StatusServiceUnavailable, "", ``, http.Header{"Retry-After": {"30"}}),
+			check: func(t *testing.T, p *problem.Problem) {
+				if p.Headers.Get("Retry-After") != "30" {
+					t.Fatalf("headers = %v", p.Headers)
+				}
+			},
+		},
+		{
+			name: "problem passthrough",
+			resp: upstream(http.StatusForbidden, "application/problem+json; charset=utf-8",
+				`{"title":"Not yours","status":400,"detail":"owned by someone else","instance":"/events/1","requestId":"upstream","reason":"OWNER"}`, nil),
+			check: func(t *testing.T, p *problem.Problem) {
+				if p.Status != http.StatusForbidden || p.Type != "about:blank" || p.Title != "Not yours" || p.Reason != "OWNER" {
+					t.Fatalf("problem = %+v", p)
+				}
+				if p.Instance != "" || p.RequestID != "" {
+					t.Fatalf("gateway-owned fields kept: %+v", p)
+				}
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.check(t, problem.FromUpstream(tc.resp))
+		})
+	}
+}
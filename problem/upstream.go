@@ -0,0 +1,89 @@
+package problem
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const maxUpstreamErrorBody = 1 << 20
+
+// upstreamError covers the error shapes produced by the event service:
+// express-validator style `errors[]` lists as well as `{"error": "..."}`
+// and `{"message": "..."}` bodies.
+type upstreamError struct {
+	Errors []struct {
+		Message string `json:"message"`
+		Msg     string `json:"msg"`
+		Field   string `json:"field"`
+		Param   string `json:"param"`
+		Path    string `json:"path"`
+	} `json:"errors"`
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+// FromUpstream normalizes an HTTP error response from an upstream service
+// into a problem with the upstream status code. Upstream problem documents
+// are passed through unchanged apart from gateway-owned fields.
+func FromUpstream(resp *http.Response) *Problem {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxUpstreamErrorBody))
+	if err != nil {
+		return New(http.StatusBadGateway, "Failed to read upstream error response")
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == ContentType {
+		var p Problem
+		if err := json.Unmarshal(body, &p); err == nil {
+			if p.Type == "" {
+				p.Type = defaultType
+			}
+			p.Status = resp.StatusCode
+			p.Instance = ""
+			p.RequestID = ""
+			return &p
+		}
+	}
+
+	p := New(resp.StatusCode, "")
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		p.header().Set("Retry-After", retryAfter)
+	}
+
+	var errResp upstreamError
+	if err := json.Unmarshal(body, &errResp); err != nil {
+		return p
+	}
+
+	var msgs []string
+	for _, e := range errResp.Errors {
+		msg := e.Message
+		if msg == "" {
+			msg = e.Msg
+		}
+		field := firstNonEmpty(e.Field, e.Param, e.Path)
+		if field != "" {
+			p.WithFieldError(field, msg)
+		} else if msg != "" {
+			msgs = append(msgs, msg)
+		}
+	}
+	if len(msgs) > 0 {
+		p.Detail = strings.Join(msgs, ", ")
+	} else {
+		p.Detail = firstNonEmpty(errResp.Error, errResp.Message)
+	}
+	return p
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
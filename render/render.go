@@ -0,0 +1,175 @@
+package render
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/logging"
+	"github.com/rekib0023/event-horizon-gateway/problem"
+	"github.com/rekib0023/event-horizon-gateway/shaping"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	MIMEJSON     = "application/json"
+	MIMEProtobuf = "application/x-protobuf"
+)
+
+// Renderer writes protobuf responses with protojson, or in the binary wire
+// format when the client asks for application/x-protobuf.
+type Renderer struct {
+	marshal protojson.MarshalOptions
+}
+
+func New(opts config.RenderOptions) *Renderer {
+	return &Renderer{
+		marshal: protojson.MarshalOptions{
+			UseProtoNames:   opts.UseProtoNames,
+			EmitUnpopulated: opts.EmitUnpopulated,
+		},
+	}
+}
+
+// IsProtobuf reports whether a request or response media type denotes the
+// protobuf wire format.
+func IsProtobuf(mediaType string) bool {
+	return mediaType == MIMEProtobuf || mediaType == "application/protobuf"
+}
+
+// Negotiate returns the media type Message renders for the request's
+// Accept header: MIMEProtobuf or MIMEJSON.
+func Negotiate(c *gin.Context) string {
+	if IsProtobuf(c.NegotiateFormat(MIMEJSON, MIMEProtobuf, "application/protobuf")) {
+		return MIMEProtobuf
+	}
+	return MIMEJSON
+}
+
+// Message renders msg in the format negotiated from the Accept header.
+func (r *Renderer) Message(c *gin.Context, statusCode int, msg proto.Message) {
+	if !bodyAllowed(statusCode) {
+		c.Status(statusCode)
+		return
+	}
+
+	shaper := shaping.FromContext(c)
+
+	c.Writer.Header().Add("Vary", "Accept")
+	if Negotiate(c) == MIMEProtobuf {
+		if shaper.Active() {
+			shaped, err := r.shapeMessage(shaper, msg)
+			if err != nil {
+				r.fail(c, err)
+				return
+			}
+			msg = shaped
+		}
+		data, err := proto.Marshal(msg)
+		if err != nil {
+			r.fail(c, err)
+			return
+		}
+		c.Data(statusCode, MIMEProtobuf, data)
+		return
+	}
+
+	data, err := r.marshal.Marshal(msg)
+	if err == nil {
+		data, err = shaper.ShapeJSON(data)
+	}
+	if err != nil {
+		r.fail(c, err)
+		return
+	}
+	c.Data(statusCode, MIMEJSON+"; charset=utf-8", data)
+}
+
+// shapeMessage applies the shaper through the message's JSON form so that
+// redaction rules hold for binary responses too.
+func (r *Renderer) shapeMessage(shaper *shaping.Shaper, msg proto.Message) (proto.Message, error) {
+	data, err := r.marshal.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = shaper.ShapeJSON(data); err != nil {
+		return nil, err
+	}
+	shaped := msg.ProtoReflect().New().Interface()
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, shaped); err != nil {
+		return nil, err
+	}
+	return shaped, nil
+}
+
+// Value renders a JSON value that may contain protobuf messages, such as a
+// repeated field selected as the response body. Such values have no
+// protobuf wire representation and are always rendered as JSON.
+func (r *Renderer) Value(c *gin.Context, statusCode int, v interface{}) {
+	if msg, ok := v.(proto.Message); ok {
+		r.Message(c, statusCode, msg)
+		return
+	}
+	if !bodyAllowed(statusCode) {
+		c.Status(statusCode)
+		return
+	}
+
+	converted, err := r.convert(v)
+	if err != nil {
+		r.fail(c, err)
+		return
+	}
+	data, err := json.Marshal(converted)
+	if err == nil {
+		data, err = shaping.FromContext(c).ShapeJSON(data)
+	}
+	if err != nil {
+		r.fail(c, err)
+		return
+	}
+	c.Data(statusCode, MIMEJSON+"; charset=utf-8", data)
+}
+
+func (r *Renderer) convert(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case proto.Message:
+		data, err := r.marshal.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return json.RawMessage(data), nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			converted, err := r.convert(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = converted
+		}
+		return out, nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			converted, err := r.convert(item)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = converted
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+func (r *Renderer) fail(c *gin.Context, err error) {
+	logging.FromContext(c.Request.Context()).Error("could not render response", "error", err)
+	problem.Abort(c, problem.New(http.StatusInternalServerError, "Internal server error"))
+}
+
+func bodyAllowed(statusCode int) bool {
+	return statusCode >= 200 && statusCode != http.StatusNoContent && statusCode != http.StatusNotModified
+}
@@ -0,0 +1,118 @@
+package render_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	pb "github.com/rekib0023/event-horizon-gateway/proto"
+	"github.com/rekib0023/event-horizon-gateway/render"
+	"github.com/rekib0023/event-horizon-gateway/shaping"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var user = &pb.UserResponse{
+	Id:        1,
+	UserName:  "ada",
+	Email:     "ada@example.com",
+	CreatedAt: timestamppb.New(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+}
+
+func serve(t *testing.T, opts config.RenderOptions, target, accept string, v interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(shaping.Middleware(map[string]config.ShapingRoute{
+		"GET /user": {Rules: []config.ShapingRule{{Path: "email", VisibleTo: []string{shaping.RoleAdmin}}}},
+	}, nil))
+	r := render.New(opts)
+	e.GET("/user", func(c *gin.Context) { r.Value(c, http.StatusOK, v) })
+	e.GET("/other", func(c *gin.Context) { r.Value(c, http.StatusOK, v) })
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	return rec
+}
+
+func TestNegotiation(t *testing.T) {
+	for _, tc := range []struct {
+		accept string
+		want   string
+	}{
+		{"", render.MIMEJSON + "; charset=utf-8"},
+		{"application/json", render.MIMEJSON + "; charset=utf-8"},
+		{"*/*", render.MIMEJSON + "; charset=utf-8"},
+		{"application/x-protobuf", render.MIMEProtobuf},
+		{"application/protobuf", render.MIMEProtobuf},
+		{"application/x-protobuf, application/json", render.MIMEProtobuf},
+		{"text/html, application/json", render.MIMEJSON + "; charset=utf-8"},
+	} {
+		rec := serve(t, config.RenderOptions{}, "/other", tc.accept, user)
+		if got := rec.Header().Get("Content-Type"); got != tc.want {
+			t.Errorf("Accept %q: Content-Type = %q, want %q", tc.accept, got, tc.want)
+			continue
+		}
+		if got := rec.Header().Get("Vary"); got != "Accept" {
+			t.Errorf("Accept %q: Vary = %q", tc.accept, got)
+		}
+		if tc.want != render.MIMEProtobuf {
+			continue
+		}
+		var decoded pb.UserResponse
+		if err := proto.Unmarshal(rec.Body.Bytes(), &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !proto.Equal(&decoded, user) {
+			t.Errorf("Accept %q: decoded %v", tc.accept, &decoded)
+		}
+	}
+}
+
+func TestProtojson(t *testing.T) {
+	rec := serve(t, config.RenderOptions{}, "/other", "", user)
+	body := rec.Body.String()
+	if !strings.Contains(body, `"createdAt":"2024-01-02T03:04:05Z"`) || !strings.Contains(body, `"userName":"ada"`) {
+		t.Fatalf("body = %s", body)
+	}
+	if strings.Contains(body, "firstName") {
+		t.Fatalf("body = %s, want unpopulated fields omitted", body)
+	}
+
+	rec = serve(t, config.RenderOptions{EmitUnpopulated: true}, "/other", "", user)
+	if !strings.Contains(rec.Body.String(), `"firstName":""`) {
+		t.Fatalf("body = %s, want unpopulated fields", rec.Body)
+	}
+}
+
+func TestShapedProtobuf(t *testing.T) {
+	rec := serve(t, config.RenderOptions{}, "/user?fields=id,email", "application/x-protobuf", user)
+	var decoded pb.UserResponse
+	if err := proto.Unmarshal(rec.Body.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Id != 1 || decoded.Email != "" || decoded.UserName != "" {
+		t.Fatalf("decoded %v, want only the id", &decoded)
+	}
+}
+
+func TestValue(t *testing.T) {
+	rec := serve(t, config.RenderOptions{}, "/user?fields=id", "application/x-protobuf", []interface{}{user})
+	if got := rec.Header().Get("Content-Type"); got != render.MIMEJSON+"; charset=utf-8" {
+		t.Fatalf("Content-Type = %q, want JSON for non-message values", got)
+	}
+	if body := rec.Body.String(); body != `[{"id":1}]` {
+		t.Fatalf("body = %s", body)
+	}
+}
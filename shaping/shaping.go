@@ -0,0 +1,346 @@
+package shaping
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/problem"
+	pb "github.com/rekib0023/event-horizon-gateway/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const contextKey = "shaper"
+
+// Roles understood in Rule.VisibleTo.
+const (
+	RoleSelf  = "self"
+	RoleAdmin = "admin"
+)
+
+// Shaper applies a route's redaction rules and the caller's sparse fieldset
+// to a JSON response document.
+type Shaper struct {
+	c          *gin.Context
+	route      config.ShapingRoute
+	routes     map[string]config.ShapingRoute
+	fields     [][]string
+	admins     map[string]bool
+	collection []string
+}
+
+// Middleware attaches a Shaper to every request. Rules are looked up by
+// "METHOD /full/route/template"; the ?fields= parameter applies to any
+// route. The caller is resolved lazily so that the middleware can run
+// before per-route authentication.
+func Middleware(routes map[string]config.ShapingRoute, adminUsers []string) gin.HandlerFunc {
+	admins := map[string]bool{}
+	for _, a := range adminUsers {
+		admins[strings.ToLower(strings.TrimSpace(a))] = true
+	}
+
+	return func(c *gin.Context) {
+		route := routes[c.Request.Method+" "+c.FullPath()]
+		s := &Shaper{c: c, route: route, routes: routes, admins: admins, collection: splitPath(route.Collection)}
+		if fields := c.Query("fields"); fields != "" {
+			for _, f := range strings.Split(fields, ",") {
+				if f = strings.TrimSpace(f); f != "" {
+					s.fields = append(s.fields, strings.Split(f, "."))
+				}
+			}
+		}
+		c.Set(contextKey, s)
+		c.Next()
+	}
+}
+
+// FromContext returns the request's Shaper, or nil if none is attached.
+func FromContext(c *gin.Context) *Shaper {
+	if v, ok := c.Get(contextKey); ok {
+		if s, ok := v.(*Shaper); ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// Route returns a Shaper for the same caller with the rules of route,
+// keyed like the routes given to Middleware, and no sparse fieldset. It
+// shapes documents a handler serves on behalf of another route, so that
+// they are redacted as that route would redact them.
+func (s *Shaper) Route(route string) *Shaper {
+	if s == nil {
+		return nil
+	}
+	r := s.routes[route]
+	return &Shaper{c: s.c, route: r, routes: s.routes, admins: s.admins, collection: splitPath(r.Collection)}
+}
+
+// Active reports whether Apply would change anything.
+func (s *Shaper) Active() bool {
+	return s != nil && (len(s.fields) > 0 || len(s.route.Rules) > 0)
+}
+
+// Variant identifies the view of a document the shaper produces: the
+// sparse fieldset and, when the route has redaction rules, the caller.
+// Equal variants shape a document the same way.
+func (s *Shaper) Variant() string {
+	if !s.Active() {
+		return ""
+	}
+	fields := make([]string, len(s.fields))
+	for i, f := range s.fields {
+		fields[i] = strings.Join(f, ".")
+	}
+	sort.Strings(fields)
+	variant := strings.Join(fields, ",")
+	if len(s.route.Rules) > 0 {
+		switch user := s.viewer(); {
+		case s.isAdmin(user):
+			variant += "|admin"
+		case user != nil:
+			variant += "|user:" + user.GetId()
+		}
+	}
+	return variant
+}
+
+// CheckFields rejects a ?fields= entry that names no field of the
+// resources under the route's collection path in documents of type md,
+// by proto or JSON name. Documents without a known type, such as proxied
+// event-service JSON, are not checked.
+func (s *Shaper) CheckFields(md protoreflect.MessageDescriptor) *problem.Problem {
+	if s == nil || md == nil || len(s.fields) == 0 {
+		return nil
+	}
+	for _, name := range s.collection {
+		fd := fieldByName(md, name)
+		if fd == nil || fd.Kind() != protoreflect.MessageKind || fd.IsMap() {
+			return nil
+		}
+		md = fd.Message()
+	}
+
+	for _, f := range s.fields {
+		if !hasField(md, f) {
+			return problem.New(http.StatusBadRequest, "Invalid fields parameter").
+				WithFieldError("fields", fmt.Sprintf("unknown field %q", strings.Join(f, ".")))
+		}
+	}
+	return nil
+}
+
+func hasField(md protoreflect.MessageDescriptor, path []string) bool {
+	for i, name := range path {
+		fd := fieldByName(md, name)
+		if fd == nil {
+			return false
+		}
+		if i == len(path)-1 || fd.IsMap() {
+			return true
+		}
+		if fd.Kind() != protoreflect.MessageKind {
+			return false
+		}
+		md = fd.Message()
+		if md.FullName() == "google.protobuf.Struct" {
+			return true
+		}
+		// Other well-known types render as JSON scalars.
+		if md.ParentFile().Package() == "google.protobuf" {
+			return false
+		}
+	}
+	return true
+}
+
+func fieldByName(md protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	if fd := md.Fields().ByName(protoreflect.Name(name)); fd != nil {
+		return fd
+	}
+	return md.Fields().ByJSONName(name)
+}
+
+// ShapeJSON decodes data, applies the shaper and re-encodes the result.
+func (s *Shaper) ShapeJSON(data []byte) ([]byte, error) {
+	if !s.Active() {
+		return data, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(s.Apply(doc))
+}
+
+// Apply redacts fields the caller may not see and then projects the
+// resources under the route's collection path onto the requested fields.
+func (s *Shaper) Apply(doc interface{}) interface{} {
+	if !s.Active() {
+		return doc
+	}
+
+	for _, rule := range s.route.Rules {
+		if s.allowedEverywhere(rule) {
+			continue
+		}
+		ownerField := rule.OwnerField
+		if ownerField == "" {
+			ownerField = "id"
+		}
+		redact(doc, splitPath(rule.Path), func(parent map[string]interface{}) bool {
+			return s.visible(rule, parent[ownerField])
+		})
+	}
+
+	if len(s.fields) > 0 {
+		return replaceAt(doc, s.collection, func(resource interface{}) interface{} {
+			if items, ok := resource.([]interface{}); ok {
+				for i, item := range items {
+					items[i] = project(item, s.fields)
+				}
+				return items
+			}
+			return project(resource, s.fields)
+		})
+	}
+	return doc
+}
+
+// Visible reports whether the caller may see a field governed by rule on
+// a resource owned by owner.
+func (s *Shaper) Visible(rule config.ShapingRule, owner interface{}) bool {
+	return s != nil && (s.allowedEverywhere(rule) || s.visible(rule, owner))
+}
+
+func (s *Shaper) viewer() *pb.TokenVerification {
+	if v, ok := s.c.Get("user"); ok {
+		if user, ok := v.(*pb.TokenVerification); ok {
+			return user
+		}
+	}
+	return nil
+}
+
+func (s *Shaper) isAdmin(user *pb.TokenVerification) bool {
+	return user != nil && (s.admins[strings.ToLower(user.GetId())] || s.admins[strings.ToLower(user.GetEmail())])
+}
+
+func (s *Shaper) allowedEverywhere(rule config.ShapingRule) bool {
+	for _, role := range rule.VisibleTo {
+		if role == RoleAdmin && s.isAdmin(s.viewer()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Shaper) visible(rule config.ShapingRule, owner interface{}) bool {
+	user := s.viewer()
+	if user == nil {
+		return false
+	}
+	for _, role := range rule.VisibleTo {
+		if role == RoleSelf && owner != nil && fmt.Sprint(owner) == user.GetId() {
+			return true
+		}
+	}
+	return false
+}
+
+func splitPath(path string) []string {
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+// redact deletes the field at path from every object it matches unless
+// keep approves of the object holding it. "*" matches every element of an
+// array or every value of an object.
+func redact(node interface{}, path []string, keep func(parent map[string]interface{}) bool) {
+	if len(path) == 0 {
+		return
+	}
+	switch n := node.(type) {
+	case []interface{}:
+		if path[0] == "*" {
+			for _, item := range n {
+				redact(item, path[1:], keep)
+			}
+		}
+	case map[string]interface{}:
+		if path[0] == "*" {
+			for _, v := range n {
+				redact(v, path[1:], keep)
+			}
+			return
+		}
+		if len(path) == 1 {
+			if _, ok := n[path[0]]; ok && !keep(n) {
+				delete(n, path[0])
+			}
+			return
+		}
+		redact(n[path[0]], path[1:], keep)
+	}
+}
+
+func replaceAt(node interface{}, path []string, fn func(interface{}) interface{}) interface{} {
+	if len(path) == 0 {
+		return fn(node)
+	}
+	if m, ok := node.(map[string]interface{}); ok {
+		if v, ok := m[path[0]]; ok {
+			m[path[0]] = replaceAt(v, path[1:], fn)
+		}
+	}
+	return node
+}
+
+func project(node interface{}, fields [][]string) interface{} {
+	m, ok := node.(map[string]interface{})
+	if !ok {
+		return node
+	}
+
+	out := map[string]interface{}{}
+	whole := map[string]bool{}
+	nested := map[string][][]string{}
+	for _, f := range fields {
+		if len(f) == 1 {
+			whole[f[0]] = true
+		} else {
+			nested[f[0]] = append(nested[f[0]], f[1:])
+		}
+	}
+	for name := range whole {
+		if v, ok := m[name]; ok {
+			out[name] = v
+		}
+	}
+	for name, sub := range nested {
+		v, ok := m[name]
+		if !ok || whole[name] {
+			continue
+		}
+		switch v := v.(type) {
+		case []interface{}:
+			items := make([]interface{}, len(v))
+			for i, item := range v {
+				items[i] = project(item, sub)
+			}
+			out[name] = items
+		default:
+			out[name] = project(v, sub)
+		}
+	}
+	return out
+}
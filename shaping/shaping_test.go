@@ -0,0 +1,133 @@
+package shaping_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	pb "github.com/rekib0023/event-horizon-gateway/proto"
+	"github.com/rekib0023/event-horizon-gateway/shaping"
+)
+
+var routes = map[string]config.ShapingRoute{
+	"GET /users": {
+		Collection: "users",
+		Rules:      []config.ShapingRule{{Path: "users.*.email", VisibleTo: []string{shaping.RoleSelf, shaping.RoleAdmin}}},
+	},
+}
+
+// serve runs fn behind the shaping middleware as the given caller.
+func serve(t *testing.T, target string, caller *pb.TokenVerification, fn func(c *gin.Context, s *shaping.Shaper)) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(func(c *gin.Context) {
+		if caller != nil {
+			c.Set("user", caller)
+		}
+	})
+	e.Use(shaping.Middleware(routes, []string{" Admin@Example.com "}))
+	called := false
+	e.GET("/users", func(c *gin.Context) {
+		called = true
+		fn(c, shaping.FromContext(c))
+	})
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	if !called {
+		t.Fatal("handler not called")
+	}
+}
+
+func userList() map[string]interface{} {
+	return map[string]interface{}{
+		"users": []interface{}{
+			map[string]interface{}{"id": 1, "userName": "ada", "email": "ada@example.com"},
+			map[string]interface{}{"id": 2, "userName": "bob", "email": "bob@example.com"},
+		},
+	}
+}
+
+func emails(doc interface{}) []interface{} {
+	var out []interface{}
+	for _, u := range doc.(map[string]interface{})["users"].([]interface{}) {
+		out = append(out, u.(map[string]interface{})["email"])
+	}
+	return out
+}
+
+func TestRedaction(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		caller *pb.TokenVerification
+		want   []interface{}
+	}{
+		{"anonymous", nil, []interface{}{nil, nil}},
+		{"self", &pb.TokenVerification{Id: "2", Email: "bob@example.com"}, []interface{}{nil, "bob@example.com"}},
+		{"admin by email", &pb.TokenVerification{Id: "9", Email: "admin@example.com"}, []interface{}{"ada@example.com", "bob@example.com"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			serve(t, "/users", tc.caller, func(c *gin.Context, s *shaping.Shaper) {
+				got := emails(s.Apply(userList()))
+				if len(got) != 2 || got[0] != tc.want[0] || got[1] != tc.want[1] {
+					t.Fatalf("emails = %v, want %v", got, tc.want)
+				}
+			})
+		})
+	}
+}
+
+func TestFields(t *testing.T) {
+	admin := &pb.TokenVerification{Id: "9", Email: "admin@example.com"}
+	serve(t, "/users?fields=id,%20email", admin, func(c *gin.Context, s *shaping.Shaper) {
+		doc := s.Apply(userList()).(map[string]interface{})
+		first := doc["users"].([]interface{})[0].(map[string]interface{})
+		if len(first) != 2 || first["id"] != 1 || first["email"] != "ada@example.com" {
+			t.Fatalf("projected user = %v", first)
+		}
+	})
+
+	serve(t, "/users?fields=email", &pb.TokenVerification{Id: "1"}, func(c *gin.Context, s *shaping.Shaper) {
+		got := emails(s.Apply(userList()))
+		if got[0] != "ada@example.com" || got[1] != nil {
+			t.Fatalf("emails = %v, want only the caller's", got)
+		}
+	})
+}
+
+func TestCheckFields(t *testing.T) {
+	md := (&pb.UserListResponse{}).ProtoReflect().Descriptor()
+	for _, tc := range []struct {
+		query string
+		ok    bool
+	}{
+		{"", true},
+		{"id,userName", true},
+		{"createdAt", true},
+		{"createdAt.seconds", false},
+		{"nope", false},
+		{"id,users", false},
+		{"userName.first", false},
+	} {
+		serve(t, "/users?fields="+tc.query, nil, func(c *gin.Context, s *shaping.Shaper) {
+			p := s.CheckFields(md)
+			if tc.ok != (p == nil) {
+				t.Fatalf("fields=%s: CheckFields = %v", tc.query, p)
+			}
+			if p != nil && (p.Status != http.StatusBadRequest || len(p.Errors) != 1 || p.Errors[0].Field != "fields") {
+				t.Fatalf("fields=%s: problem = %+v", tc.query, p)
+			}
+		})
+	}
+
+	var none *shaping.Shaper
+	if p := none.CheckFields(md); p != nil {
+		t.Fatalf("nil shaper: %v", p)
+	}
+	serve(t, "/users?fields=anything", nil, func(c *gin.Context, s *shaping.Shaper) {
+		if p := s.CheckFields(nil); p != nil {
+			t.Fatalf("untyped document: %v", p)
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package tracing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rekib0023/event-horizon-gateway/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+func record(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return rec
+}
+
+func attr(span sdktrace.ReadOnlySpan, key string) string {
+	for _, kv := range span.Attributes() {
+		if string(kv.Key) == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestTransportStripsQuery(t *testing.T) {
+	rec := record(t)
+	var traceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: tracing.Transport(nil)}
+	target := strings.Replace(upstream.URL, "://", "://ada:hunter2@", 1)
+	req, _ := http.NewRequest(http.MethodGet, target+"/events?token=secret&email=ada@example.com#frag", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	spans := rec.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("%d spans, want 1", len(spans))
+	}
+	if got, want := attr(spans[0], string(semconv.HTTPURLKey)), upstream.URL+"/events"; got != want {
+		t.Fatalf("http.url = %q, want %q", got, want)
+	}
+	if got := attr(spans[0], string(semconv.HTTPStatusCodeKey)); got != "502" {
+		t.Fatalf("status attribute = %q", got)
+	}
+	if traceparent == "" || spans[0].SpanContext().TraceID().String() != traceparent[3:35] {
+		t.Fatalf("traceparent = %q, span %s", traceparent, spans[0].SpanContext().TraceID())
+	}
+	if req.URL.RawQuery == "" {
+		t.Fatal("request URL was modified")
+	}
+}
+
+func TestMiddlewareContinuesTrace(t *testing.T) {
+	rec := record(t)
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(tracing.Middleware())
+	e.GET("/events/:eventId", func(c *gin.Context) { c.Status(http.StatusInternalServerError) })
+
+	req := httptest.NewRequest(http.MethodGet, "/events/7?token=secret", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := rec.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("%d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /events/:eventId" || span.Parent().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("span %q with parent %s", span.Name(), span.Parent().TraceID())
+	}
+	if got := attr(span, string(semconv.HTTPTargetKey)); got != "/events/7" {
+		t.Fatalf("http.target = %q", got)
+	}
+	if span.Status().Code.String() != "Error" {
+		t.Fatalf("status = %v, want Error", span.Status())
+	}
+}
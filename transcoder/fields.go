@@ -0,0 +1,206 @@
+package transcoder
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// lookupPath resolves a dotted field path such as "userId.id". Each segment
+// may use the proto or JSON field name.
+func lookupPath(md protoreflect.MessageDescriptor, path string) (protoreflect.FieldDescriptor, error) {
+	segments := strings.Split(path, ".")
+	var fd protoreflect.FieldDescriptor
+	for i, name := range segments {
+		if md == nil {
+			return nil, fmt.Errorf("field %q is not a message", strings.Join(segments[:i], "."))
+		}
+		fd = md.Fields().ByName(protoreflect.Name(name))
+		if fd == nil {
+			fd = md.Fields().ByJSONName(name)
+		}
+		if fd == nil {
+			return nil, fmt.Errorf("%s has no field %q", md.FullName(), name)
+		}
+		md = nil
+		if fd.Kind() == protoreflect.MessageKind && !fd.IsList() && !fd.IsMap() {
+			md = fd.Message()
+		}
+	}
+	return fd, nil
+}
+
+// underPath reports whether path names the field at prefix or one inside
+// it, whichever mix of proto and JSON names either uses.
+func underPath(md protoreflect.MessageDescriptor, path, prefix string) bool {
+	segments := strings.Split(path, ".")
+	n := len(strings.Split(prefix, "."))
+	if len(segments) < n {
+		return false
+	}
+	fd, err := lookupPath(md, strings.Join(segments[:n], "."))
+	want, _ := lookupPath(md, prefix)
+	return err == nil && fd == want
+}
+
+// mutableMessage returns the message holding the last segment of path,
+// allocating intermediate messages as needed.
+func mutableMessage(msg protoreflect.Message, path string) protoreflect.Message {
+	segments := strings.Split(path, ".")
+	for _, name := range segments[:len(segments)-1] {
+		fd, _ := lookupPath(msg.Descriptor(), name)
+		msg = msg.Mutable(fd).Message()
+	}
+	return msg
+}
+
+func setField(msg protoreflect.Message, path string, values []string) error {
+	fd, err := lookupPath(msg.Descriptor(), path)
+	if err != nil {
+		return err
+	}
+	parent := mutableMessage(msg, path)
+
+	if fd.IsMap() {
+		return fmt.Errorf("map fields cannot be bound from the URL")
+	}
+	if fd.IsList() {
+		list := parent.Mutable(fd).List()
+		for _, s := range values {
+			v, err := parseValue(fd, list.NewElement, s)
+			if err != nil {
+				return err
+			}
+			list.Append(v)
+		}
+		return nil
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+	v, err := parseValue(fd, func() protoreflect.Value { return parent.NewField(fd) }, values[len(values)-1])
+	if err != nil {
+		return err
+	}
+	parent.Set(fd, v)
+	return nil
+}
+
+func parseValue(fd protoreflect.FieldDescriptor, newValue func() protoreflect.Value, s string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("must be a boolean")
+		}
+		return protoreflect.ValueOfBool(b), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("must be an integer")
+		}
+		return protoreflect.ValueOfInt32(int32(n)), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("must be an integer")
+		}
+		return protoreflect.ValueOfInt64(n), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("must be a non-negative integer")
+		}
+		return protoreflect.ValueOfUint32(uint32(n)), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("must be a non-negative integer")
+		}
+		return protoreflect.ValueOfUint64(n), nil
+	case protoreflect.FloatKind:
+		f, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("must be a number")
+		}
+		return protoreflect.ValueOfFloat32(float32(f)), nil
+	case protoreflect.DoubleKind:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("must be a number")
+		}
+		return protoreflect.ValueOfFloat64(f), nil
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(s), nil
+	case protoreflect.BytesKind:
+		b, err := base64.URLEncoding.DecodeString(s)
+		if err != nil {
+			b, err = base64.StdEncoding.DecodeString(s)
+		}
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("must be base64 encoded")
+		}
+		return protoreflect.ValueOfBytes(b), nil
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(s)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		n, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("must be one of the %s values", fd.Enum().FullName())
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), nil
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		// Well-known types such as Timestamp have a JSON string form.
+		v := newValue()
+		if err := protojson.Unmarshal([]byte(strconv.Quote(s)), v.Message().Interface()); err != nil {
+			return protoreflect.Value{}, fmt.Errorf("invalid %s", fd.Message().FullName())
+		}
+		return v, nil
+	}
+	return protoreflect.Value{}, fmt.Errorf("unsupported field type %s", fd.Kind())
+}
+
+// responseValue returns the Go value of the response field at path for
+// rendering.
+func responseValue(msg protoreflect.Message, path string) interface{} {
+	segments := strings.Split(path, ".")
+	for _, name := range segments[:len(segments)-1] {
+		fd, _ := lookupPath(msg.Descriptor(), name)
+		msg = msg.Get(fd).Message()
+	}
+	fd, _ := lookupPath(msg.Descriptor(), segments[len(segments)-1])
+	return goValue(fd, msg.Get(fd))
+}
+
+func goValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	switch {
+	case fd.IsList():
+		list := v.List()
+		items := make([]interface{}, list.Len())
+		for i := range items {
+			items[i] = scalarOrMessage(fd, list.Get(i))
+		}
+		return items
+	case fd.IsMap():
+		entries := map[string]interface{}{}
+		v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+			entries[k.String()] = scalarOrMessage(fd.MapValue(), mv)
+			return true
+		})
+		return entries
+	}
+	return scalarOrMessage(fd, v)
+}
+
+func scalarOrMessage(fd protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	if fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind {
+		return v.Message().Interface()
+	}
+	return v.Interface()
+}
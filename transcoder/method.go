@@ -0,0 +1,49 @@
+package transcoder
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Method is a resolved unary RPC, for callers that build requests from
+// string values outside of a route.
+type Method struct {
+	FullMethod string
+	md         protoreflect.MethodDescriptor
+}
+
+// ResolveMethod accepts the same names as Route.RPC.
+func ResolveMethod(rpc string) (*Method, error) {
+	fullMethod, md, err := resolveMethod(rpc)
+	if err != nil {
+		return nil, err
+	}
+	if md.IsStreamingClient() || md.IsStreamingServer() {
+		return nil, fmt.Errorf("%s: streaming methods cannot be called", rpc)
+	}
+	return &Method{FullMethod: fullMethod, md: md}, nil
+}
+
+// CheckField reports whether path names a request field.
+func (m *Method) CheckField(path string) error {
+	_, err := lookupPath(m.md.Input(), path)
+	return err
+}
+
+// NewRequest builds a request with the fields at the given paths parsed
+// from their string values.
+func (m *Method) NewRequest(fields map[string]string) (proto.Message, error) {
+	req := newMessage(m.md.Input())
+	for path, value := range fields {
+		if err := setField(req.ProtoReflect(), path, []string{value}); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return req, nil
+}
+
+func (m *Method) NewResponse() proto.Message {
+	return newMessage(m.md.Output())
+}
@@ -0,0 +1,277 @@
+package transcoder
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	"github.com/rekib0023/event-horizon-gateway/logging"
+	"github.com/rekib0023/event-horizon-gateway/problem"
+	"github.com/rekib0023/event-horizon-gateway/render"
+	"github.com/rekib0023/event-horizon-gateway/shaping"
+	"github.com/rekib0023/event-horizon-gateway/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Hook customizes a transcoded route. Before runs after the request message
+// has been bound and After runs once the RPC succeeded. Returning a
+// *problem.Problem aborts with that problem; if After writes a response the
+// transcoder does not render one.
+type Hook struct {
+	Before func(c *gin.Context, req proto.Message) error
+	After  func(c *gin.Context, res proto.Message) error
+}
+
+// Transcoder exposes unary gRPC methods as REST endpoints using the proto
+// descriptors in the global registry.
+type Transcoder struct {
+	conn          grpc.ClientConnInterface
+	statusMapping *utils.StatusMapping
+	renderer      *render.Renderer
+	hooks         map[string]Hook
+}
+
+func New(conn grpc.ClientConnInterface, statusMapping *utils.StatusMapping, renderer *render.Renderer) *Transcoder {
+	return &Transcoder{
+		conn:          conn,
+		statusMapping: statusMapping,
+		renderer:      renderer,
+		hooks:         map[string]Hook{},
+	}
+}
+
+func (t *Transcoder) RegisterHook(name string, hook Hook) {
+	t.hooks[name] = hook
+}
+
+var pathVariable = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// GinPath converts a route template such as "/users/{userId}" into the
+// gin pattern "/users/:userId".
+func GinPath(template string) string {
+	return pathVariable.ReplaceAllString(template, ":$1")
+}
+
+type binding struct {
+	route      config.Route
+	fullMethod string
+	method     protoreflect.MethodDescriptor
+	response   protoreflect.MessageDescriptor
+	hooks      []Hook
+	pathFields map[string]string
+}
+
+// Handler builds the gin handler for route, resolving its RPC and hooks up
+// front so that misconfigured routes fail at startup.
+func (t *Transcoder) Handler(route config.Route) (gin.HandlerFunc, error) {
+	fullMethod, md, err := resolveMethod(route.RPC)
+	if err != nil {
+		return nil, err
+	}
+	if md.IsStreamingClient() || md.IsStreamingServer() {
+		return nil, fmt.Errorf("%s: streaming methods cannot be transcoded", route.RPC)
+	}
+
+	b := &binding{
+		route:      route,
+		fullMethod: fullMethod,
+		method:     md,
+		response:   md.Output(),
+		pathFields: map[string]string{},
+	}
+
+	for _, m := range pathVariable.FindAllStringSubmatch(route.Path, -1) {
+		fieldPath := m[1]
+		if mapped, ok := route.Fields[m[1]]; ok {
+			fieldPath = mapped
+		}
+		if _, err := lookupPath(md.Input(), fieldPath); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", route.Method, route.Path, err)
+		}
+		b.pathFields[m[1]] = fieldPath
+	}
+	if route.Body != "" && route.Body != "*" {
+		fd, err := lookupPath(md.Input(), route.Body)
+		if err != nil || fd.Kind() != protoreflect.MessageKind || fd.IsList() || fd.IsMap() {
+			return nil, fmt.Errorf("%s %s: body %q must name a message field", route.Method, route.Path, route.Body)
+		}
+	}
+	if route.ResponseBody != "" {
+		fd, err := lookupPath(md.Output(), route.ResponseBody)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: response body: %w", route.Method, route.Path, err)
+		}
+		b.response = nil
+		if fd.Kind() == protoreflect.MessageKind && !fd.IsMap() {
+			b.response = fd.Message()
+		}
+	}
+	for _, name := range route.Hooks {
+		hook, ok := t.hooks[name]
+		if !ok {
+			return nil, fmt.Errorf("%s %s: unknown hook %q", route.Method, route.Path, name)
+		}
+		b.hooks = append(b.hooks, hook)
+	}
+
+	return func(c *gin.Context) { t.serve(c, b) }, nil
+}
+
+func (t *Transcoder) serve(c *gin.Context, b *binding) {
+	if p := shaping.FromContext(c).CheckFields(b.response); p != nil {
+		problem.Abort(c, p)
+		return
+	}
+
+	req := newMessage(b.method.Input())
+	if p := b.bind(c, req); p != nil {
+		problem.Abort(c, p)
+		return
+	}
+
+	for _, hook := range b.hooks {
+		if hook.Before == nil {
+			continue
+		}
+		if err := hook.Before(c, req); err != nil {
+			abortWithHookError(c, err)
+			return
+		}
+	}
+
+	res := newMessage(b.method.Output())
+	if err := t.conn.Invoke(c.Request.Context(), b.fullMethod, req, res); err != nil {
+		problem.Abort(c, problem.FromGRPC(err, b.fullMethod, t.statusMapping))
+		return
+	}
+
+	for _, hook := range b.hooks {
+		if hook.After == nil {
+			continue
+		}
+		if err := hook.After(c, res); err != nil {
+			abortWithHookError(c, err)
+			return
+		}
+		if c.Writer.Written() || c.IsAborted() {
+			return
+		}
+	}
+
+	statusCode := b.route.Status
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+
+	if b.route.ResponseBody != "" {
+		t.renderer.Value(c, statusCode, responseValue(res.ProtoReflect(), b.route.ResponseBody))
+		return
+	}
+	t.renderer.Message(c, statusCode, res)
+}
+
+func (b *binding) bind(c *gin.Context, req proto.Message) *problem.Problem {
+	msg := req.ProtoReflect()
+
+	if b.route.Body != "" {
+		data, err := io.ReadAll(c.Request.Body)
+		if p := problem.FromBodyError(err); p != nil {
+			return p
+		}
+		if err != nil || len(data) == 0 {
+			return problem.New(http.StatusBadRequest, "Invalid request")
+		}
+
+		target := req
+		if b.route.Body != "*" {
+			fd, _ := lookupPath(b.method.Input(), b.route.Body)
+			target = mutableMessage(msg, b.route.Body).Mutable(fd).Message().Interface()
+		}
+		if render.IsProtobuf(c.ContentType()) {
+			err = proto.Unmarshal(data, target)
+		} else {
+			err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, target)
+		}
+		if err != nil {
+			return problem.New(http.StatusBadRequest, "Invalid request")
+		}
+	}
+
+	if b.route.Body != "*" {
+		for key, values := range c.Request.URL.Query() {
+			if _, err := lookupPath(b.method.Input(), key); err != nil {
+				continue
+			}
+			// The body is bound only from the request body, so that query
+			// parameters cannot override fields the client sent in it.
+			if b.route.Body != "" && underPath(b.method.Input(), key, b.route.Body) {
+				continue
+			}
+			if err := setField(msg, key, values); err != nil {
+				return problem.New(http.StatusBadRequest, "Invalid request").WithFieldError(key, err.Error())
+			}
+		}
+	}
+
+	for param, fieldPath := range b.pathFields {
+		if err := setField(msg, fieldPath, []string{c.Param(param)}); err != nil {
+			return problem.New(http.StatusBadRequest, "Invalid request").WithFieldError(param, err.Error())
+		}
+	}
+	return nil
+}
+
+func abortWithHookError(c *gin.Context, err error) {
+	var p *problem.Problem
+	if errors.As(err, &p) {
+		problem.Abort(c, p)
+		return
+	}
+	logging.FromContext(c.Request.Context()).Error("transcoder hook failed", "error", err)
+	problem.Abort(c, problem.New(http.StatusInternalServerError, "Internal server error"))
+}
+
+// resolveMethod accepts "/pkg.Service/Method", "pkg.Service/Method" or
+// "pkg.Service.Method".
+func resolveMethod(rpc string) (string, protoreflect.MethodDescriptor, error) {
+	name := strings.TrimPrefix(rpc, "/")
+	var service, method string
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		service, method = name[:i], name[i+1:]
+	} else if i := strings.LastIndex(name, "."); i >= 0 {
+		service, method = name[:i], name[i+1:]
+	} else {
+		return "", nil, fmt.Errorf("invalid rpc name %q", rpc)
+	}
+
+	desc, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return "", nil, fmt.Errorf("rpc %q: %w", rpc, err)
+	}
+	sd, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return "", nil, fmt.Errorf("rpc %q: %s is not a service", rpc, service)
+	}
+	md := sd.Methods().ByName(protoreflect.Name(method))
+	if md == nil {
+		return "", nil, fmt.Errorf("rpc %q: service %s has no method %s", rpc, service, method)
+	}
+	return "/" + service + "/" + method, md, nil
+}
+
+func newMessage(md protoreflect.MessageDescriptor) proto.Message {
+	if mt, err := protoregistry.GlobalTypes.FindMessageByName(md.FullName()); err == nil {
+		return mt.New().Interface()
+	}
+	return dynamicpb.NewMessage(md)
+}
@@ -0,0 +1,102 @@
+package transcoder_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rekib0023/event-horizon-gateway/config"
+	pb "github.com/rekib0023/event-horizon-gateway/proto"
+	"github.com/rekib0023/event-horizon-gateway/render"
+	"github.com/rekib0023/event-horizon-gateway/transcoder"
+	"github.com/rekib0023/event-horizon-gateway/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeConn records the last request and answers UpdateUser with the user it
+// was sent.
+type fakeConn struct {
+	method string
+	req    proto.Message
+}
+
+func (f *fakeConn) Invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	f.method = method
+	f.req = proto.Clone(args.(proto.Message))
+	if in, ok := args.(*pb.UpdateUserRequest); ok {
+		out := reply.(*pb.UserResponse)
+		out.Id = in.GetUserId().GetId()
+		out.FirstName = in.GetUser().GetFirstName()
+	}
+	return nil
+}
+
+func (f *fakeConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	panic("not used")
+}
+
+var updateUser = config.Route{Method: http.MethodPut, Path: "/users/{userId}", RPC: pb.AuthService_UpdateUser_FullMethodName, Body: "user", Fields: map[string]string{"userId": "userId.id"}}
+
+func newEngine(t *testing.T, conn *fakeConn, route config.Route) *gin.Engine {
+	t.Helper()
+	statusMapping, err := utils.NewStatusMapping(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, err := transcoder.New(conn, statusMapping, render.New(config.RenderOptions{})).Handler(route)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Handle(route.Method, transcoder.GinPath(route.Path), handler)
+	return e
+}
+
+func put(e *gin.Engine, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPut, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestBindBodyAndPath(t *testing.T) {
+	conn := &fakeConn{}
+	e := newEngine(t, conn, updateUser)
+
+	rec := put(e, "/users/7", `{"firstName":"Ada"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	if conn.method != pb.AuthService_UpdateUser_FullMethodName {
+		t.Fatalf("method = %s", conn.method)
+	}
+	req := conn.req.(*pb.UpdateUserRequest)
+	if req.GetUserId().GetId() != 7 || req.GetUser().GetFirstName() != "Ada" {
+		t.Fatalf("request = %v", req)
+	}
+
+	if rec := put(e, "/users/nope", `{}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("non-numeric id: status = %d", rec.Code)
+	}
+}
+
+func TestQueryCannotOverrideBody(t *testing.T) {
+	conn := &fakeConn{}
+	e := newEngine(t, conn, updateUser)
+
+	for _, query := range []string{"user.firstName=Eve", "user.first_name=Eve", "user=x"} {
+		rec := put(e, "/users/7?"+query, `{"firstName":"Ada"}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body %s", query, rec.Code, rec.Body.String())
+		}
+		if got := conn.req.(*pb.UpdateUserRequest).GetUser().GetFirstName(); got != "Ada" {
+			t.Fatalf("%s: firstName = %q, want Ada", query, got)
+		}
+	}
+}
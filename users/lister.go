@@ -0,0 +1,242 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/rekib0023/event-horizon-gateway/proto"
+)
+
+type Page struct {
+	Users         []*pb.UserResponse
+	NextPageToken string
+	TotalSize     int
+}
+
+// Lister returns pages of users. SnapshotLister pages in the gateway until
+// the auth service's GetUsers RPC supports paging itself; an RPC-backed
+// Lister can then replace it without touching the handler.
+type Lister interface {
+	List(ctx context.Context, q Query) (*Page, error)
+}
+
+// SnapshotLister pages over the full GetUsers result, cached for ttl.
+type SnapshotLister struct {
+	gRpc pb.AuthServiceClient
+	ttl  time.Duration
+	Now  func() time.Time
+
+	mu        sync.Mutex
+	users     []*pb.UserResponse
+	fetchedAt time.Time
+	call      *snapshotCall
+
+	hits, misses atomic.Uint64
+}
+
+// snapshotCall is a GetUsers call in flight; its result is set before done
+// is closed.
+type snapshotCall struct {
+	done  chan struct{}
+	users []*pb.UserResponse
+	err   error
+}
+
+type SnapshotStats struct {
+	Users     int
+	Hits      uint64
+	Misses    uint64
+	TTL       time.Duration
+	FetchedAt time.Time
+}
+
+func NewSnapshotLister(gRpc pb.AuthServiceClient, ttl time.Duration) *SnapshotLister {
+	return &SnapshotLister{gRpc: gRpc, ttl: ttl, Now: time.Now}
+}
+
+func (l *SnapshotLister) List(ctx context.Context, q Query) (*Page, error) {
+	all, err := l.snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []*pb.UserResponse
+	for _, u := range all {
+		if q.matches(u) {
+			matched = append(matched, u)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return compareKeys(q.sortKey(matched[i]), q.sortKey(matched[j]), q.Sort) < 0
+	})
+
+	start := 0
+	if q.After != nil {
+		start = sort.Search(len(matched), func(i int) bool {
+			return compareKeys(q.sortKey(matched[i]), q.After, q.Sort) > 0
+		})
+	}
+	end := start + q.Limit
+	if end > len(matched) {
+		end = len(matched)
+	}
+
+	page := &Page{Users: matched[start:end], TotalSize: len(matched)}
+	if end < len(matched) {
+		page.NextPageToken = q.nextPageToken(q.sortKey(matched[end-1]))
+	}
+	return page, nil
+}
+
+// Invalidate drops the cached snapshot, e.g. after a user was changed
+// through the gateway.
+func (l *SnapshotLister) Invalidate() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.users = nil
+	l.call = nil
+}
+
+func (l *SnapshotLister) Stats() SnapshotStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := SnapshotStats{Users: len(l.users), Hits: l.hits.Load(), Misses: l.misses.Load(), TTL: l.ttl}
+	if l.users != nil {
+		stats.FetchedAt = l.fetchedAt
+	}
+	return stats
+}
+
+// snapshot returns the cached users, fetching them when stale. Concurrent
+// misses share one GetUsers call, made outside the lock with the context
+// of the caller that started it.
+func (l *SnapshotLister) snapshot(ctx context.Context) ([]*pb.UserResponse, error) {
+	l.mu.Lock()
+	if l.users != nil && l.Now().Sub(l.fetchedAt) < l.ttl {
+		users := l.users
+		l.mu.Unlock()
+		l.hits.Add(1)
+		return users, nil
+	}
+	l.misses.Add(1)
+
+	if call := l.call; call != nil {
+		l.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.users, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	call := &snapshotCall{done: make(chan struct{})}
+	l.call = call
+	l.mu.Unlock()
+
+	res, err := l.gRpc.GetUsers(ctx, &pb.Empty{})
+
+	l.mu.Lock()
+	call.err = err
+	if err == nil {
+		call.users = res.GetUsers()
+		if call.users == nil {
+			call.users = []*pb.UserResponse{}
+		}
+	}
+	// An Invalidate during the call drops it, so that its possibly stale
+	// result is not cached.
+	if l.call == call {
+		l.call = nil
+		if err == nil {
+			l.users = call.users
+			l.fetchedAt = l.Now()
+		}
+	}
+	l.mu.Unlock()
+	close(call.done)
+	return call.users, call.err
+}
+
+func (q Query) matches(u *pb.UserResponse) bool {
+	if q.Email != "" && !strings.EqualFold(u.GetEmail(), q.Email) {
+		return false
+	}
+	if q.UserNamePrefix != "" && !strings.HasPrefix(strings.ToLower(u.GetUserName()), strings.ToLower(q.UserNamePrefix)) {
+		return false
+	}
+	if !q.CreatedAfter.IsZero() || !q.CreatedBefore.IsZero() {
+		if u.GetCreatedAt() == nil {
+			return false
+		}
+		created := u.GetCreatedAt().AsTime()
+		if !q.CreatedAfter.IsZero() && created.Before(q.CreatedAfter) {
+			return false
+		}
+		if !q.CreatedBefore.IsZero() && !created.Before(q.CreatedBefore) {
+			return false
+		}
+	}
+	return true
+}
+
+// sortKey renders the sort fields of u, followed by its id as a tie-breaker,
+// as strings that order the same way as the underlying values.
+func (q Query) sortKey(u *pb.UserResponse) []string {
+	key := make([]string, 0, len(q.Sort)+1)
+	for _, sf := range q.Sort {
+		key = append(key, fieldKey(u, sf.Field))
+	}
+	return append(key, fieldKey(u, "id"))
+}
+
+func fieldKey(u *pb.UserResponse, field string) string {
+	switch field {
+	case "id":
+		return fmt.Sprintf("%011d", int64(u.GetId())+1<<31)
+	case "firstName":
+		return strings.ToLower(u.GetFirstName())
+	case "lastName":
+		return strings.ToLower(u.GetLastName())
+	case "userName":
+		return strings.ToLower(u.GetUserName())
+	case "email":
+		return strings.ToLower(u.GetEmail())
+	case "createdAt":
+		return timeKey(u.GetCreatedAt().AsTime(), u.GetCreatedAt() != nil)
+	case "updatedAt":
+		return timeKey(u.GetUpdatedAt().AsTime(), u.GetUpdatedAt() != nil)
+	}
+	return ""
+}
+
+func timeKey(t time.Time, ok bool) string {
+	if !ok {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02T15:04:05.000000000Z")
+}
+
+func compareKeys(a, b []string, sortFields []SortField) int {
+	for i := range a {
+		if i >= len(b) {
+			return 1
+		}
+		c := strings.Compare(a[i], b[i])
+		if c == 0 {
+			continue
+		}
+		if i < len(sortFields) && sortFields[i].Desc {
+			return -c
+		}
+		return c
+	}
+	if len(a) < len(b) {
+		return -1
+	}
+	return 0
+}
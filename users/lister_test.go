@@ -0,0 +1,176 @@
+package users_test
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/rekib0023/event-horizon-gateway/proto"
+	"github.com/rekib0023/event-horizon-gateway/users"
+	"google.golang.org/grpc"
+)
+
+// listClient answers GetUsers once release is closed and counts the calls.
+type listClient struct {
+	pb.AuthServiceClient
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+func (c *listClient) GetUsers(ctx context.Context, in *pb.Empty, opts ...grpc.CallOption) (*pb.UserListResponse, error) {
+	c.calls.Add(1)
+	select {
+	case <-c.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return &pb.UserListResponse{Users: []*pb.UserResponse{
+		{Id: 1, UserName: "ada"},
+		{Id: 2, UserName: "Adam"},
+		{Id: 3, UserName: "bob"},
+	}}, nil
+}
+
+func query(t *testing.T, raw string) users.Query {
+	t.Helper()
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, p := users.ParseQuery(values)
+	if p != nil {
+		t.Fatalf("ParseQuery(%s): %v", raw, p)
+	}
+	return q
+}
+
+func TestSnapshotListerSharesFetch(t *testing.T) {
+	client := &listClient{release: make(chan struct{})}
+	l := users.NewSnapshotLister(client, time.Minute)
+	q := query(t, "")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			page, err := l.List(context.Background(), q)
+			if err == nil && page.TotalSize != 3 {
+				t.Errorf("total = %d, want 3", page.TotalSize)
+			}
+			errs <- err
+		}()
+	}
+	for client.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The lock is not held while GetUsers runs, so a canceled caller
+	// returns at once.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		l.List(ctx, q)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("List blocked on the GetUsers call")
+	}
+
+	close(client.release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := client.calls.Load(); n != 1 {
+		t.Fatalf("GetUsers called %d times, want 1", n)
+	}
+	if _, err := l.List(context.Background(), q); err != nil {
+		t.Fatal(err)
+	}
+	if n := client.calls.Load(); n != 1 {
+		t.Fatalf("GetUsers called %d times after a cached read, want 1", n)
+	}
+}
+
+func TestSnapshotListerWaiterCancel(t *testing.T) {
+	client := &listClient{release: make(chan struct{})}
+	l := users.NewSnapshotLister(client, time.Minute)
+	q := query(t, "")
+
+	go l.List(context.Background(), q)
+	for client.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := l.List(ctx, q); err != context.Canceled {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	close(client.release)
+}
+
+func TestSnapshotListerInvalidateDuringFetch(t *testing.T) {
+	client := &listClient{release: make(chan struct{})}
+	l := users.NewSnapshotLister(client, time.Minute)
+	q := query(t, "")
+
+	result := make(chan error)
+	go func() {
+		_, err := l.List(context.Background(), q)
+		result <- err
+	}()
+	for client.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	l.Invalidate()
+	close(client.release)
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.List(context.Background(), q); err != nil {
+		t.Fatal(err)
+	}
+	if n := client.calls.Load(); n != 2 {
+		t.Fatalf("GetUsers called %d times, want 2 since the result fetched before Invalidate is not cached", n)
+	}
+}
+
+func TestPageTokenIgnoresPrefixCase(t *testing.T) {
+	client := &listClient{release: make(chan struct{})}
+	close(client.release)
+	l := users.NewSnapshotLister(client, time.Minute)
+
+	page, err := l.List(context.Background(), query(t, "userName=ad&limit=1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Users) != 1 || page.NextPageToken == "" {
+		t.Fatalf("page = %+v", page)
+	}
+
+	token := page.NextPageToken
+	next := query(t, "userName=AD&limit=1&page_token="+token)
+	page, err = l.List(context.Background(), next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Users) != 1 || page.Users[0].GetId() != 2 {
+		t.Fatalf("second page = %+v", page.Users)
+	}
+
+	values, _ := url.ParseQuery("userName=bo&limit=1&page_token=" + token)
+	if _, p := users.ParseQuery(values); p == nil {
+		t.Fatal("token accepted for a different prefix")
+	}
+}
@@ -0,0 +1,145 @@
+package users
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/rekib0023/event-horizon-gateway/proto"
+	"github.com/rekib0023/event-horizon-gateway/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Profiles resolves users by id with GetUserById, at most concurrency
+// calls at a time, and caches the results for ttl. Users that do not
+// exist are cached too, as nil. Past maxEntries the least recently used
+// users are evicted.
+type Profiles struct {
+	gRpc        pb.AuthServiceClient
+	ttl         time.Duration
+	concurrency int
+	maxEntries  int
+	Now         func() time.Time
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[int32]*list.Element
+
+	hits, misses atomic.Uint64
+}
+
+type profile struct {
+	id      int32
+	user    *pb.UserResponse
+	fetched time.Time
+}
+
+type ProfileStats struct {
+	Entries int
+	Hits    uint64
+	Misses  uint64
+	TTL     time.Duration
+}
+
+// NewProfiles caches up to maxEntries users; 0 leaves the cache
+// unbounded.
+func NewProfiles(gRpc pb.AuthServiceClient, ttl time.Duration, concurrency, maxEntries int) *Profiles {
+	return &Profiles{
+		gRpc:        gRpc,
+		ttl:         ttl,
+		concurrency: concurrency,
+		maxEntries:  maxEntries,
+		Now:         time.Now,
+		lru:         list.New(),
+		entries:     map[int32]*list.Element{},
+	}
+}
+
+// Get returns the users with the given ids, nil for those that do not
+// exist. It fails if any lookup fails otherwise.
+func (p *Profiles) Get(ctx context.Context, ids []int32) (map[int32]*pb.UserResponse, error) {
+	found := make(map[int32]*pb.UserResponse, len(ids))
+	var missing []int32
+
+	p.mu.Lock()
+	for _, id := range ids {
+		if _, ok := found[id]; ok {
+			continue
+		}
+		if el, ok := p.entries[id]; ok {
+			if e := el.Value.(*profile); p.Now().Sub(e.fetched) < p.ttl {
+				p.lru.MoveToFront(el)
+				found[id] = e.user
+				p.hits.Add(1)
+				continue
+			}
+		}
+		found[id] = nil
+		missing = append(missing, id)
+		p.misses.Add(1)
+	}
+	p.mu.Unlock()
+
+	fetch := utils.Parallel(p.concurrency, func(ctx context.Context, id int32) (*pb.UserResponse, error) {
+		u, err := p.gRpc.GetUserById(ctx, &pb.UserId{Id: id})
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		return u, err
+	})
+	users, errs := fetch(ctx, missing)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, id := range missing {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		found[id] = users[i]
+		p.store(id, users[i])
+	}
+	return found, nil
+}
+
+func (p *Profiles) store(id int32, user *pb.UserResponse) {
+	e := &profile{id: id, user: user, fetched: p.Now()}
+	if el, ok := p.entries[id]; ok {
+		el.Value = e
+		p.lru.MoveToFront(el)
+	} else {
+		p.entries[id] = p.lru.PushFront(e)
+	}
+	for p.maxEntries > 0 && p.lru.Len() > p.maxEntries {
+		oldest := p.lru.Back()
+		p.lru.Remove(oldest)
+		delete(p.entries, oldest.Value.(*profile).id)
+	}
+}
+
+// Invalidate drops the cached users, e.g. after a user was changed
+// through the gateway.
+func (p *Profiles) Invalidate() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lru.Init()
+	p.entries = map[int32]*list.Element{}
+}
+
+func (p *Profiles) Stats() ProfileStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return ProfileStats{Entries: len(p.entries), Hits: p.hits.Load(), Misses: p.misses.Load(), TTL: p.ttl}
+}
+
+// PublicProfile is the part of u that any signed-in user may see.
+func PublicProfile(u *pb.UserResponse) *pb.UserResponse {
+	return &pb.UserResponse{
+		Id:        u.GetId(),
+		FirstName: u.GetFirstName(),
+		LastName:  u.GetLastName(),
+		UserName:  u.GetUserName(),
+	}
+}
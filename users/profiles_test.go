@@ -0,0 +1,93 @@
+package users_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/rekib0023/event-horizon-gateway/proto"
+	"github.com/rekib0023/event-horizon-gateway/users"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// authClient answers GetUserById for ids below 100 and counts the calls.
+type authClient struct {
+	pb.AuthServiceClient
+	mu    sync.Mutex
+	calls map[int32]int
+}
+
+func (c *authClient) GetUserById(ctx context.Context, in *pb.UserId, opts ...grpc.CallOption) (*pb.UserResponse, error) {
+	c.mu.Lock()
+	c.calls[in.GetId()]++
+	c.mu.Unlock()
+	switch {
+	case in.GetId() == 500:
+		return nil, status.Error(codes.Unavailable, "down")
+	case in.GetId() >= 100:
+		return nil, status.Error(codes.NotFound, "no such user")
+	}
+	return &pb.UserResponse{Id: in.GetId(), Email: "secret@example.com"}, nil
+}
+
+func TestProfilesEvictLeastRecentlyUsed(t *testing.T) {
+	client := &authClient{calls: map[int32]int{}}
+	p := users.NewProfiles(client, time.Minute, 1, 2)
+	ctx := context.Background()
+
+	for _, ids := range [][]int32{{1, 2}, {1}, {3}, {1, 3}} {
+		if _, err := p.Get(ctx, ids); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if client.calls[1] != 1 || client.calls[3] != 1 {
+		t.Fatalf("calls = %v, want 1 and 3 cached", client.calls)
+	}
+	if _, err := p.Get(ctx, []int32{2}); err != nil {
+		t.Fatal(err)
+	}
+	if client.calls[2] != 2 {
+		t.Fatalf("user 2 fetched %d times, want it evicted", client.calls[2])
+	}
+	if stats := p.Stats(); stats.Entries != 2 {
+		t.Fatalf("entries = %d, want 2", stats.Entries)
+	}
+}
+
+func TestProfilesExpire(t *testing.T) {
+	client := &authClient{calls: map[int32]int{}}
+	p := users.NewProfiles(client, time.Minute, 1, 0)
+	now := time.Now()
+	p.Now = func() time.Time { return now }
+
+	found, err := p.Get(context.Background(), []int32{1, 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found[1].GetId() != 1 || found[200] != nil {
+		t.Fatalf("found = %v", found)
+	}
+	now = now.Add(2 * time.Minute)
+	p.Get(context.Background(), []int32{1, 200})
+	if client.calls[1] != 2 || client.calls[200] != 2 {
+		t.Fatalf("calls = %v, want expired entries refetched", client.calls)
+	}
+}
+
+func TestProfilesFailure(t *testing.T) {
+	p := users.NewProfiles(&authClient{calls: map[int32]int{}}, time.Minute, 4, 0)
+
+	if _, err := p.Get(context.Background(), []int32{1, 500}); status.Code(err) != codes.Unavailable {
+		t.Fatalf("err = %v, want Unavailable", err)
+	}
+}
+
+func TestPublicProfile(t *testing.T) {
+	u := users.PublicProfile(&pb.UserResponse{Id: 1, UserName: "ada", Email: "ada@example.com"})
+	if u.GetEmail() != "" || u.GetUserName() != "ada" {
+		t.Fatalf("public profile = %v", u)
+	}
+}
@@ -0,0 +1,149 @@
+package users
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rekib0023/event-horizon-gateway/problem"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+var sortableFields = map[string]bool{
+	"id":        true,
+	"firstName": true,
+	"lastName":  true,
+	"userName":  true,
+	"email":     true,
+	"createdAt": true,
+	"updatedAt": true,
+}
+
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// Query selects a page of users. After holds the sort key of the last user
+// on the previous page, decoded from the page token.
+type Query struct {
+	Limit          int
+	Sort           []SortField
+	Email          string
+	UserNamePrefix string
+	CreatedAfter   time.Time
+	CreatedBefore  time.Time
+	After          []string
+}
+
+// UsesEmail reports whether q filters or sorts by email, which reveals
+// addresses even where the field itself is redacted.
+func (q Query) UsesEmail() bool {
+	if q.Email != "" {
+		return true
+	}
+	for _, sf := range q.Sort {
+		if sf.Field == "email" {
+			return true
+		}
+	}
+	return false
+}
+
+type pageToken struct {
+	Fingerprint string   `json:"f"`
+	After       []string `json:"a"`
+}
+
+// ParseQuery reads limit, page_token, sort, email, userName, created_after
+// and created_before from the query string.
+func ParseQuery(values url.Values) (Query, *problem.Problem) {
+	q := Query{Limit: DefaultLimit}
+	invalid := problem.New(http.StatusBadRequest, "Invalid query parameters")
+
+	if v := values.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > MaxLimit {
+			invalid.WithFieldError("limit", fmt.Sprintf("must be an integer between 1 and %d", MaxLimit))
+		}
+		q.Limit = limit
+	}
+
+	sortParam := values.Get("sort")
+	if sortParam == "" {
+		sortParam = "id"
+	}
+	for _, field := range strings.Split(sortParam, ",") {
+		sf := SortField{Field: strings.TrimSpace(field)}
+		if strings.HasPrefix(sf.Field, "-") {
+			sf.Field, sf.Desc = sf.Field[1:], true
+		}
+		if !sortableFields[sf.Field] {
+			invalid.WithFieldError("sort", fmt.Sprintf("cannot sort by %q", sf.Field))
+			continue
+		}
+		q.Sort = append(q.Sort, sf)
+	}
+
+	q.Email = strings.TrimSpace(values.Get("email"))
+	q.UserNamePrefix = values.Get("userName")
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"created_after", &q.CreatedAfter}, {"created_before", &q.CreatedBefore}} {
+		v := values.Get(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			invalid.WithFieldError(p.name, "must be an RFC 3339 timestamp")
+			continue
+		}
+		*p.dst = t
+	}
+
+	if len(invalid.Errors) > 0 {
+		return Query{}, invalid
+	}
+
+	if v := values.Get("page_token"); v != "" {
+		var token pageToken
+		data, err := base64.RawURLEncoding.DecodeString(v)
+		if err == nil {
+			err = json.Unmarshal(data, &token)
+		}
+		if err != nil || token.Fingerprint != q.fingerprint() {
+			return Query{}, invalid.WithFieldError("page_token", "is invalid or does not match the other query parameters")
+		}
+		q.After = token.After
+	}
+	return q, nil
+}
+
+// fingerprint identifies the sort order and filters a page token was issued
+// for, so that tokens cannot be replayed against a different query.
+func (q Query) fingerprint() string {
+	h := sha256.New()
+	for _, sf := range q.Sort {
+		fmt.Fprintf(h, "%s:%t;", sf.Field, sf.Desc)
+	}
+	fmt.Fprintf(h, "%s|%s|%d|%d", strings.ToLower(q.Email), strings.ToLower(q.UserNamePrefix), q.CreatedAfter.UnixNano(), q.CreatedBefore.UnixNano())
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+func (q Query) nextPageToken(after []string) string {
+	data, _ := json.Marshal(pageToken{Fingerprint: q.fingerprint(), After: after})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
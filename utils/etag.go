@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ETag returns a strong entity tag for msg, hashed from its deterministic
+// wire encoding.
+func ETag(msg proto.Message) (string, error) {
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// MatchETag reports whether the If-Match or If-None-Match header value
+// matches etag. "*" matches any current entity. Weak comparison ignores
+// W/ prefixes, as If-None-Match requires; If-Match compares strongly.
+func MatchETag(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+			etag = strings.TrimPrefix(etag, "W/")
+		} else if strings.HasPrefix(candidate, "W/") {
+			continue
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// WithVariant tags one representation of the entity tagged etag, such as
+// a media type or a redacted view, keeping the tag strong. The variant is
+// appended to the tag as a short hash, e.g. "abc-1a2b3c4d".
+func WithVariant(etag, variant string) string {
+	sum := sha256.Sum256([]byte(variant))
+	return strings.TrimSuffix(etag, `"`) + "-" + hex.EncodeToString(sum[:4]) + `"`
+}
+
+// MatchVersion compares an If-Match header strongly with etag, ignoring
+// the variants added by WithVariant: a write applies to the entity, not to
+// the representation the client read it in.
+func MatchVersion(header, etag string) bool {
+	candidates := strings.Split(header, ",")
+	for i, candidate := range candidates {
+		candidate = strings.TrimSpace(candidate)
+		if j := strings.LastIndex(candidate, "-"); j > 0 && strings.HasPrefix(candidate, `"`) && strings.HasSuffix(candidate, `"`) {
+			candidate = candidate[:j] + `"`
+		}
+		candidates[i] = candidate
+	}
+	return MatchETag(strings.Join(candidates, ","), etag, false)
+}
@@ -0,0 +1,43 @@
+package utils_test
+
+import (
+	"testing"
+
+	pb "github.com/rekib0023/event-horizon-gateway/proto"
+	"github.com/rekib0023/event-horizon-gateway/utils"
+)
+
+func TestETagVariants(t *testing.T) {
+	version, err := utils.ETag(&pb.UserResponse{Id: 1, UserName: "ada"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := utils.ETag(&pb.UserResponse{Id: 1, UserName: "bob"})
+	json := utils.WithVariant(version, "application/json;")
+	protobuf := utils.WithVariant(version, "application/x-protobuf;")
+	if json == protobuf || json != utils.WithVariant(version, "application/json;") {
+		t.Fatalf("variants %s and %s", json, protobuf)
+	}
+
+	for _, tc := range []struct {
+		header string
+		want   bool
+	}{
+		{json, true},
+		{protobuf, true},
+		{version, true},
+		{`"stale", ` + protobuf, true},
+		{"*", true},
+		{"W/" + json, false},
+		{utils.WithVariant(other, "application/json;"), false},
+		{`"stale"`, false},
+	} {
+		if got := utils.MatchVersion(tc.header, version); got != tc.want {
+			t.Errorf("MatchVersion(%s) = %t, want %t", tc.header, got, tc.want)
+		}
+	}
+
+	if !utils.MatchETag("W/"+json, json, true) || utils.MatchETag(protobuf, json, true) {
+		t.Error("If-None-Match must compare whole representation tags")
+	}
+}
@@ -1,48 +1,99 @@
 package utils
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 
 	"google.golang.org/grpc/codes"
 )
 
+// StatusClientClosedRequest is the non-standard status google.rpc uses for
+// CANCELLED.
+const StatusClientClosedRequest = 499
+
+var defaultHttpStatusCodes = map[codes.Code]int{
+	codes.OK:                 http.StatusOK,
+	codes.Canceled:           StatusClientClosedRequest,
+	codes.Unknown:            http.StatusInternalServerError,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.FailedPrecondition: http.StatusBadRequest,
+	codes.Aborted:            http.StatusConflict,
+	codes.OutOfRange:         http.StatusBadRequest,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Internal:           http.StatusInternalServerError,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DataLoss:           http.StatusInternalServerError,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+}
+
+// GetHttpStatusCode maps a gRPC code to HTTP following the google.rpc.Code
+// conventions.
 func GetHttpStatusCode(code codes.Code) int {
-	switch code {
-	case 0: // Status.OK
-		return http.StatusOK
-	case 1: // Status.CANCELLED
-		return http.StatusConflict
-	case 2: // Status.UNKNOWN
-		return http.StatusInternalServerError
-	case 3: // Status.INVALID_ARGUMENT
-		return http.StatusBadRequest
-	case 4: // Status.DEADLINE_EXCEEDED
-		return http.StatusRequestTimeout
-	case 5: // Status.NOT_FOUND
-		return http.StatusNotFound
-	case 6: // Status.ALREADY_EXISTS
-		return http.StatusConflict
-	case 7: // Status.PERMISSION_DENIED
-		return http.StatusForbidden
-	case 8: // Status.RESOURCE_EXHAUSTED
-		return http.StatusTooManyRequests
-	case 9: // Status.FAILED_PRECONDITION
-		return http.StatusPreconditionFailed
-	case 10: // Status.ABORTED
-		return http.StatusConflict
-	case 11: // Status.OUT_OF_RANGE
-		return http.StatusBadRequest
-	case 12: // Status.UNIMPLEMENTED
-		return http.StatusNotImplemented
-	case 13: // Status.INTERNAL
-		return http.StatusInternalServerError
-	case 14: // Status.UNAVAILABLE
-		return http.StatusServiceUnavailable
-	case 15: // Status.DATA_LOSS
-		return http.StatusInternalServerError
-	case 16: // Status.UNAUTHENTICATED
-		return http.StatusUnauthorized
-	default:
-		return http.StatusInternalServerError
+	if httpStatus, ok := defaultHttpStatusCodes[code]; ok {
+		return httpStatus
+	}
+	return http.StatusInternalServerError
+}
+
+// StatusMapping overrides the default gRPC to HTTP mapping per service
+// ("auth.AuthService") or per method ("/auth.AuthService/Login"). Method
+// overrides take precedence over service overrides.
+type StatusMapping struct {
+	overrides map[string]map[codes.Code]int
+}
+
+// NewStatusMapping builds a mapping from code names as used in
+// configuration, e.g. {"/auth.AuthService/Login": {"NOT_FOUND": 401}}.
+func NewStatusMapping(overrides map[string]map[string]int) (*StatusMapping, error) {
+	m := &StatusMapping{overrides: map[string]map[codes.Code]int{}}
+	for target, table := range overrides {
+		key := normalizeTarget(target)
+		m.overrides[key] = map[codes.Code]int{}
+		for name, httpStatus := range table {
+			var code codes.Code
+			if err := code.UnmarshalJSON([]byte(`"` + strings.ToUpper(name) + `"`)); err != nil {
+				return nil, fmt.Errorf("status mapping for %s: %w", target, err)
+			}
+			if httpStatus < 100 || httpStatus > 599 {
+				return nil, fmt.Errorf("status mapping for %s: invalid HTTP status %d for %s", target, httpStatus, name)
+			}
+			m.overrides[key][code] = httpStatus
+		}
+	}
+	return m, nil
+}
+
+// HTTPStatus resolves the HTTP status for a code returned by fullMethod.
+// A nil mapping uses the defaults.
+func (m *StatusMapping) HTTPStatus(fullMethod string, code codes.Code) int {
+	if m != nil && fullMethod != "" {
+		if httpStatus, ok := m.overrides[normalizeTarget(fullMethod)][code]; ok {
+			return httpStatus
+		}
+		if httpStatus, ok := m.overrides[serviceOf(fullMethod)][code]; ok {
+			return httpStatus
+		}
+	}
+	return GetHttpStatusCode(code)
+}
+
+func normalizeTarget(target string) string {
+	if strings.Contains(target, "/") && !strings.HasPrefix(target, "/") {
+		return "/" + target
+	}
+	return target
+}
+
+func serviceOf(fullMethod string) string {
+	service := strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(service, "/"); i >= 0 {
+		service = service[:i]
 	}
+	return service
 }
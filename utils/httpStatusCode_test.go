@@ -0,0 +1,56 @@
+package utils_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/rekib0023/event-horizon-gateway/utils"
+	"google.golang.org/grpc/codes"
+)
+
+func TestStatusMapping(t *testing.T) {
+	m, err := utils.NewStatusMapping(map[string]map[string]int{
+		"auth.AuthService":           {"NOT_FOUND": http.StatusUnauthorized, "unavailable": http.StatusBadGateway},
+		"auth.AuthService/Login":     {"NOT_FOUND": http.StatusForbidden},
+		"/auth.AuthService/Register": {"ALREADY_EXISTS": http.StatusUnprocessableEntity},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		method string
+		code   codes.Code
+		want   int
+	}{
+		{"/auth.AuthService/Login", codes.NotFound, http.StatusForbidden},
+		{"/auth.AuthService/GetUser", codes.NotFound, http.StatusUnauthorized},
+		{"/auth.AuthService/GetUser", codes.Unavailable, http.StatusBadGateway},
+		{"/auth.AuthService/Register", codes.AlreadyExists, http.StatusUnprocessableEntity},
+		{"/auth.AuthService/Login", codes.AlreadyExists, http.StatusConflict},
+		{"/other.Service/Get", codes.NotFound, http.StatusNotFound},
+		{"", codes.NotFound, http.StatusNotFound},
+		{"/auth.AuthService/Login", codes.Canceled, utils.StatusClientClosedRequest},
+		{"/auth.AuthService/Login", codes.Code(99), http.StatusInternalServerError},
+	} {
+		if got := m.HTTPStatus(tc.method, tc.code); got != tc.want {
+			t.Errorf("HTTPStatus(%q, %v) = %d, want %d", tc.method, tc.code, got, tc.want)
+		}
+	}
+
+	var none *utils.StatusMapping
+	if got := none.HTTPStatus("/auth.AuthService/Login", codes.NotFound); got != http.StatusNotFound {
+		t.Errorf("nil mapping = %d, want the default", got)
+	}
+}
+
+func TestStatusMappingRejectsBadConfig(t *testing.T) {
+	for name, overrides := range map[string]map[string]map[string]int{
+		"unknown code": {"auth.AuthService": {"NOPE": 400}},
+		"bad status":   {"auth.AuthService": {"NOT_FOUND": 42}},
+	} {
+		if _, err := utils.NewStatusMapping(overrides); err == nil {
+			t.Errorf("%s: NewStatusMapping succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// CollectIDs returns, in order and without duplicates, the ids in a
+// decoded event service list: bare ids or objects carrying "userId" or
+// "id", in the list ListAt finds at path.
+func CollectIDs(doc interface{}, path string) []string {
+	items := ListAt(doc, path)
+
+	seen := map[string]bool{}
+	var ids []string
+	for _, item := range items {
+		if id := ItemID(item); id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// ListAt returns the list at the dotted path in doc, or doc itself when
+// it is a list.
+func ListAt(doc interface{}, path string) []interface{} {
+	if items, ok := doc.([]interface{}); ok {
+		return items
+	}
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		doc = obj[key]
+	}
+	items, _ := doc.([]interface{})
+	return items
+}
+
+// ItemID returns the id of one list item as CollectIDs reads it, or ""
+// when it has none.
+func ItemID(item interface{}) string {
+	if obj, ok := item.(map[string]interface{}); ok {
+		item = obj["userId"]
+		if item == nil {
+			item = obj["id"]
+		}
+	}
+	switch v := item.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
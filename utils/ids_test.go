@@ -0,0 +1,42 @@
+package utils_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rekib0023/event-horizon-gateway/utils"
+)
+
+func TestItemID(t *testing.T) {
+	for _, tc := range []struct {
+		item any
+		want string
+	}{
+		{map[string]any{"id": float64(1000000)}, "1000000"},
+		{map[string]any{"userId": json.Number("2000000"), "id": "9"}, "2000000"},
+		{"abc", "abc"},
+		{map[string]any{"name": "no id"}, ""},
+		{true, ""},
+	} {
+		if got := utils.ItemID(tc.item); got != tc.want {
+			t.Errorf("ItemID(%v) = %q, want %q", tc.item, got, tc.want)
+		}
+	}
+}
+
+func TestCollectIDs(t *testing.T) {
+	var doc any
+	if err := json.Unmarshal([]byte(`{"data":{"people":[{"userId":3},{"id":"4"},3,"x",{"name":"none"}]}}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	got := utils.CollectIDs(doc, "data.people")
+	if len(got) != 3 || got[0] != "3" || got[1] != "4" || got[2] != "x" {
+		t.Fatalf("CollectIDs = %v", got)
+	}
+	if got := utils.CollectIDs(doc, "attendees"); len(got) != 0 {
+		t.Fatalf("CollectIDs at a missing path = %v", got)
+	}
+	if got := utils.CollectIDs([]any{"1", "2"}, "attendees"); len(got) != 2 {
+		t.Fatalf("CollectIDs of a bare list = %v", got)
+	}
+}
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// PageLink renders an RFC 8288 link to the current request URL with the
+// page token query parameter replaced. An empty token links to the first
+// page.
+func PageLink(u *url.URL, param, token, rel string) string {
+	target := *u
+	query := target.Query()
+	query.Del(param)
+	if token != "" {
+		query.Set(param, token)
+	}
+	target.RawQuery = query.Encode()
+	target.Scheme, target.Host = "", ""
+	return fmt.Sprintf("<%s>; rel=%q", target.String(), rel)
+}
+
+func JoinLinks(links ...string) string {
+	return strings.Join(links, ", ")
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"context"
+	"sync"
+)
+
+// Parallel builds a batch from single-item calls, at most limit at a
+// time, for backends without a batch API.
+func Parallel[K comparable, V any](limit int, fn func(ctx context.Context, key K) (V, error)) func(ctx context.Context, keys []K) ([]V, []error) {
+	return func(ctx context.Context, keys []K) ([]V, []error) {
+		values := make([]V, len(keys))
+		errs := make([]error, len(keys))
+		sem := make(chan struct{}, max(limit, 1))
+		var wg sync.WaitGroup
+		for i, key := range keys {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int, key K) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				values[i], errs[i] = fn(ctx, key)
+			}(i, key)
+		}
+		wg.Wait()
+		return values, errs
+	}
+}